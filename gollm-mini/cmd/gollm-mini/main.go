@@ -6,26 +6,40 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	// side-effect 注册 Provider
+	_ "gollm-mini/internal/provider/anthropic"
 	_ "gollm-mini/internal/provider/huggingface"
 	_ "gollm-mini/internal/provider/ollama"
 	_ "gollm-mini/internal/provider/openai"
+	"gollm-mini/internal/provider/replay"
 
 	"gollm-mini/internal/cli"
+	"gollm-mini/internal/core"
 	"gollm-mini/internal/server"
 	"gollm-mini/internal/template"
+	"gollm-mini/internal/types"
 )
 
 func main() {
 	// --------- CLI 参数解析 ---------
-	mode := flag.String("mode", "chat", "运行模式：chat / server / template")
-	provider := flag.String("provider", "ollama", "Provider：ollama / openai / hf ...")
-	model := flag.String("model", "llama3", "模型名称：llama3 / gpt-4o-mini ...")
-	stream := flag.Bool("stream", true, "是否实时输出（结构化 JSON 会自动关闭）")
+	mode := flag.String("mode", "chat", "运行模式：chat / server / template / models")
+	provider := flag.String("provider", "ollama", "Provider：ollama / openai / anthropic / hf ...")
+	model := flag.String("model", "", "模型名称：llama3 / gpt-4o-mini ...（留空使用 Provider 默认模型）")
+	fallbackFlag := flag.String("fallback", "", "备用目标，逗号分隔：hf:TinyLlama/TinyLlama-1.1B-Chat-v1.0,openai:gpt-4o-mini")
+	stream := flag.Bool("stream", true, "是否实时输出（结构化模式下逐条打印部分对象的 JSON Patch）")
 	schemaPath := flag.String("schema", "", "JSON Schema 文件路径（触发结构化模式）")
 	sessionID := flag.String("sid", "", "对话 Session ID")
+	imageFlag := flag.String("image", "", "图片附件（本地路径或 URL，逗号分隔），随第一轮提问发送")
+
+	// 采样参数：负数表示不设置，使用 Provider 默认值
+	temperature := flag.Float64("temperature", -1, "采样温度 [0,2]")
+	topP := flag.Float64("top_p", -1, "nucleus 采样 (0,1]")
+	maxTokens := flag.Int("max_tokens", 0, "最大生成 token 数（0 = 默认）")
+	stopFlag := flag.String("stop", "", "停止序列，逗号分隔")
+	seed := flag.Int("seed", -1, "随机种子（-1 = 不设置）")
 
 	port := flag.String("port", "8080", "server 端口")
 	system := flag.String("system", "", "覆盖 system 指令文本")
@@ -36,6 +50,12 @@ func main() {
 	timeout := flag.Duration("timeout", 5*time.Minute, "全局超时时间")
 	flag.Parse()
 
+	// GOLLM_REPLAY=record|replay|auto：录制 / 回放全部 Provider 的调用
+	if err := replay.FromEnv(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+
 	// ---------- 模板管理子命令 ----------
 	if *mode == "template" {
 		store, _ := template.Open("templates.db")
@@ -61,17 +81,17 @@ func main() {
 		}
 	}
 
-	// 结构化模式自动关闭流式
-	if *schemaPath != "" {
-		*stream = false
-	}
-
 	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
 	defer cancel()
 
 	switch *mode {
 	case "chat":
-		err := cli.RunChat(
+		fallbacks, err := core.ParseTargets(*fallbackFlag)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		err = cli.RunChat(
 			ctx,
 			*provider,
 			*model,
@@ -80,6 +100,9 @@ func main() {
 			*varsFlag,
 			*system,
 			*sessionID, // ← 将 session 透传给 RunChat
+			splitList(*imageFlag),
+			fallbacks,
+			genOptions(*temperature, *topP, *maxTokens, *stopFlag, *seed),
 			*stream,
 		)
 		if err != nil {
@@ -87,6 +110,12 @@ func main() {
 			os.Exit(1)
 		}
 
+	case "models":
+		if err := cli.RunModels(ctx, *provider, flag.Args()); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+
 	case "server":
 		fmt.Println("REST server listening on :" + *port)
 		if err := server.Run(ctx, ":"+*port); err != nil && err != http.ErrServerClosed {
@@ -99,3 +128,29 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// splitList 解析逗号分隔的参数，忽略空项
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// genOptions 把 CLI 采样参数转换为 GenerateOptions，负数视为未设置
+func genOptions(temperature, topP float64, maxTokens int, stop string, seed int) types.GenerateOptions {
+	opts := types.GenerateOptions{MaxTokens: maxTokens, Stop: splitList(stop)}
+	if temperature >= 0 {
+		opts.Temperature = &temperature
+	}
+	if topP >= 0 {
+		opts.TopP = &topP
+	}
+	if seed >= 0 {
+		opts.Seed = &seed
+	}
+	return opts
+}
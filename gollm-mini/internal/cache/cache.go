@@ -23,19 +23,43 @@ const (
 // ---- 单例 DB ----
 var (
 	db   *bolt.DB
-	once sync.Once
+	dbMu sync.Mutex
 )
 
-// open 单例
-func openDB() *bolt.DB {
-	once.Do(func() {
-		db, _ = bolt.Open("prompt_cache.db", 0600, &bolt.Options{Timeout: 1 * time.Second})
-		_ = db.Update(func(tx *bolt.Tx) error {
-			_, _ = tx.CreateBucketIfNotExists([]byte(bucket))
-			return nil
-		})
+// openDB 单例；打开失败不缓存错误，下次调用重试（文件被占用 / 目录暂不可写时可自愈）
+func openDB() (*bolt.DB, error) {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+	if db != nil {
+		return db, nil
+	}
+	d, err := bolt.Open("prompt_cache.db", 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		return err
+	}); err != nil {
+		_ = d.Close()
+		return nil, err
+	}
+	db = d
+	return db, nil
+}
+
+// Ping 检查 prompt_cache.db 可用且 bucket 存在（用于就绪检查）
+func Ping() error {
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
+	return db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte(bucket)) == nil {
+			return fmt.Errorf("bucket %s missing", bucket)
+		}
+		return nil
 	})
-	return db
 }
 
 // KeyFromMessages 根据 provider+model+messages 生成 SHA256
@@ -54,7 +78,10 @@ type Value struct {
 
 // Get 查询缓存
 func Get(key string) (val Value, ok bool) {
-	db := openDB()
+	db, err := openDB()
+	if err != nil {
+		return val, false
+	}
 	_ = db.View(func(tx *bolt.Tx) error {
 		v := tx.Bucket([]byte(bucket)).Get([]byte(key))
 		if v == nil {
@@ -75,7 +102,10 @@ func Get(key string) (val Value, ok bool) {
 
 // Put 写入缓存
 func Put(key string, val Value) {
-	db := openDB()
+	db, err := openDB()
+	if err != nil {
+		return
+	}
 	_ = db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(bucket))
 
@@ -115,7 +145,10 @@ func evictOldest(b *bolt.Bucket) {
 }
 
 func ClearAll() error {
-	db := openDB()
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
 	return db.Update(func(tx *bolt.Tx) error {
 		_ = tx.DeleteBucket([]byte(bucket))
 		_, err := tx.CreateBucketIfNotExists([]byte(bucket))
@@ -124,7 +157,10 @@ func ClearAll() error {
 }
 
 func DeleteKey(key string) error {
-	db := openDB()
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
 	return db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(bucket))
 		return b.Delete([]byte(key))
@@ -132,7 +168,10 @@ func DeleteKey(key string) error {
 }
 
 func DeletePrefix(prefix string) error {
-	db := openDB()
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
 	return db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(bucket))
 		c := b.Cursor()
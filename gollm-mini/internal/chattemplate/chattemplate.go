@@ -0,0 +1,220 @@
+// Package chattemplate 把完整对话渲染为各模型家族的原始 prompt 格式，
+// 供只接受纯文本输入的后端（TGI / hf-api / HF Inference API）使用。
+// BOS 由各后端 tokenizer 自动添加，这里不重复输出。
+package chattemplate
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"gollm-mini/internal/types"
+)
+
+// Template 描述一个模型家族的对话格式
+type Template struct {
+	Name string
+	// AssistantPrefix 是最后追加的“轮到 assistant”标记，也用于在回显全文中定位回答
+	AssistantPrefix string
+	// Stop 是该家族的回合结束符，生成时作为停止序列，后处理时截断
+	Stop []string
+
+	marker string // 在回显全文中定位回答起点；为空时取 AssistantPrefix
+	render func(msgs []types.Message) string
+}
+
+// Render 渲染完整对话，并以 AssistantPrefix 结尾等待模型续写
+func (t Template) Render(msgs []types.Message) string {
+	return t.render(msgs) + t.AssistantPrefix
+}
+
+// Clean 去掉回显的 prompt、前导 BOS 以及第一个停止符之后的内容。
+// prompt 为空表示 txt 只包含新生成的部分。
+func (t Template) Clean(txt, prompt string) string {
+	// 1) 跳过 prompt 中已有的 assistant 标记，定位本轮回答起点
+	marker := t.marker
+	if marker == "" {
+		marker = strings.TrimSpace(t.AssistantPrefix)
+	}
+	if prompt != "" && marker != "" {
+		for n := strings.Count(prompt, marker); n > 0; n-- {
+			i := strings.Index(txt, marker)
+			if i == -1 {
+				break
+			}
+			txt = txt[i+len(marker):]
+		}
+	}
+
+	// 2) 去掉前导 BOS
+	txt = strings.TrimSpace(txt)
+	for _, bos := range []string{"<s>", "<bos>", "<|begin_of_text|>"} {
+		for strings.HasPrefix(txt, bos) {
+			txt = strings.TrimSpace(strings.TrimPrefix(txt, bos))
+		}
+	}
+
+	// 3) 截断到第一个停止符
+	for _, stop := range t.Stop {
+		if j := strings.Index(txt, stop); j != -1 {
+			txt = txt[:j]
+		}
+	}
+	return strings.TrimSpace(txt)
+}
+
+// ---------------------------------------------------------------------
+// 各家族格式
+// ---------------------------------------------------------------------
+
+var (
+	ChatML = Template{
+		Name:            "chatml",
+		AssistantPrefix: "<|im_start|>assistant\n",
+		Stop:            []string{"<|im_end|>", "<|endoftext|>"},
+		render: func(msgs []types.Message) string {
+			var sb strings.Builder
+			for _, m := range msgs {
+				sb.WriteString("<|im_start|>" + string(m.Role) + "\n" + m.Text() + "<|im_end|>\n")
+			}
+			return sb.String()
+		},
+	}
+
+	Llama3 = Template{
+		Name:            "llama3",
+		AssistantPrefix: "<|start_header_id|>assistant<|end_header_id|>\n\n",
+		Stop:            []string{"<|eot_id|>", "<|end_of_text|>"},
+		render: func(msgs []types.Message) string {
+			var sb strings.Builder
+			for _, m := range msgs {
+				sb.WriteString("<|start_header_id|>" + string(m.Role) + "<|end_header_id|>\n\n" +
+					strings.TrimSpace(m.Text()) + "<|eot_id|>")
+			}
+			return sb.String()
+		},
+	}
+
+	// Mistral 没有 system 角色，system 内容并入第一条 user 消息
+	Mistral = Template{
+		Name:            "mistral",
+		AssistantPrefix: "",
+		Stop:            []string{"</s>", "[INST]"},
+		marker:          "[/INST]",
+		render: func(msgs []types.Message) string {
+			var sb strings.Builder
+			for _, m := range mergeSystem(msgs, "\n\n") {
+				if m.Role == types.RoleAssistant {
+					sb.WriteString(" " + strings.TrimSpace(m.Text()) + "</s>")
+					continue
+				}
+				sb.WriteString("[INST] " + strings.TrimSpace(m.Text()) + " [/INST]")
+			}
+			return sb.String()
+		},
+	}
+
+	// Zephyr 的 system 块可省略；调用方没给 system 时不补默认提示
+	Zephyr = Template{
+		Name:            "zephyr",
+		AssistantPrefix: "<|assistant|>\n",
+		Stop:            []string{"</s>", "<|user|>"},
+		render: func(msgs []types.Message) string {
+			var sb strings.Builder
+			for _, m := range msgs {
+				role := m.Role
+				if role == types.RoleTool {
+					role = types.RoleUser
+				}
+				sb.WriteString("<|" + string(role) + "|>\n" + m.Text() + "</s>\n")
+			}
+			return sb.String()
+		},
+	}
+
+	// Gemma 只有 user / model 两种角色，system 并入第一条 user 消息
+	Gemma = Template{
+		Name:            "gemma",
+		AssistantPrefix: "<start_of_turn>model\n",
+		Stop:            []string{"<end_of_turn>", "<eos>"},
+		render: func(msgs []types.Message) string {
+			var sb strings.Builder
+			for _, m := range mergeSystem(msgs, "\n\n") {
+				role := "user"
+				if m.Role == types.RoleAssistant {
+					role = "model"
+				}
+				sb.WriteString("<start_of_turn>" + role + "\n" + strings.TrimSpace(m.Text()) + "<end_of_turn>\n")
+			}
+			return sb.String()
+		},
+	}
+)
+
+var byName = map[string]Template{
+	ChatML.Name:  ChatML,
+	Llama3.Name:  Llama3,
+	Mistral.Name: Mistral,
+	Zephyr.Name:  Zephyr,
+	Gemma.Name:   Gemma,
+}
+
+// Get 按名字查找模板
+func Get(name string) (Template, bool) {
+	t, ok := byName[strings.ToLower(name)]
+	return t, ok
+}
+
+// Names 返回支持的模板名
+func Names() []string {
+	names := make([]string, 0, len(byName))
+	for n := range byName {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ForModel 选择模板：HF_CHAT_TEMPLATE 优先，否则按模型 ID 推断，未知时用 ChatML
+func ForModel(modelID string) Template {
+	if t, ok := Get(os.Getenv("HF_CHAT_TEMPLATE")); ok {
+		return t
+	}
+	id := strings.ToLower(modelID)
+	switch {
+	case strings.Contains(id, "llama-3"), strings.Contains(id, "llama3"):
+		return Llama3
+	case strings.Contains(id, "mistral"), strings.Contains(id, "mixtral"):
+		return Mistral
+	case strings.Contains(id, "gemma"):
+		return Gemma
+	case strings.Contains(id, "zephyr"), strings.Contains(id, "tinyllama"):
+		return Zephyr
+	default:
+		return ChatML
+	}
+}
+
+// mergeSystem 把所有 system 消息拼接后并入第一条非 system 消息
+func mergeSystem(msgs []types.Message, sep string) []types.Message {
+	var (
+		sys  []string
+		rest []types.Message
+	)
+	for _, m := range msgs {
+		if m.Role == types.RoleSystem {
+			sys = append(sys, m.Text())
+			continue
+		}
+		rest = append(rest, types.Message{Role: m.Role, Content: m.Text()})
+	}
+	if len(sys) == 0 {
+		return rest
+	}
+	prefix := strings.Join(sys, sep)
+	if len(rest) == 0 || rest[0].Role == types.RoleAssistant {
+		return append([]types.Message{{Role: types.RoleUser, Content: prefix}}, rest...)
+	}
+	rest[0].Content = prefix + sep + rest[0].Content
+	return rest
+}
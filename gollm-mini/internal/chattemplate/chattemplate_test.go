@@ -0,0 +1,152 @@
+package chattemplate
+
+import (
+	"reflect"
+	"testing"
+
+	"gollm-mini/internal/types"
+)
+
+var convo = []types.Message{
+	{Role: types.RoleSystem, Content: "Be brief."},
+	{Role: types.RoleUser, Content: "Hi"},
+	{Role: types.RoleAssistant, Content: "Hello!"},
+	{Role: types.RoleUser, Content: "Name a color."},
+}
+
+func TestRender(t *testing.T) {
+	cases := []struct {
+		tpl  Template
+		want string
+	}{
+		{ChatML, "<|im_start|>system\nBe brief.<|im_end|>\n" +
+			"<|im_start|>user\nHi<|im_end|>\n" +
+			"<|im_start|>assistant\nHello!<|im_end|>\n" +
+			"<|im_start|>user\nName a color.<|im_end|>\n" +
+			"<|im_start|>assistant\n"},
+		{Llama3, "<|start_header_id|>system<|end_header_id|>\n\nBe brief.<|eot_id|>" +
+			"<|start_header_id|>user<|end_header_id|>\n\nHi<|eot_id|>" +
+			"<|start_header_id|>assistant<|end_header_id|>\n\nHello!<|eot_id|>" +
+			"<|start_header_id|>user<|end_header_id|>\n\nName a color.<|eot_id|>" +
+			"<|start_header_id|>assistant<|end_header_id|>\n\n"},
+		{Mistral, "[INST] Be brief.\n\nHi [/INST] Hello!</s>[INST] Name a color. [/INST]"},
+		{Zephyr, "<|system|>\nBe brief.</s>\n" +
+			"<|user|>\nHi</s>\n" +
+			"<|assistant|>\nHello!</s>\n" +
+			"<|user|>\nName a color.</s>\n" +
+			"<|assistant|>\n"},
+		{Gemma, "<start_of_turn>user\nBe brief.\n\nHi<end_of_turn>\n" +
+			"<start_of_turn>model\nHello!<end_of_turn>\n" +
+			"<start_of_turn>user\nName a color.<end_of_turn>\n" +
+			"<start_of_turn>model\n"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.tpl.Name, func(t *testing.T) {
+			if got := tc.tpl.Render(convo); got != tc.want {
+				t.Errorf("Render =\n%q\nwant\n%q", got, tc.want)
+			}
+		})
+	}
+}
+
+// 没有 system 消息时不补默认提示
+func TestRenderWithoutSystem(t *testing.T) {
+	msgs := []types.Message{{Role: types.RoleUser, Content: "Hi"}}
+	want := map[string]string{
+		"chatml":  "<|im_start|>user\nHi<|im_end|>\n<|im_start|>assistant\n",
+		"llama3":  "<|start_header_id|>user<|end_header_id|>\n\nHi<|eot_id|><|start_header_id|>assistant<|end_header_id|>\n\n",
+		"mistral": "[INST] Hi [/INST]",
+		"zephyr":  "<|user|>\nHi</s>\n<|assistant|>\n",
+		"gemma":   "<start_of_turn>user\nHi<end_of_turn>\n<start_of_turn>model\n",
+	}
+	for _, name := range Names() {
+		tpl, _ := Get(name)
+		if got := tpl.Render(msgs); got != want[name] {
+			t.Errorf("%s: Render = %q, want %q", name, got, want[name])
+		}
+	}
+}
+
+func TestClean(t *testing.T) {
+	for _, name := range Names() {
+		tpl, _ := Get(name)
+		t.Run(name, func(t *testing.T) {
+			prompt := tpl.Render(convo)
+			stop := tpl.Stop[0]
+
+			// 后端回显 prompt（可能带 BOS），回答后接停止符和多余内容
+			echoed := "<s>" + prompt + " Blue." + stop + "\nmore turns"
+			if got := tpl.Clean(echoed, prompt); got != "Blue." {
+				t.Errorf("Clean(echoed) = %q", got)
+			}
+			// 只返回新生成部分
+			if got := tpl.Clean("<bos> Blue."+stop, ""); got != "Blue." {
+				t.Errorf("Clean(generated) = %q", got)
+			}
+			// 任一停止符都截断
+			for _, s := range tpl.Stop {
+				if got := tpl.Clean("Blue."+s+"Red.", ""); got != "Blue." {
+					t.Errorf("Clean with stop %q = %q", s, got)
+				}
+			}
+		})
+	}
+}
+
+func TestForModel(t *testing.T) {
+	cases := map[string]string{
+		"meta-llama/Meta-Llama-3-8B-Instruct":  "llama3",
+		"meta-llama/Llama-3.1-8B-Instruct":     "llama3",
+		"mistralai/Mistral-7B-Instruct-v0.3":   "mistral",
+		"mistralai/Mixtral-8x7B-Instruct-v0.1": "mistral",
+		"google/gemma-2-9b-it":                 "gemma",
+		"HuggingFaceH4/zephyr-7b-beta":         "zephyr",
+		"TinyLlama/TinyLlama-1.1B-Chat-v1.0":   "zephyr",
+		"Qwen/Qwen2-7B-Instruct":               "chatml",
+		"":                                     "chatml",
+	}
+	t.Setenv("HF_CHAT_TEMPLATE", "")
+	for id, want := range cases {
+		if got := ForModel(id).Name; got != want {
+			t.Errorf("ForModel(%q) = %s, want %s", id, got, want)
+		}
+	}
+
+	// HF_CHAT_TEMPLATE 优先于按 ID 推断（不区分大小写）
+	t.Setenv("HF_CHAT_TEMPLATE", "Gemma")
+	if got := ForModel("meta-llama/Meta-Llama-3-8B-Instruct").Name; got != "gemma" {
+		t.Errorf("override: got %s, want gemma", got)
+	}
+	// 未知的名字被忽略
+	t.Setenv("HF_CHAT_TEMPLATE", "vicuna")
+	if got := ForModel("mistralai/Mistral-7B-Instruct-v0.3").Name; got != "mistral" {
+		t.Errorf("unknown override: got %s, want mistral", got)
+	}
+}
+
+func TestMergeSystem(t *testing.T) {
+	user := func(s string) types.Message { return types.Message{Role: types.RoleUser, Content: s} }
+	asst := func(s string) types.Message { return types.Message{Role: types.RoleAssistant, Content: s} }
+	sys := func(s string) types.Message { return types.Message{Role: types.RoleSystem, Content: s} }
+
+	cases := []struct {
+		name string
+		in   []types.Message
+		want []types.Message
+	}{
+		{"no system", []types.Message{user("a"), asst("b")}, []types.Message{user("a"), asst("b")}},
+		{"into first user", []types.Message{sys("s"), user("a")}, []types.Message{user("s\n\na")}},
+		{"several system messages", []types.Message{sys("s1"), user("a"), sys("s2")}, []types.Message{user("s1\n\ns2\n\na")}},
+		{"assistant first", []types.Message{sys("s"), asst("b")}, []types.Message{user("s"), asst("b")}},
+		{"system only", []types.Message{sys("s")}, []types.Message{user("s")}},
+		{"parts flattened", []types.Message{{Role: types.RoleUser, Parts: []types.ContentPart{{Type: types.PartText, Text: "hi"}}}},
+			[]types.Message{user("hi")}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := mergeSystem(tc.in, "\n\n"); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("mergeSystem = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
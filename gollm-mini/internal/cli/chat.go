@@ -17,9 +17,13 @@ import (
 
 const defaultCtx = 3000 // fallback
 
-// RunChat 交互式 CLI
+// RunChat 交互式 CLI；images 为本地路径或 URL，随第一轮提问发送；
+// fallbacks 为主目标失败时依次尝试的备用目标
 func RunChat(ctx context.Context,
 	provider, model, schema, tplName, varJSON, sysOverride, sessionID string,
+	images []string,
+	fallbacks []core.Target,
+	opts types.GenerateOptions,
 	stream bool,
 ) error {
 
@@ -44,11 +48,25 @@ func RunChat(ctx context.Context,
 		}
 	}
 
+	// ---------- 1.1 载入图片附件 ----------
+	var attachments []types.ContentPart
+	for _, src := range images {
+		img, err := helper.LoadImage(src)
+		if err != nil {
+			return fmt.Errorf("load image %s: %w", src, err)
+		}
+		attachments = append(attachments, img)
+	}
+
 	// ---------- 2. 创建 LLM ----------
-	llm, err := core.New(provider, model)
+	targets := append([]core.Target{{Provider: provider, Model: model}}, fallbacks...)
+	llm, err := core.NewChain(targets...)
 	if err != nil {
 		return err
 	}
+	if err := llm.SetOptions(opts); err != nil {
+		return err
+	}
 
 	reader := bufio.NewReader(os.Stdin)
 	fmt.Println("🔹 gollm-mini | 交互模式，exit 退出")
@@ -56,7 +74,7 @@ func RunChat(ctx context.Context,
 	// ---------- 3. 初始化对话历史 ----------
 	var history []types.Message
 	if sessionID != "" {
-		if hist, e := memory.Load(sessionID); e == nil {
+		if hist, e := memory.Load(sessionID, llm.Tokenizer()); e == nil {
 			history = hist
 		}
 	}
@@ -70,6 +88,7 @@ func RunChat(ctx context.Context,
 	}
 
 	// context token limit
+	tok := llm.Tokenizer()
 	ctxLimit := defaultCtx
 	if tplLoaded && tpl.MaxLen > 0 {
 		ctxLimit = tpl.MaxLen
@@ -101,15 +120,33 @@ func RunChat(ctx context.Context,
 		}
 
 		// 4.1.1 截断
-		messages = helper.TruncateMessages(messages, ctxLimit)
+		messages = helper.TruncateMessagesFor(tok, messages, ctxLimit)
+
+		// 4.1.2 图片只随第一轮发送
+		if len(attachments) > 0 {
+			messages = helper.AttachImages(messages, attachments)
+			attachments = nil
+		}
 
 		// ----- 4.2 结构化输出 -----
 		if schema != "" {
 			var result map[string]interface{}
-			if _, err := llm.StructuredGenerate(ctx, messages, schema, &result); err != nil {
+			var (
+				rep core.StructuredReport
+				err error
+			)
+			if stream {
+				rep, err = llm.StructuredStream(ctx, messages, schema, &result, printPartial)
+			} else {
+				rep, err = llm.StructuredGenerate(ctx, messages, schema, &result)
+			}
+			if err != nil {
 				fmt.Println("Error：结构化失败:", err)
 				continue
 			}
+			if rep.Attempts > 1 || rep.LocalRepair {
+				fmt.Printf("（%d 次尝试，本地修复：%v）\n", rep.Attempts, rep.LocalRepair)
+			}
 			pretty, _ := json.MarshalIndent(result, "", "  ")
 			fmt.Println("🤖 JSON:\n", string(pretty))
 
@@ -142,11 +179,12 @@ func RunChat(ctx context.Context,
 				_ = memory.Append(sessionID, []types.Message{userMsg, assistantMsg})
 			}
 		} else {
-			ans, _, err := llm.Generate(ctx, messages)
+			res, err := llm.Generate(ctx, messages)
 			if err != nil {
 				fmt.Println("Error:", err)
 				continue
 			}
+			ans := res.Text
 			fmt.Println("🤖:", ans)
 			userMsg := types.Message{Role: types.RoleUser, Content: userInput}
 			assistantMsg := types.Message{Role: types.RoleAssistant, Content: ans}
@@ -158,3 +196,28 @@ func RunChat(ctx context.Context,
 		}
 	}
 }
+
+// printPartial 流式结构化输出：逐条打印 JSON Patch（修复轮次开始时提示重新生成）
+func printPartial(p core.StructuredPartial) {
+	for _, op := range p.Patch {
+		if op.Path == "" { // 本轮第一个快照
+			if p.Attempt > 1 {
+				fmt.Printf("  ↻ 第 %d 次尝试\n", p.Attempt)
+			}
+			if v, _ := json.Marshal(op.Value); string(v) != "{}" && string(v) != "[]" {
+				fmt.Printf("  + %s\n", v)
+			}
+			continue
+		}
+		if op.Op == "remove" {
+			fmt.Printf("  - %s\n", op.Path)
+			continue
+		}
+		sign := "+"
+		if op.Op == "replace" {
+			sign = "~"
+		}
+		v, _ := json.Marshal(op.Value)
+		fmt.Printf("  %s %s = %s\n", sign, op.Path, v)
+	}
+}
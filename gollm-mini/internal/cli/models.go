@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"gollm-mini/internal/helper"
+	"gollm-mini/internal/provider"
+	"gollm-mini/internal/types"
+)
+
+const modelsUsage = `用法：-mode models -provider ollama <命令>
+  list                     列出模型
+  pull <model>             拉取模型（显示进度）
+  show <model>             查看模型详情
+  rm <model>               删除模型
+  keepalive <model> <dur>  加载并常驻 dur（如 10m；-1 永久）
+  unload <model>           立即卸载`
+
+// RunModels 模型管理子命令；args 为 flag 之后的位置参数
+func RunModels(ctx context.Context, providerName string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing command\n%s", modelsUsage)
+	}
+	if args[0] == "list" {
+		models, err := provider.Models(ctx, providerName, true)
+		if err != nil {
+			return err
+		}
+		for _, m := range models {
+			fmt.Printf("%-40s ctx=%-7d tools=%-5t vision=%-5t embeddings=%t\n",
+				m.ID, m.ContextWindow, m.Tools, m.Vision, m.Embeddings)
+		}
+		return nil
+	}
+
+	if len(args) < 2 {
+		return fmt.Errorf("missing model name\n%s", modelsUsage)
+	}
+	p, err := provider.Get(providerName, "")
+	if err != nil {
+		return err
+	}
+	mm, ok := provider.As[provider.ModelManager](p)
+	if !ok {
+		return fmt.Errorf("provider %s does not support model management", providerName)
+	}
+	model := args[1]
+
+	switch args[0] {
+	case "pull":
+		err = mm.PullModel(ctx, model, printProgress)
+		fmt.Println()
+		return err
+	case "show":
+		d, err := mm.ShowModel(ctx, model)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	case "rm":
+		if err := mm.DeleteModel(ctx, model); err != nil {
+			return err
+		}
+		fmt.Println("deleted", model)
+		return nil
+	case "keepalive":
+		if len(args) < 3 {
+			return fmt.Errorf("missing duration\n%s", modelsUsage)
+		}
+		d, err := helper.ParseKeepAlive(args[2])
+		if err != nil {
+			return err
+		}
+		if err := mm.KeepAlive(ctx, model, d); err != nil {
+			return err
+		}
+		fmt.Printf("%s keep_alive=%s\n", model, args[2])
+		return nil
+	case "unload":
+		if err := mm.KeepAlive(ctx, model, 0); err != nil {
+			return err
+		}
+		fmt.Println("unloaded", model)
+		return nil
+	}
+	return fmt.Errorf("unknown command %q\n%s", args[0], modelsUsage)
+}
+
+var lastStatus string // printProgress 上一条状态
+
+// printProgress 同一行刷新下载进度；状态切换时换行
+func printProgress(p types.PullProgress) {
+	label := p.Status
+	if p.Host != "" {
+		label = p.Host + " " + label
+	}
+	if label != lastStatus && lastStatus != "" {
+		fmt.Println()
+	}
+	lastStatus = label
+	if p.Total > 0 {
+		fmt.Printf("\r%s %3d%% (%d/%d MB)", label, p.Completed*100/p.Total, p.Completed>>20, p.Total>>20)
+		return
+	}
+	fmt.Printf("\r%s", label)
+}
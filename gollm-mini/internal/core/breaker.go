@@ -0,0 +1,299 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"gollm-mini/internal/monitor"
+	"gollm-mini/internal/provider"
+)
+
+// BreakerState 熔断器状态
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota // 正常放行
+	BreakerHalfOpen                     // 冷却结束，放行单个探测请求
+	BreakerOpen                         // 熔断中，直接失败
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerHalfOpen:
+		return "half-open"
+	case BreakerOpen:
+		return "open"
+	}
+	return "closed"
+}
+
+// BreakerConfig 熔断参数；可用 GOLLM_BREAKER_* 环境变量覆盖默认值
+type BreakerConfig struct {
+	Window      time.Duration // 统计错误率的滚动窗口
+	MinRequests int           // 窗口内请求数达到该值才会判断错误率
+	Threshold   float64       // 错误率 ≥ Threshold 时熔断
+	Cooldown    time.Duration // 熔断后多久进入 half-open
+}
+
+// breakerBuckets 滚动窗口切分的桶数
+const breakerBuckets = 10
+
+var defaultBreakerConfig = BreakerConfig{
+	Window:      time.Minute,
+	MinRequests: 5,
+	Threshold:   0.5,
+	Cooldown:    30 * time.Second,
+}
+
+// CircuitOpenError 目标处于熔断状态时 core 直接返回的错误
+type CircuitOpenError struct {
+	Target  Target
+	RetryAt time.Time // 预计进入 half-open 的时间
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit open for %s (retry after %s)", e.Target, e.RetryAt.Format(time.RFC3339))
+}
+
+// BreakerSnapshot 供 /admin/breakers 展示
+type BreakerSnapshot struct {
+	Provider  string     `json:"provider"`
+	Model     string     `json:"model"`
+	State     string     `json:"state"`
+	Requests  int        `json:"requests"` // 窗口内请求数
+	Failures  int        `json:"failures"` // 窗口内失败数
+	ErrorRate float64    `json:"error_rate"`
+	OpenedAt  *time.Time `json:"opened_at,omitempty"` // 仅 open / half-open 时有值
+	RetryAt   *time.Time `json:"retry_at,omitempty"`
+}
+
+type bucket struct {
+	start    time.Time
+	ok, fail int
+}
+
+type breaker struct {
+	mu       sync.Mutex
+	target   Target
+	cfg      BreakerConfig
+	state    BreakerState
+	buckets  [breakerBuckets]bucket
+	openedAt time.Time
+	probing  bool             // half-open 时是否已有探测请求在途
+	now      func() time.Time // 时钟，测试中可替换
+}
+
+var breakers = struct {
+	sync.Mutex
+	m    map[Target]*breaker
+	cfg  BreakerConfig
+	once sync.Once
+}{m: map[Target]*breaker{}}
+
+// breakerFor 返回 provider+model 对应的熔断器（全局共享，跨请求统计）
+func breakerFor(t Target) *breaker {
+	breakers.once.Do(func() { breakers.cfg = breakerConfigFromEnv() })
+	breakers.Lock()
+	defer breakers.Unlock()
+	b, ok := breakers.m[t]
+	if !ok {
+		b = &breaker{target: t, cfg: breakers.cfg, now: time.Now}
+		breakers.m[t] = b
+		monitor.BreakerState.WithLabelValues(t.Provider, t.Model).Set(float64(BreakerClosed))
+	}
+	return b
+}
+
+// Breakers 返回所有熔断器的当前状态（按目标排序）
+func Breakers() []BreakerSnapshot {
+	breakers.Lock()
+	list := make([]*breaker, 0, len(breakers.m))
+	for _, b := range breakers.m {
+		list = append(list, b)
+	}
+	breakers.Unlock()
+
+	out := make([]BreakerSnapshot, 0, len(list))
+	for _, b := range list {
+		out = append(out, b.snapshot())
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Provider != out[j].Provider {
+			return out[i].Provider < out[j].Provider
+		}
+		return out[i].Model < out[j].Model
+	})
+	return out
+}
+
+// allow 判断是否放行；open 且冷却结束时转为 half-open 并放行一个探测请求
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		retryAt := b.openedAt.Add(b.cfg.Cooldown)
+		if b.now().Before(retryAt) {
+			return &CircuitOpenError{Target: b.target, RetryAt: retryAt}
+		}
+		b.transition(BreakerHalfOpen)
+		b.probing = true
+	case BreakerHalfOpen:
+		if b.probing {
+			return &CircuitOpenError{Target: b.target, RetryAt: b.now().Add(b.cfg.Cooldown)}
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// record 记录一次调用结果并按需切换状态
+func (b *breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	cur := b.bucket(now)
+	if err != nil {
+		cur.fail++
+	} else {
+		cur.ok++
+	}
+
+	switch b.state {
+	case BreakerHalfOpen:
+		b.probing = false
+		if err != nil {
+			b.openedAt = now
+			b.transition(BreakerOpen)
+			return
+		}
+		b.buckets = [breakerBuckets]bucket{} // 探测成功，清空历史重新统计
+		b.transition(BreakerClosed)
+	case BreakerClosed:
+		total, fails := b.counts(now)
+		if total >= b.cfg.MinRequests && float64(fails)/float64(total) >= b.cfg.Threshold {
+			b.openedAt = now
+			b.transition(BreakerOpen)
+		}
+	}
+}
+
+// bucket 返回当前时间所在的桶，过期桶会被重置
+func (b *breaker) bucket(now time.Time) *bucket {
+	width := b.cfg.Window / breakerBuckets
+	start := now.Truncate(width)
+	bk := &b.buckets[(start.UnixNano()/int64(width))%breakerBuckets]
+	if !bk.start.Equal(start) {
+		*bk = bucket{start: start}
+	}
+	return bk
+}
+
+// counts 统计窗口内的请求数与失败数
+func (b *breaker) counts(now time.Time) (total, fails int) {
+	for _, bk := range b.buckets {
+		if now.Sub(bk.start) < b.cfg.Window {
+			total += bk.ok + bk.fail
+			fails += bk.fail
+		}
+	}
+	return total, fails
+}
+
+func (b *breaker) transition(to BreakerState) {
+	if b.state == to {
+		return
+	}
+	log.Printf("[BREAKER] %s %s -> %s", b.target, b.state, to)
+	b.state = to
+	monitor.BreakerState.WithLabelValues(b.target.Provider, b.target.Model).Set(float64(to))
+	monitor.BreakerTransitions.WithLabelValues(b.target.Provider, b.target.Model, to.String()).Inc()
+}
+
+func (b *breaker) snapshot() BreakerSnapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	total, fails := b.counts(b.now())
+	s := BreakerSnapshot{
+		Provider: b.target.Provider,
+		Model:    b.target.Model,
+		State:    b.state.String(),
+		Requests: total,
+		Failures: fails,
+	}
+	if total > 0 {
+		s.ErrorRate = float64(fails) / float64(total)
+	}
+	if b.state != BreakerClosed {
+		opened, retry := b.openedAt, b.openedAt.Add(b.cfg.Cooldown)
+		s.OpenedAt, s.RetryAt = &opened, &retry
+	}
+	return s
+}
+
+// guard 包装单次尝试：熔断时快速失败；调用方取消的请求不计入统计，
+// 调用方自身的错误（参数错误、上下文过长）说明后端可达，按成功计
+func (l *LLM) guard(ctx context.Context, fn func() error) func() error {
+	b := breakerFor(l.Target())
+	return func() error {
+		if err := b.allow(); err != nil {
+			return err
+		}
+		err := fn()
+		if ctx.Err() != nil {
+			// 不计入结果，但要释放 half-open 的探测名额
+			b.mu.Lock()
+			b.probing = false
+			b.mu.Unlock()
+			return err
+		}
+		if provider.ClientError(err) {
+			b.record(nil)
+			return err
+		}
+		b.record(err)
+		return err
+	}
+}
+
+func breakerConfigFromEnv() BreakerConfig {
+	cfg := defaultBreakerConfig
+	if d, ok := envDuration("GOLLM_BREAKER_WINDOW"); ok && d >= breakerBuckets {
+		cfg.Window = d
+	}
+	if d, ok := envDuration("GOLLM_BREAKER_COOLDOWN"); ok {
+		cfg.Cooldown = d
+	}
+	if v := os.Getenv("GOLLM_BREAKER_MIN_REQUESTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.MinRequests = n
+		}
+	}
+	if v := os.Getenv("GOLLM_BREAKER_THRESHOLD"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 && f <= 1 {
+			cfg.Threshold = f
+		}
+	}
+	return cfg
+}
+
+func envDuration(key string) (time.Duration, bool) {
+	v := os.Getenv(key)
+	if v == "" {
+		return 0, false
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("[WARN] invalid %s %q: %v", key, v, err)
+		return 0, false
+	}
+	return d, true
+}
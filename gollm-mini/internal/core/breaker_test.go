@@ -0,0 +1,292 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"gollm-mini/internal/provider"
+	"gollm-mini/internal/types"
+)
+
+// fakeClock 手动推进的时钟
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.t = c.t.Add(d)
+	c.mu.Unlock()
+}
+
+// stubModel 每次返回 err，并记录调用次数
+type stubModel struct {
+	err   error
+	calls int
+}
+
+func (s *stubModel) Generate(context.Context, []types.Message, types.GenerateOptions) (string, types.Usage, error) {
+	s.calls++
+	return "", types.Usage{}, s.err
+}
+
+func (s *stubModel) Stream(ctx context.Context, msgs []types.Message, opts types.GenerateOptions, _ func(types.Chunk)) (types.Usage, error) {
+	_, u, err := s.Generate(ctx, msgs, opts)
+	return u, err
+}
+
+var testBreakerConfig = BreakerConfig{
+	Window:      10 * time.Second, // 每桶 1s
+	MinRequests: 4,
+	Threshold:   0.5,
+	Cooldown:    5 * time.Second,
+}
+
+// breakerHarness 一个使用假时钟的熔断器，以及经 guard 调用 stub 的函数
+type breakerHarness struct {
+	b     *breaker
+	clock *fakeClock
+	stub  *stubModel
+	call  func(ctx context.Context, err error) error
+}
+
+func newBreakerHarness(t *testing.T) *breakerHarness {
+	t.Helper()
+	target := Target{Provider: "breaker-stub", Model: t.Name()}
+	breakerFor(target) // 触发全局配置加载后再替换为测试实例
+
+	h := &breakerHarness{
+		clock: &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		stub:  &stubModel{},
+	}
+	h.b = &breaker{target: target, cfg: testBreakerConfig, now: h.clock.Now}
+	breakers.Lock()
+	breakers.m[target] = h.b
+	breakers.Unlock()
+	t.Cleanup(func() {
+		breakers.Lock()
+		delete(breakers.m, target)
+		breakers.Unlock()
+	})
+
+	llm := &LLM{name: target.Provider, model: target.Model, p: h.stub}
+	h.call = func(ctx context.Context, err error) error {
+		h.stub.err = err
+		return llm.guard(ctx, func() error {
+			_, _, e := llm.p.Generate(ctx, nil, types.GenerateOptions{})
+			return e
+		})()
+	}
+	return h
+}
+
+func (h *breakerHarness) state() BreakerState {
+	h.b.mu.Lock()
+	defer h.b.mu.Unlock()
+	return h.b.state
+}
+
+var errBackend = &provider.Error{Kind: provider.ErrOverloaded, Provider: "breaker-stub"}
+
+// open 连续失败直到熔断
+func (h *breakerHarness) open(t *testing.T) {
+	t.Helper()
+	for range testBreakerConfig.MinRequests {
+		_ = h.call(context.Background(), errBackend)
+	}
+	if h.state() != BreakerOpen {
+		t.Fatalf("state = %s, want open", h.state())
+	}
+}
+
+func TestBreakerOpensOnErrorRate(t *testing.T) {
+	h := newBreakerHarness(t)
+	ctx := context.Background()
+
+	// 请求数未达 MinRequests 时不判断错误率
+	for range 3 {
+		_ = h.call(ctx, errBackend)
+	}
+	if h.state() != BreakerClosed {
+		t.Fatalf("state = %s after 3 requests, want closed", h.state())
+	}
+
+	// 新窗口：错误率低于阈值保持 closed，达到阈值时熔断
+	h.clock.Advance(testBreakerConfig.Window)
+	for i, err := range []error{nil, nil, nil, errBackend, errBackend} {
+		_ = h.call(ctx, err)
+		if h.state() != BreakerClosed {
+			t.Fatalf("state = %s after request %d, want closed below the threshold", h.state(), i+1)
+		}
+	}
+	_ = h.call(ctx, errBackend) // 3/6 = 0.5
+	if h.state() != BreakerOpen {
+		t.Fatalf("state = %s at 3/6 failures, want open", h.state())
+	}
+
+	// open：不调用后端，直接返回 CircuitOpenError
+	calls := h.stub.calls
+	err := h.call(ctx, nil)
+	var ce *CircuitOpenError
+	if !errors.As(err, &ce) || h.stub.calls != calls {
+		t.Fatalf("err = %v, backend calls %d -> %d; want fast failure", err, calls, h.stub.calls)
+	}
+	if want := h.clock.Now().Add(testBreakerConfig.Cooldown); !ce.RetryAt.Equal(want) {
+		t.Errorf("RetryAt = %v, want %v", ce.RetryAt, want)
+	}
+}
+
+func TestBreakerCooldownAndProbe(t *testing.T) {
+	h := newBreakerHarness(t)
+	h.open(t)
+
+	// 冷却未结束
+	h.clock.Advance(testBreakerConfig.Cooldown - time.Second)
+	if err := h.b.allow(); err == nil {
+		t.Fatal("allowed before the cooldown ended")
+	}
+
+	// 冷却结束：只放行一个探测请求
+	h.clock.Advance(time.Second)
+	if err := h.b.allow(); err != nil {
+		t.Fatalf("probe rejected: %v", err)
+	}
+	if h.state() != BreakerHalfOpen {
+		t.Fatalf("state = %s, want half-open", h.state())
+	}
+	var ce *CircuitOpenError
+	if err := h.b.allow(); !errors.As(err, &ce) {
+		t.Fatalf("second request during the probe: err = %v, want CircuitOpenError", err)
+	}
+
+	// 探测成功：closed，历史清空
+	h.b.record(nil)
+	if h.state() != BreakerClosed {
+		t.Fatalf("state = %s after a successful probe, want closed", h.state())
+	}
+	if s := h.b.snapshot(); s.Failures != 0 || s.Requests != 0 || s.OpenedAt != nil {
+		t.Errorf("snapshot after close = %+v, want a fresh window", s)
+	}
+}
+
+func TestBreakerProbeFailureReopens(t *testing.T) {
+	h := newBreakerHarness(t)
+	h.open(t)
+	h.clock.Advance(testBreakerConfig.Cooldown)
+
+	if err := h.call(context.Background(), errBackend); !errors.Is(err, provider.ErrOverloaded) {
+		t.Fatalf("probe err = %v, want the backend error", err)
+	}
+	if h.state() != BreakerOpen {
+		t.Fatalf("state = %s after a failed probe, want open", h.state())
+	}
+	// 冷却从探测失败时重新计算
+	s := h.b.snapshot()
+	if want := h.clock.Now().Add(testBreakerConfig.Cooldown); s.RetryAt == nil || !s.RetryAt.Equal(want) {
+		t.Errorf("RetryAt = %v, want %v", s.RetryAt, want)
+	}
+	h.clock.Advance(testBreakerConfig.Cooldown - time.Millisecond)
+	if err := h.b.allow(); err == nil {
+		t.Error("allowed before the new cooldown ended")
+	}
+}
+
+func TestBreakerRollingWindow(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("expired buckets drop out", func(t *testing.T) {
+		h := newBreakerHarness(t)
+		for range 3 {
+			_ = h.call(ctx, errBackend)
+		}
+		// 整个窗口过去后旧的失败不再计入，落在同一个桶位时桶被重置
+		h.clock.Advance(testBreakerConfig.Window)
+		_ = h.call(ctx, errBackend)
+		if h.state() != BreakerClosed {
+			t.Fatalf("state = %s, want closed: old failures should have expired", h.state())
+		}
+		if s := h.b.snapshot(); s.Requests != 1 || s.Failures != 1 {
+			t.Errorf("window = %d/%d, want 1/1", s.Failures, s.Requests)
+		}
+	})
+
+	t.Run("buckets within the window add up", func(t *testing.T) {
+		h := newBreakerHarness(t)
+		for i := range 4 {
+			_ = h.call(ctx, errBackend)
+			if i < 3 {
+				h.clock.Advance(3 * time.Second) // 分布在 4 个不同的桶里，跨度 9s < 10s
+			}
+		}
+		if h.state() != BreakerOpen {
+			t.Fatalf("state = %s, want open", h.state())
+		}
+	})
+}
+
+func TestBreakerIgnoresClientErrorsAndCancel(t *testing.T) {
+	h := newBreakerHarness(t)
+
+	// 参数错误说明后端可达，按成功计
+	for range 10 {
+		err := h.call(context.Background(), &provider.Error{Kind: provider.ErrInvalidRequest})
+		if !errors.Is(err, provider.ErrInvalidRequest) {
+			t.Fatalf("err = %v", err)
+		}
+	}
+	if s := h.b.snapshot(); h.state() != BreakerClosed || s.Failures != 0 || s.Requests != 10 {
+		t.Fatalf("state %s, window %d/%d; invalid requests must count as successes", h.state(), s.Failures, s.Requests)
+	}
+
+	// 调用方取消的请求不计入统计
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for range 10 {
+		_ = h.call(ctx, context.Canceled)
+	}
+	if s := h.b.snapshot(); h.state() != BreakerClosed || s.Requests != 10 {
+		t.Fatalf("state %s, %d requests; cancelled calls must not be recorded", h.state(), s.Requests)
+	}
+
+	// half-open 中被取消的探测释放名额，下一个请求仍可探测
+	h.clock.Advance(testBreakerConfig.Window)
+	h.open(t)
+	h.clock.Advance(testBreakerConfig.Cooldown)
+	_ = h.call(ctx, context.Canceled)
+	if h.state() != BreakerHalfOpen {
+		t.Fatalf("state = %s after a cancelled probe, want half-open", h.state())
+	}
+	if err := h.call(context.Background(), nil); err != nil || h.state() != BreakerClosed {
+		t.Errorf("err = %v, state %s; want the next probe admitted and the breaker closed", err, h.state())
+	}
+}
+
+func TestBreakerConfigFromEnv(t *testing.T) {
+	t.Setenv("GOLLM_BREAKER_WINDOW", "20s")
+	t.Setenv("GOLLM_BREAKER_COOLDOWN", "1m")
+	t.Setenv("GOLLM_BREAKER_MIN_REQUESTS", "8")
+	t.Setenv("GOLLM_BREAKER_THRESHOLD", "0.25")
+	want := BreakerConfig{Window: 20 * time.Second, MinRequests: 8, Threshold: 0.25, Cooldown: time.Minute}
+	if got := breakerConfigFromEnv(); got != want {
+		t.Errorf("config = %+v, want %+v", got, want)
+	}
+
+	// 非法值保留默认
+	t.Setenv("GOLLM_BREAKER_WINDOW", "5ns") // 小于桶数
+	t.Setenv("GOLLM_BREAKER_COOLDOWN", "soon")
+	t.Setenv("GOLLM_BREAKER_MIN_REQUESTS", "0")
+	t.Setenv("GOLLM_BREAKER_THRESHOLD", "1.5")
+	if got := breakerConfigFromEnv(); got != defaultBreakerConfig {
+		t.Errorf("config = %+v, want defaults %+v", got, defaultBreakerConfig)
+	}
+}
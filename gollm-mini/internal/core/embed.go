@@ -0,0 +1,59 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"gollm-mini/internal/helper"
+	"gollm-mini/internal/monitor"
+	"gollm-mini/internal/provider"
+	"gollm-mini/internal/types"
+)
+
+// Embed 调用 Provider 的 Embedding 接口（若实现），返回与 inputs 等长的向量
+func (l *LLM) Embed(ctx context.Context, inputs []string) ([][]float32, types.Usage, error) {
+	em, ok := provider.As[provider.Embedder](l.p)
+	if !ok {
+		return nil, types.Usage{}, &provider.Error{Kind: provider.ErrInvalidRequest, Provider: l.name,
+			Err: fmt.Errorf("provider %s does not support embeddings", l.name)}
+	}
+	if len(inputs) == 0 {
+		return nil, types.Usage{}, fmt.Errorf("no input to embed")
+	}
+
+	start := time.Now()
+	var (
+		vecs  [][]float32
+		usage types.Usage
+	)
+	est := 0 // 限流预留
+	for _, s := range inputs {
+		est += l.tok.Count(s)
+	}
+	err := Retry(ctx, 3, 300*time.Millisecond, l.guard(ctx, l.throttle(ctx, est, &usage, func() error {
+		var e error
+		vecs, usage, e = em.Embed(ctx, inputs)
+		return e
+	})))
+	if err == nil && len(vecs) != len(inputs) {
+		err = fmt.Errorf("provider %s returned %d embeddings for %d inputs", l.name, len(vecs), len(inputs))
+	}
+
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	dur := time.Since(start)
+	monitor.Latency.WithLabelValues(l.name, "embed", status).Observe(dur.Seconds())
+	monitor.EmbeddingTokens.WithLabelValues(l.name, l.model).Add(float64(usage.PromptTokens))
+
+	cost := helper.CalcCost(l.name, l.model, usage.PromptTokens, 0)
+	if cost > 0 {
+		monitor.EmbeddingCost.WithLabelValues(l.name, l.model).Add(cost)
+	}
+	log.Printf("[LLM] provider=%s model=%s endpoint=embed inputs=%d tokens=%d latency=%s cost=$%.6f",
+		l.name, l.model, len(inputs), usage.PromptTokens, dur, cost)
+	return vecs, usage, err
+}
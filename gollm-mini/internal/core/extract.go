@@ -0,0 +1,28 @@
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"gollm-mini/internal/schema"
+	"gollm-mini/internal/types"
+)
+
+// Extract 由 T 反射生成 JSON Schema（见 schema.Reflect 支持的标签），按 StructuredGenerateSchema 生成、校验，
+// 并把结果解码为 T。T 通常是结构体：
+//
+//	type Ticket struct {
+//		Title    string   `json:"title" description:"一句话概括"`
+//		Priority string   `json:"priority" enum:"low,medium,high"`
+//		Tags     []string `json:"tags,omitempty"`
+//	}
+//	t, rep, err := core.Extract[Ticket](ctx, llm, msgs)
+func Extract[T any](ctx context.Context, llm *LLM, msgs []types.Message) (T, StructuredReport, error) {
+	var out T
+	sc, err := schema.Of[T]()
+	if err != nil {
+		return out, StructuredReport{}, fmt.Errorf("extract: %w", err)
+	}
+	rep, err := llm.StructuredGenerateSchema(ctx, msgs, sc, &out)
+	return out, rep, err
+}
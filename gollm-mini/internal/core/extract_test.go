@@ -0,0 +1,53 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"gollm-mini/internal/provider"
+)
+
+type city struct {
+	Name      string   `json:"name" description:"city name"`
+	Country   string   `json:"country"`
+	Continent string   `json:"continent" enum:"Asia,Europe,Africa,Americas,Oceania"`
+	Landmarks []string `json:"landmarks,omitempty"`
+}
+
+func TestExtract(t *testing.T) {
+	m := &schemaModel{native: true, replies: []string{
+		`{"name":"Tokyo","country":"Japan","continent":"Eurasia"}`, // enum 不匹配，触发修复轮次
+		`{"name":"Tokyo","country":"Japan","continent":"Asia","landmarks":["Tokyo Tower"]}`,
+	}}
+	provider.Register("extract-fake", func(string) (provider.Provider, error) { return m, nil })
+	llm, err := New("extract-fake", "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, rep, err := Extract[city](context.Background(), llm, prompt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "Tokyo" || got.Continent != "Asia" || len(got.Landmarks) != 1 {
+		t.Errorf("got %+v", got)
+	}
+	if rep.Attempts != 2 || rep.History[0].Result != "schema_mismatch" {
+		t.Errorf("report = %+v", rep)
+	}
+	if s := string(m.seen[0]); !strings.Contains(s, `"enum":["Asia","Europe"`) || !strings.Contains(s, `"required":["name","country","continent"]`) {
+		t.Errorf("schema sent = %s", s)
+	}
+}
+
+func TestExtractUnsupportedType(t *testing.T) {
+	provider.Register("extract-none", func(string) (provider.Provider, error) { return &schemaModel{}, nil })
+	llm, err := New("extract-none", "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Extract[map[int]string](context.Background(), llm, prompt); err == nil {
+		t.Fatal("expected schema error")
+	}
+}
@@ -2,12 +2,15 @@ package core
 
 import (
 	"context"
+	"errors"
 	"gollm-mini/internal/helper"
 	"gollm-mini/internal/monitor"
 	"log"
+	"strings"
 	"time"
 
 	"gollm-mini/internal/provider"
+	"gollm-mini/internal/tokenizer"
 	"gollm-mini/internal/types"
 )
 
@@ -19,28 +22,75 @@ type LLM struct {
 	name  string
 	model string
 	p     provider.Provider
+	opts  types.GenerateOptions // 采样参数，透传给 Provider
+	tok   tokenizer.Tokenizer   // 截断 / 计数用
+
+	tools     map[string]registeredTool // RunTools 使用的 Go 工具
+	toolOrder []string
+
+	fallbacks []*LLM // 备用目标，按顺序尝试（见 NewChain）
+}
+
+// Result 一次调用的结果。Served 随结果返回而不记在 LLM 上，同一实例可被并发调用
+type Result struct {
+	Text   string // 完整回答；Stream 时为已通过回调发出的内容
+	Usage  types.Usage
+	Served Target // 实际完成请求（或最后尝试）的目标
 }
 
+// Cost 按实际服务目标的价格估算本次调用的费用（USD）
+func (r Result) Cost() float64 { return r.Served.Cost(r.Usage) }
+
 func (l *LLM) Provider() string { return l.name }
 
 func (l *LLM) Model() string { return l.model }
 
-// New 创建一个 LLM 实例并注入模型名（若 Provider 支持）
+// Tokenizer 与当前模型匹配的 tokenizer（截断、历史裁剪、用量估算）
+func (l *LLM) Tokenizer() tokenizer.Tokenizer { return l.tok }
+
+// New 通过 Provider 工厂创建一个绑定模型的独立实例，实例之间互不影响
 func New(providerName, model string) (*LLM, error) {
-	p, err := provider.Get(providerName)
+	p, err := provider.Get(providerName, model)
 	if err != nil {
 		return nil, err
 	}
-	if ms, ok := p.(provider.ModelSetter); ok && model != "" {
-		ms.SetModel(model)
+	// 空模型由工厂补全为默认模型；记录实际模型，保证计费、指标、熔断 / 限流的 key 与显式指定时一致
+	if m, ok := provider.As[provider.Modeler](p); ok && m.Model() != "" {
+		model = m.Model()
 	}
-	return &LLM{name: providerName, model: model, p: p}, nil
+	return &LLM{name: providerName, model: model, p: p, tok: tokenizer.ForModel(providerName, model)}, nil
+}
+
+// SetOptions 设置后续调用使用的采样参数
+func (l *LLM) SetOptions(opts types.GenerateOptions) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	l.opts = opts
+	for _, f := range l.fallbacks {
+		f.opts = opts
+	}
+	return nil
+}
+
+// Generate 调用底层 Provider 的生成接口；失败且可重试时依次尝试备用目标
+func (l *LLM) Generate(ctx context.Context, messages []types.Message) (Result, error) {
+	var (
+		res Result
+		err error
+	)
+	res.Served, err = l.route(ctx, "generate", func(c *LLM) error {
+		var e error
+		res.Text, res.Usage, e = c.generate(ctx, messages, c.opts)
+		return e
+	})
+	return res, err
 }
 
-// Generate 调用底层 Provider 的生成接口，并打印日志
-func (l *LLM) Generate(ctx context.Context, messages []types.Message) (string, types.Usage, error) {
+// generate 在单个目标上以 opts 调用（含重试），并打印日志
+func (l *LLM) generate(ctx context.Context, messages []types.Message, opts types.GenerateOptions) (string, types.Usage, error) {
 	//Memory截断
-	clipped := helper.TruncateMessages(messages, maxCtx)
+	clipped := helper.TruncateMessagesFor(l.tok, messages, maxCtx)
 
 	//尝试命中缓存
 	//cacheKey := cache.KeyFromMessages(l.name, l.model, clipped)
@@ -58,28 +108,13 @@ func (l *LLM) Generate(ctx context.Context, messages []types.Message) (string, t
 		err   error
 	)
 
-	err = Retry(ctx, 3, 300*time.Millisecond, func() error {
+	est := tokenizer.CountMessages(l.tok, clipped) // 限流预留
+	err = Retry(ctx, 3, 300*time.Millisecond, l.guard(ctx, l.throttle(ctx, est, &usage, func() error {
 		var e error
-		txt, usage, e = l.p.Generate(ctx, clipped)
+		txt, usage, e = l.p.Generate(ctx, clipped, opts)
 		return e
-	})
-	dur := time.Since(start)
-
-	//Prometheus
-	status := "ok"
-	if err != nil {
-		status = "error"
-	}
-	monitor.Latency.WithLabelValues(l.name, "generate", status).Observe(dur.Seconds())
-	monitor.Tokens.WithLabelValues(l.name, "prompt").Add(float64(usage.PromptTokens))
-	monitor.Tokens.WithLabelValues(l.name, "completion").Add(float64(usage.CompletionTokens))
-
-	cost := helper.CalcCost(l.name, l.name, usage.PromptTokens, usage.CompletionTokens)
-	if cost > 0 {
-		monitor.CostUSD.WithLabelValues(l.name, l.name).Add(cost)
-	}
-	log.Printf("[LLM] provider=%s prompt=%d completion=%d total=%d latency=%s cost=$%.4f",
-		l.name, usage.PromptTokens, usage.CompletionTokens, usage.Total(), dur, cost)
+	})))
+	l.observe("generate", start, usage, err)
 
 	//if err == nil {
 	//	cache.Put(cacheKey, cache.Value{Text: txt, Usage: usage})
@@ -90,36 +125,102 @@ func (l *LLM) Generate(ctx context.Context, messages []types.Message) (string, t
 	return txt, usage, err
 }
 
-// Stream 调用底层 Provider 的流式接口（若实现）
-func (l *LLM) Stream(ctx context.Context, messages []types.Message, cb func(types.Chunk)) (types.Usage, error) {
+// Stream 调用底层 Provider 的流式接口（若实现）。
+// 只有在尚未发出任何 chunk 时才会切换到备用目标，避免客户端收到两段拼接的回答。
+func (l *LLM) Stream(ctx context.Context, messages []types.Message, cb func(types.Chunk)) (Result, error) {
+	var (
+		res  Result
+		err  error
+		text strings.Builder
+		sent bool
+	)
+	wrapped := func(ch types.Chunk) {
+		sent = true
+		text.WriteString(ch.Content)
+		cb(ch)
+	}
+	res.Served, err = l.route(ctx, "stream", func(c *LLM) error {
+		var e error
+		res.Usage, e = c.stream(ctx, messages, c.opts, wrapped)
+		if e != nil && sent {
+			return &RetryStop{e}
+		}
+		return e
+	})
+	var rs *RetryStop
+	if errors.As(err, &rs) {
+		err = rs.error
+	}
+	res.Text = text.String()
+	return res, err
+}
+
+// stream 在单个目标上以 opts 流式调用；已发出 chunk 后不再重试
+func (l *LLM) stream(ctx context.Context, messages []types.Message, opts types.GenerateOptions, cb func(types.Chunk)) (types.Usage, error) {
 	//Memory截断
-	clipped := helper.TruncateMessages(messages, maxCtx)
+	clipped := helper.TruncateMessagesFor(l.tok, messages, maxCtx)
 
 	ps, streamed := l.p.(interface {
-		Stream(context.Context, []types.Message, func(types.Chunk)) (types.Usage, error)
+		Stream(context.Context, []types.Message, types.GenerateOptions, func(types.Chunk)) (types.Usage, error)
 	})
 
 	var (
 		usage types.Usage
 		err   error
 	)
+	start := time.Now()
 	// 若 Provider 不支持流式，降级为一次性调用
 
-	err = Retry(ctx, 3, 300*time.Millisecond, func() error {
+	var sent bool
+	onChunk := func(ch types.Chunk) {
+		sent = true
+		cb(ch)
+	}
+	est := tokenizer.CountMessages(l.tok, clipped) // 限流预留
+	err = Retry(ctx, 3, 300*time.Millisecond, l.guard(ctx, l.throttle(ctx, est, &usage, func() error {
 		if streamed {
-			usage, err = ps.Stream(ctx, clipped, cb)
+			usage, err = ps.Stream(ctx, clipped, opts, onChunk)
+			if err != nil && sent {
+				return &RetryStop{err}
+			}
 			return err
 		}
 		var txt string
-		txt, usage, err = l.p.Generate(ctx, clipped)
+		txt, usage, err = l.p.Generate(ctx, clipped, opts)
 		if err == nil {
-			cb(types.Chunk{Content: txt, Delta: usage.CompletionTokens})
+			onChunk(types.Chunk{Content: txt, Delta: usage.CompletionTokens})
 		}
 		return err
-	})
+	})))
+	var rs *RetryStop
+	if errors.As(err, &rs) {
+		err = rs.error
+	}
+	l.observe("stream", start, usage, err)
 
 	if c, ok := l.p.(interface{ Close() error }); ok {
 		_ = c.Close()
 	}
 	return usage, err
 }
+
+// observe 记录 Prometheus 指标与日志
+func (l *LLM) observe(endpoint string, start time.Time, usage types.Usage, err error) {
+	dur := time.Since(start)
+
+	//Prometheus
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	monitor.Latency.WithLabelValues(l.name, endpoint, status).Observe(dur.Seconds())
+	monitor.Tokens.WithLabelValues(l.name, "prompt").Add(float64(usage.PromptTokens))
+	monitor.Tokens.WithLabelValues(l.name, "completion").Add(float64(usage.CompletionTokens))
+
+	cost := helper.CalcCost(l.name, l.model, usage.PromptTokens, usage.CompletionTokens)
+	if cost > 0 {
+		monitor.CostUSD.WithLabelValues(l.name, l.model).Add(cost)
+	}
+	log.Printf("[LLM] provider=%s model=%s endpoint=%s prompt=%d completion=%d total=%d latency=%s cost=$%.4f",
+		l.name, l.model, endpoint, usage.PromptTokens, usage.CompletionTokens, usage.Total(), dur, cost)
+}
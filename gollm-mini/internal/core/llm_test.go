@@ -0,0 +1,145 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+
+	"gollm-mini/internal/helper"
+	"gollm-mini/internal/provider"
+	"gollm-mini/internal/types"
+)
+
+// echoModel 是只回显自身模型名的假 Provider
+type echoModel struct{ model string }
+
+func (e *echoModel) Generate(_ context.Context, _ []types.Message, _ types.GenerateOptions) (string, types.Usage, error) {
+	runtime.Gosched() // 放大调度交错，便于 -race 暴露问题
+	return e.model, types.Usage{PromptTokens: 1, CompletionTokens: 1}, nil
+}
+
+func (e *echoModel) Stream(_ context.Context, _ []types.Message, _ types.GenerateOptions, cb func(types.Chunk)) (types.Usage, error) {
+	for _, r := range e.model {
+		runtime.Gosched()
+		cb(types.Chunk{Content: string(r), Delta: 1})
+	}
+	return types.Usage{CompletionTokens: len(e.model)}, nil
+}
+
+func (e *echoModel) Model() string { return e.model }
+
+func init() {
+	provider.Register("echo", provider.Simple("echo-default", func(m string) *echoModel {
+		return &echoModel{model: m}
+	}))
+}
+
+var prompt = []types.Message{{Role: types.RoleUser, Content: "which model are you?"}}
+
+func TestConcurrentModelsIsolated(t *testing.T) {
+	const workers = 64
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			want := fmt.Sprintf("model-%d", i%4)
+
+			llm, err := New("echo", want)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			res, err := llm.Generate(context.Background(), prompt)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if res.Text != want {
+				t.Errorf("Generate: got model %q, want %q", res.Text, want)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestConcurrentStreamsIsolated(t *testing.T) {
+	const workers = 32
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			want := fmt.Sprintf("stream-model-%d", i%3)
+
+			llm, err := New("echo", want)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			var got string
+			if _, err := llm.Stream(context.Background(), prompt, func(ch types.Chunk) {
+				got += ch.Content
+			}); err != nil {
+				t.Error(err)
+				return
+			}
+			if got != want {
+				t.Errorf("Stream: got model %q, want %q", got, want)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestDefaultModel(t *testing.T) {
+	llm, err := New("echo", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := llm.Generate(context.Background(), prompt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Text != "echo-default" || res.Served != llm.Target() {
+		t.Errorf("got %q from %v, want factory default model", res.Text, res.Served)
+	}
+	if llm.Model() != "echo-default" {
+		t.Errorf("Model() = %q, want resolved default model", llm.Model())
+	}
+
+	// 计费按实际模型查价：默认模型与显式指定结果一致
+	helper.SetPrice("echo", "echo-default", 1, 2)
+	explicit, err := New("echo", "echo-default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := types.Usage{PromptTokens: 1000, CompletionTokens: 1000}
+	if llm.Target().Cost(u) != 3 || explicit.Target().Cost(u) != llm.Target().Cost(u) {
+		t.Errorf("Cost = %v (explicit %v), want 3", llm.Target().Cost(u), explicit.Target().Cost(u))
+	}
+	if llm.Target() != explicit.Target() {
+		t.Errorf("Target = %v, explicit %v", llm.Target(), explicit.Target())
+	}
+}
+
+func TestRegisterToolReplace(t *testing.T) {
+	llm, err := New("echo", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	def := types.Tool{Name: "now"}
+	llm.RegisterTool(def, func(context.Context, json.RawMessage) (string, error) { return "old", nil })
+	llm.RegisterTool(types.Tool{Name: "other"}, func(context.Context, json.RawMessage) (string, error) { return "", nil })
+	llm.RegisterTool(def, func(context.Context, json.RawMessage) (string, error) { return "new", nil })
+
+	if len(llm.toolOrder) != 2 || llm.toolOrder[0] != "now" {
+		t.Errorf("toolOrder = %v, want [now other]", llm.toolOrder)
+	}
+	if out := llm.invokeTool(context.Background(), types.ToolCall{Name: "now"}); out != "new" {
+		t.Errorf("invokeTool = %q, want the replacement handler", out)
+	}
+}
@@ -0,0 +1,194 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"gollm-mini/internal/monitor"
+	"gollm-mini/internal/types"
+)
+
+// RateLimit 每分钟请求数 / token 数上限；0 表示不限制该维度
+type RateLimit struct {
+	RPM int `json:"rpm"`
+	TPM int `json:"tpm"`
+}
+
+// tokenBucket 令牌桶：容量为一分钟的额度，按每秒 limit/60 匀速补充
+type tokenBucket struct {
+	capacity float64
+	rate     float64 // 每秒补充量
+	tokens   float64 // 可为负：实际用量超出预留时记为欠账
+	last     time.Time
+}
+
+func newTokenBucket(perMinute int) *tokenBucket {
+	if perMinute <= 0 {
+		return nil
+	}
+	c := float64(perMinute)
+	return &tokenBucket{capacity: c, rate: c / 60, tokens: c, last: time.Now()}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens = math.Min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// wait 取得 n 个令牌还需等待的时间
+func (b *tokenBucket) wait(n float64) time.Duration {
+	if b == nil || b.tokens >= n {
+		return 0
+	}
+	return time.Duration((n - b.tokens) / b.rate * float64(time.Second))
+}
+
+// limiter 一个限流 key（provider 或 provider:model）的 RPM + TPM 桶
+type limiter struct {
+	mu  sync.Mutex
+	key string
+	rpm *tokenBucket
+	tpm *tokenBucket
+}
+
+var limiters = struct {
+	sync.Mutex
+	m    map[string]*limiter
+	once sync.Once
+}{m: map[string]*limiter{}}
+
+// SetRateLimit 为 "provider" 或 "provider:model" 设置限流（覆盖 GOLLM_RATE_LIMITS 中的同名配置）。
+// provider 级别的限额由该 provider 下所有模型共享。
+func SetRateLimit(key string, rl RateLimit) {
+	limiters.once.Do(loadRateLimits)
+	limiters.Lock()
+	defer limiters.Unlock()
+	limiters.m[key] = &limiter{key: key, rpm: newTokenBucket(rl.RPM), tpm: newTokenBucket(rl.TPM)}
+}
+
+// loadRateLimits 读取 GOLLM_RATE_LIMITS，例如
+// {"openai": {"rpm": 500, "tpm": 200000}, "openai:gpt-4o": {"rpm": 100, "tpm": 30000}}
+func loadRateLimits() {
+	for k, rl := range parseRateLimits(os.Getenv("GOLLM_RATE_LIMITS")) {
+		limiters.m[k] = &limiter{key: k, rpm: newTokenBucket(rl.RPM), tpm: newTokenBucket(rl.TPM)}
+	}
+}
+
+// parseRateLimits 逐项解析；格式错误或为负数的项打印警告后跳过，不影响其余项
+func parseRateLimits(v string) map[string]RateLimit {
+	if v == "" {
+		return nil
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(v), &raw); err != nil {
+		log.Printf("[WARN] invalid GOLLM_RATE_LIMITS: %v", err)
+		return nil
+	}
+	out := make(map[string]RateLimit, len(raw))
+	for k, item := range raw {
+		var rl RateLimit
+		dec := json.NewDecoder(bytes.NewReader(item))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&rl); err != nil {
+			log.Printf("[WARN] invalid GOLLM_RATE_LIMITS entry %q: %v", k, err)
+			continue
+		}
+		if k == "" || rl.RPM < 0 || rl.TPM < 0 {
+			log.Printf("[WARN] invalid GOLLM_RATE_LIMITS entry %q: %s", k, item)
+			continue
+		}
+		out[k] = rl
+	}
+	return out
+}
+
+// limiterFor 先找 provider:model，再找 provider；都没有返回 nil（不限流）
+func limiterFor(t Target) *limiter {
+	limiters.once.Do(loadRateLimits)
+	limiters.Lock()
+	defer limiters.Unlock()
+	if l, ok := limiters.m[t.String()]; ok {
+		return l
+	}
+	return limiters.m[t.Provider]
+}
+
+// reserve 阻塞直到同时拿到 1 个请求额度和 tokens 个 token 额度，或 ctx 结束；返回实际预留的 token 数
+func (l *limiter) reserve(ctx context.Context, tokens int) (float64, error) {
+	n := float64(tokens)
+	if l.tpm != nil && n > l.tpm.capacity {
+		n = l.tpm.capacity // 单个请求超过每分钟额度时按满桶处理，避免永远等待
+	}
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		var d time.Duration
+		if l.rpm != nil {
+			l.rpm.refill(now)
+			d = l.rpm.wait(1)
+		}
+		if l.tpm != nil {
+			l.tpm.refill(now)
+			d = max(d, l.tpm.wait(n))
+		}
+		if d == 0 {
+			if l.rpm != nil {
+				l.rpm.tokens--
+			}
+			if l.tpm != nil {
+				l.tpm.tokens -= n
+			}
+			l.mu.Unlock()
+			return n, nil
+		}
+		l.mu.Unlock()
+
+		t := time.NewTimer(d)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return 0, ctx.Err()
+		}
+	}
+}
+
+// reconcile 用实际用量修正预留：多退少补（失败且没有用量时 actual=0，即全额退回）
+func (l *limiter) reconcile(reserved float64, actual int) {
+	if l.tpm == nil {
+		return
+	}
+	l.mu.Lock()
+	l.tpm.tokens = math.Min(l.tpm.capacity, l.tpm.tokens+reserved-float64(actual))
+	l.mu.Unlock()
+}
+
+// throttle 包装单次尝试：调用前按估算 prompt token 预留额度，调用后按 usage 对账
+func (l *LLM) throttle(ctx context.Context, estimate int, usage *types.Usage, fn func() error) func() error {
+	lim := limiterFor(l.Target())
+	if lim == nil {
+		return fn
+	}
+	return func() error {
+		start := time.Now()
+		reserved, err := lim.reserve(ctx, estimate)
+		if err != nil {
+			return err
+		}
+		if waited := time.Since(start); waited > time.Millisecond {
+			log.Printf("[RATELIMIT] %s waited %s (limit %s)", l.Target(), waited, lim.key)
+		}
+		monitor.RateLimitWait.WithLabelValues(l.name, l.model).Observe(time.Since(start).Seconds())
+
+		*usage = types.Usage{}
+		err = fn()
+		lim.reconcile(reserved, usage.Total())
+		return err
+	}
+}
@@ -0,0 +1,195 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"gollm-mini/internal/monitor"
+	"gollm-mini/internal/types"
+)
+
+// newLimiter 直接构造 limiter；TPM 6000 即每 10ms 补充 1 个 token
+func newLimiter(rl RateLimit) *limiter {
+	return &limiter{key: "test", rpm: newTokenBucket(rl.RPM), tpm: newTokenBucket(rl.TPM)}
+}
+
+func (l *limiter) tpmTokens() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.tpm.tokens
+}
+
+func near(got, want float64) bool { return math.Abs(got-want) < 2 }
+
+func TestReserveBlocksUntilRefill(t *testing.T) {
+	l := newLimiter(RateLimit{TPM: 6000})
+	if _, err := l.reserve(context.Background(), 6000); err != nil { // 用完整桶
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	got, err := l.reserve(context.Background(), 10) // 需要补充 100ms
+	if err != nil || got != 10 {
+		t.Fatalf("reserve = %v, %v", got, err)
+	}
+	if d := time.Since(start); d < 90*time.Millisecond {
+		t.Errorf("reserve returned after %v, want to wait for the refill", d)
+	}
+
+	// 超过每分钟额度的请求按满桶预留，不会永远等待
+	l = newLimiter(RateLimit{TPM: 6000})
+	if got, err := l.reserve(context.Background(), 1_000_000); err != nil || got != 6000 {
+		t.Errorf("oversized reserve = %v, %v; want capped at capacity", got, err)
+	}
+
+	// RPM 同样阻塞：600 RPM 即每 100ms 一个请求
+	l = newLimiter(RateLimit{RPM: 600})
+	l.rpm.tokens = 0
+	start = time.Now()
+	if _, err := l.reserve(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 90*time.Millisecond {
+		t.Errorf("rpm reserve returned after %v", d)
+	}
+}
+
+func TestReserveCancel(t *testing.T) {
+	l := newLimiter(RateLimit{RPM: 60, TPM: 6000})
+	if _, err := l.reserve(context.Background(), 6000); err != nil {
+		t.Fatal(err)
+	}
+	rpmBefore := l.rpm.tokens
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := l.reserve(ctx, 3000) // 需要等 30s
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want the context error", err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("cancelled reserve returned after %v", d)
+	}
+
+	// 没有扣除任何额度：等待期间只有补充
+	if tok := l.tpmTokens(); tok < 0 || tok > 10 {
+		t.Errorf("tpm tokens = %v after cancel, want only the refill", tok)
+	}
+	l.mu.Lock()
+	rpmAfter := l.rpm.tokens
+	l.mu.Unlock()
+	if rpmAfter < rpmBefore {
+		t.Errorf("rpm tokens %v -> %v, want no request consumed", rpmBefore, rpmAfter)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	cases := []struct {
+		name     string
+		reserved float64
+		actual   int
+		want     float64 // 对账后的 token 余额（初始满桶 6000，预留后为 6000-reserved）
+	}{
+		{"refund over-estimate", 100, 40, 5960},
+		{"debit under-estimate", 100, 150, 5850},
+		{"failure refunds in full", 100, 0, 6000},
+		{"never above capacity", 0, 0, 6000},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := newLimiter(RateLimit{TPM: 6000})
+			l.tpm.tokens -= tc.reserved
+			l.reconcile(tc.reserved, tc.actual)
+			if got := l.tpmTokens(); !near(got, tc.want) {
+				t.Errorf("tokens = %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	// 欠账可为负，之后的预留需要等待补齐
+	l := newLimiter(RateLimit{TPM: 6000})
+	l.reconcile(0, 6100)
+	if got := l.tpmTokens(); !near(got, -100) {
+		t.Errorf("tokens = %v, want a 100-token debt", got)
+	}
+
+	// 只有 RPM 时对账是空操作
+	newLimiter(RateLimit{RPM: 10}).reconcile(100, 0)
+}
+
+func TestParseRateLimits(t *testing.T) {
+	cases := []struct {
+		name string
+		env  string
+		want map[string]RateLimit
+	}{
+		{"empty", "", nil},
+		{"valid", `{"openai": {"rpm": 500, "tpm": 200000}, "openai:gpt-4o": {"tpm": 30000}}`,
+			map[string]RateLimit{"openai": {RPM: 500, TPM: 200000}, "openai:gpt-4o": {TPM: 30000}}},
+		{"not json", `openai=500`, nil},
+		{"not an object", `[1, 2]`, nil},
+		{"bad entries are skipped", `{"ok": {"rpm": 1}, "str": "fast", "neg": {"tpm": -5}, "typo": {"rpms": 3}, "float": {"rpm": 1.5}, "": {"rpm": 2}}`,
+			map[string]RateLimit{"ok": {RPM: 1}}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := parseRateLimits(tc.env)
+			if len(got) == 0 && len(tc.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parseRateLimits(%s) = %v, want %v", tc.env, got, tc.want)
+			}
+		})
+	}
+}
+
+func waitSamples(t *testing.T, provider, model string) uint64 {
+	t.Helper()
+	var m dto.Metric
+	if err := monitor.RateLimitWait.WithLabelValues(provider, model).(prometheus.Histogram).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestThrottle(t *testing.T) {
+	llm := &LLM{name: "ratelimit-stub", model: t.Name(), p: &stubModel{}}
+	SetRateLimit(llm.Target().String(), RateLimit{RPM: 60, TPM: 6000})
+	lim := limiterFor(llm.Target())
+
+	var usage types.Usage
+	before := waitSamples(t, llm.name, llm.model)
+	err := llm.throttle(context.Background(), 100, &usage, func() error {
+		if got := lim.tpmTokens(); !near(got, 5900) {
+			t.Errorf("tokens during the call = %v, want the 100-token estimate reserved", got)
+		}
+		usage = types.Usage{PromptTokens: 120, CompletionTokens: 30}
+		return nil
+	})()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := lim.tpmTokens(); !near(got, 5850) {
+		t.Errorf("tokens after the call = %v, want the actual 150 tokens debited", got)
+	}
+	if got := waitSamples(t, llm.name, llm.model); got != before+1 {
+		t.Errorf("RateLimitWait samples = %d, want %d", got, before+1)
+	}
+
+	// 没有限流配置的目标不包装
+	free := &LLM{name: "ratelimit-free", model: t.Name()}
+	calls := 0
+	_ = free.throttle(context.Background(), 100, &usage, func() error { calls++; return nil })()
+	if calls != 1 || waitSamples(t, free.name, free.model) != 0 {
+		t.Errorf("unlimited target: calls %d, wait observed", calls)
+	}
+}
@@ -0,0 +1,166 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"gollm-mini/internal/provider/replay"
+	"gollm-mini/internal/types"
+
+	_ "gollm-mini/internal/provider/ollama"
+	_ "gollm-mini/internal/provider/openai"
+)
+
+// 默认回放仓库根目录的 testdata/cassettes（core / server / optimizer 共用）；
+// GOLLM_REPLAY=record go test 可对真实后端重新录制
+func TestMain(m *testing.M) {
+	mode := replay.ModeReplay
+	if v := os.Getenv("GOLLM_REPLAY"); v != "" {
+		var err error
+		if mode, err = replay.ParseMode(v); err != nil {
+			panic(err)
+		}
+	}
+	if err := replay.Install("../../testdata/cassettes", mode, "ollama", "openai"); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func user(s string) []types.Message {
+	return []types.Message{{Role: types.RoleUser, Content: s}}
+}
+
+func TestReplayGenerate(t *testing.T) {
+	llm, err := New("ollama", "llama3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := llm.Generate(context.Background(), user("What is the capital of France? Answer in one sentence."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(res.Text, "Paris") {
+		t.Errorf("text = %q, want mention of Paris", res.Text)
+	}
+	if res.Usage.PromptTokens == 0 || res.Usage.CompletionTokens == 0 {
+		t.Errorf("usage = %+v, want both counts", res.Usage)
+	}
+	if s := res.Served; s != (Target{"ollama", "llama3"}) {
+		t.Errorf("served = %v", s)
+	}
+}
+
+func TestReplayStream(t *testing.T) {
+	llm, err := New("ollama", "llama3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chunks []string
+	res, err := llm.Stream(context.Background(), user("What is the capital of France? Answer in one sentence."), func(ch types.Chunk) {
+		chunks = append(chunks, ch.Content)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) < 2 {
+		t.Fatalf("got %d chunks, want a streamed answer", len(chunks))
+	}
+	if got := strings.Join(chunks, ""); !strings.Contains(got, "Paris") {
+		t.Errorf("stream = %q", got)
+	}
+	if res.Usage.CompletionTokens == 0 {
+		t.Errorf("usage = %+v", res.Usage)
+	}
+}
+
+func TestReplayStructured(t *testing.T) {
+	llm, err := New("ollama", "llama3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var city struct {
+		Name       string `json:"name"`
+		Country    string `json:"country"`
+		Population int    `json:"population"`
+	}
+	_, err = llm.StructuredGenerate(context.Background(),
+		user("Describe the city of Tokyo as JSON with name, country and population."),
+		"testdata/city.schema.json", &city)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if city.Name != "Tokyo" || city.Country != "Japan" || city.Population == 0 {
+		t.Errorf("city = %+v", city)
+	}
+}
+
+func TestReplayFallback(t *testing.T) {
+	// openai 的 cassette 录的是 401：不重试，直接切到 ollama
+	llm, err := NewChain(Target{"openai", "gpt-4o-mini"}, Target{"ollama", "llama3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := llm.Generate(context.Background(), user("Say hello."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Text == "" {
+		t.Error("empty answer from fallback")
+	}
+	if s := res.Served; s.Provider != "ollama" {
+		t.Errorf("served = %v, want fallback to ollama", s)
+	}
+}
+
+func TestReplayRunTools(t *testing.T) {
+	llm, err := New("openai", "gpt-4o-mini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var asked string
+	llm.RegisterTool(types.Tool{
+		Name:        "get_weather",
+		Description: "Current weather for a city",
+		Parameters:  json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}`),
+	}, func(_ context.Context, args json.RawMessage) (string, error) {
+		var a struct{ City string }
+		if err := json.Unmarshal(args, &a); err != nil {
+			return "", err
+		}
+		asked = a.City
+		return `{"city":"Paris","temp_c":18,"sky":"sunny"}`, nil
+	})
+
+	res, convo, err := llm.RunTools(context.Background(), user("What's the weather in Paris right now?"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asked != "Paris" {
+		t.Errorf("tool called with city %q", asked)
+	}
+	if !strings.Contains(res.Text, "18") {
+		t.Errorf("final = %q, want the tool result", res.Text)
+	}
+	if len(convo) != 4 { // user → assistant(tool_calls) → tool → assistant
+		t.Errorf("conversation has %d messages, want 4", len(convo))
+	}
+}
+
+func TestReplayMissingCassette(t *testing.T) {
+	if os.Getenv("GOLLM_REPLAY") != "" {
+		t.Skip("only meaningful when replaying")
+	}
+	llm, err := New("ollama", "llama3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = llm.Generate(context.Background(), user("a prompt nobody recorded"))
+	if !errors.Is(err, replay.ErrNoCassette) {
+		t.Fatalf("err = %v, want ErrNoCassette", err)
+	}
+}
@@ -3,30 +3,71 @@ package core
 import (
 	"context"
 	"errors"
+	"math/rand/v2"
 	"time"
+
+	"gollm-mini/internal/provider"
 )
 
+// maxRetryWait 单次等待上限；服务端要求等待更久时直接返回，交给 fallback / 调用方处理
+const maxRetryWait = 20 * time.Second
+
+// Retry 按错误分类决定是否重试：鉴权失败、上下文过长、模型不存在、参数错误直接返回；
+// 限流 / 过载 / 超时 / 网络错误按指数退避 + 抖动重试，服务端给出 Retry-After 时至少等待该时长；
+// 未分类的错误不重试。
 func Retry(ctx context.Context, tries int, base time.Duration, fn func() error) error {
 	var err error
 	for i := 0; i < tries; i++ {
 		if err = fn(); err == nil {
 			return nil
 		}
-		// 不可重试 error 直接返回
-		var re *RetryStop
-		if errors.As(err, &re) {
+		if !retryable(err) || i == tries-1 {
 			return err
 		}
-		sleep := base * (1 << i) // 1x,2x,4x…
+
+		sleep := backoff(base, i)
+		if ra := provider.RetryAfter(err); ra > sleep {
+			sleep = ra
+		}
+		if sleep > maxRetryWait {
+			return err
+		}
+		if dl, ok := ctx.Deadline(); ok && time.Until(dl) < sleep {
+			return err // 等完也来不及
+		}
+		t := time.NewTimer(sleep)
 		select {
-		case <-time.After(sleep):
+		case <-t.C:
 		case <-ctx.Done():
+			t.Stop()
 			return ctx.Err()
 		}
 	}
 	return err
 }
 
+// retryable 不可重试：调用方取消、Provider 主动标记、熔断中、以及 provider 判定的永久性错误
+func retryable(err error) bool {
+	var (
+		re *RetryStop
+		co *CircuitOpenError
+	)
+	if errors.As(err, &re) || errors.As(err, &co) {
+		return false
+	}
+	return provider.Retryable(err)
+}
+
+// backoff 指数退避（1x,2x,4x…）加抖动：在 [d/2, d] 之间均匀取值，避免多个调用方同时重试
+func backoff(base time.Duration, attempt int) time.Duration {
+	d := base << attempt
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int64N(int64(half)+1))
+}
+
 // RetryStop 用于 Provider 主动标记“别再重试”
 type RetryStop struct{ error }
 
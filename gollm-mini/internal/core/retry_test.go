@@ -0,0 +1,101 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"gollm-mini/internal/provider"
+)
+
+func TestBackoffJitter(t *testing.T) {
+	base := 100 * time.Millisecond
+	for attempt := 0; attempt < 5; attempt++ {
+		d := base << attempt
+		for range 200 {
+			if got := backoff(base, attempt); got < d/2 || got > d {
+				t.Fatalf("backoff(%v, %d) = %v, want within [%v, %v]", base, attempt, got, d/2, d)
+			}
+		}
+	}
+	if got := backoff(0, 3); got != 0 {
+		t.Errorf("backoff(0) = %v", got)
+	}
+}
+
+func TestRetryDecisions(t *testing.T) {
+	cases := []struct {
+		name  string
+		err   error
+		calls int // tries = 3
+	}{
+		{"rate limited", &provider.Error{Kind: provider.ErrRateLimited}, 3},
+		{"overloaded", &provider.Error{Kind: provider.ErrOverloaded}, 3},
+		{"timeout", &provider.Error{Kind: provider.ErrTimeout}, 3},
+		{"transport", fmt.Errorf("read: %w", io.ErrUnexpectedEOF), 3},
+		{"auth", &provider.Error{Kind: provider.ErrAuth}, 1},
+		{"context length", &provider.Error{Kind: provider.ErrContextLength}, 1},
+		{"model not found", &provider.Error{Kind: provider.ErrModelNotFound}, 1},
+		{"invalid request", &provider.Error{Kind: provider.ErrInvalidRequest}, 1},
+		{"unclassified", errors.New("decode: invalid character"), 1},
+		{"retry stop", &RetryStop{&provider.Error{Kind: provider.ErrOverloaded}}, 1},
+		{"circuit open", &CircuitOpenError{Target: Target{Provider: "x"}}, 1},
+		{"too long Retry-After", &provider.Error{Kind: provider.ErrRateLimited, RetryAfter: time.Minute}, 1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := 0
+			err := Retry(context.Background(), 3, time.Millisecond, func() error {
+				calls++
+				return tc.err
+			})
+			if calls != tc.calls {
+				t.Errorf("calls = %d, want %d", calls, tc.calls)
+			}
+			if !errors.Is(err, tc.err) {
+				t.Errorf("err = %v, want %v", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestRetryRecovers(t *testing.T) {
+	calls := 0
+	err := Retry(context.Background(), 3, time.Millisecond, func() error {
+		if calls++; calls < 3 {
+			return &provider.Error{Kind: provider.ErrOverloaded}
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("err = %v after %d calls", err, calls)
+	}
+}
+
+func TestRetryHonoursRetryAfter(t *testing.T) {
+	start := time.Now()
+	calls := 0
+	_ = Retry(context.Background(), 2, time.Millisecond, func() error {
+		calls++
+		return &provider.Error{Kind: provider.ErrRateLimited, RetryAfter: 50 * time.Millisecond}
+	})
+	if d := time.Since(start); calls != 2 || d < 50*time.Millisecond {
+		t.Errorf("calls = %d after %v, want a 50ms wait", calls, d)
+	}
+}
+
+func TestRetryStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := Retry(ctx, 5, 50*time.Millisecond, func() error {
+		calls++
+		cancel()
+		return &provider.Error{Kind: provider.ErrOverloaded}
+	})
+	if !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Errorf("err = %v after %d calls", err, calls)
+	}
+}
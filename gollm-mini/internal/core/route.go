@@ -0,0 +1,133 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"gollm-mini/internal/helper"
+	"gollm-mini/internal/monitor"
+	"gollm-mini/internal/provider"
+	"gollm-mini/internal/types"
+)
+
+// Target 一个 provider/model 组合，写作 "ollama:llama3"（模型名可再含冒号，如 "ollama:llama3:8b"）
+type Target struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model,omitempty"`
+}
+
+func (t Target) String() string {
+	if t.Model == "" {
+		return t.Provider
+	}
+	return t.Provider + ":" + t.Model
+}
+
+// ParseTarget 解析 "provider[:model]"
+func ParseTarget(s string) (Target, error) {
+	p, m, _ := strings.Cut(strings.TrimSpace(s), ":")
+	if p == "" {
+		return Target{}, fmt.Errorf("invalid target %q", s)
+	}
+	return Target{Provider: p, Model: m}, nil
+}
+
+// ParseTargets 解析逗号分隔的目标列表，如 "ollama:llama3,hf:tinyllama,openai:gpt-4o-mini"
+func ParseTargets(s string) ([]Target, error) {
+	var out []Target
+	for _, part := range strings.Split(s, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		t, err := ParseTarget(part)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// NewChain 按顺序创建目标：第一个为主目标，其余为备用。
+// 主目标遇到可重试错误（重试耗尽后）时依次落到下一个目标。
+func NewChain(targets ...Target) (*LLM, error) {
+	if len(targets) == 0 {
+		return nil, errors.New("no targets")
+	}
+	var head *LLM
+	for _, t := range targets {
+		l, err := New(t.Provider, t.Model)
+		if err != nil {
+			return nil, fmt.Errorf("target %s: %w", t, err)
+		}
+		if head == nil {
+			head = l
+			continue
+		}
+		head.fallbacks = append(head.fallbacks, l)
+	}
+	return head, nil
+}
+
+// Target 返回本实例（主目标）的 provider/model
+func (l *LLM) Target() Target { return Target{Provider: l.name, Model: l.model} }
+
+// Cost 按价格表估算该目标上 u 的费用（USD）
+func (t Target) Cost(u types.Usage) float64 {
+	return helper.CalcCost(t.Provider, t.Model, u.PromptTokens, u.CompletionTokens)
+}
+
+// route 在主目标与备用目标上依次执行 fn，直到成功或遇到不应切换的错误；返回实际完成请求（或最后尝试）的目标
+func (l *LLM) route(ctx context.Context, endpoint string, fn func(c *LLM) error) (Target, error) {
+	c, err := l.routeVia(ctx, append([]*LLM{l}, l.fallbacks...), endpoint, fn)
+	if c == nil {
+		return l.Target(), err
+	}
+	return c.Target(), err
+}
+
+// routeVia 同 route，但只在给定的目标中尝试，并返回最后尝试的目标（RunTools 据此固定目标）
+func (l *LLM) routeVia(ctx context.Context, chain []*LLM, endpoint string, fn func(c *LLM) error) (*LLM, error) {
+	var err error
+	for i, c := range chain {
+		err = fn(c)
+		if err == nil {
+			monitor.Served.WithLabelValues(c.name, c.model, endpoint, strconv.FormatBool(i > 0)).Inc()
+			return c, nil
+		}
+		if i == len(chain)-1 || !shouldFallback(ctx, err) {
+			return c, err
+		}
+		next := chain[i+1].Target()
+		monitor.Fallbacks.WithLabelValues(c.Target().String(), next.String(), endpoint).Inc()
+		log.Printf("[FALLBACK] %s failed (%v), trying %s", c.Target(), err, next)
+	}
+	return nil, err
+}
+
+// shouldFallback 只有目标本身不可用时才切换：鉴权失败、过载、限流、超时、熔断中以及网络错误。
+// 参数错误、上下文过长、模型不存在、未分类错误换个目标也不会好，直接返回；
+// 调用方取消或已向客户端发出内容（RetryStop）时同样不切换。
+func shouldFallback(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var (
+		rs *RetryStop
+		co *CircuitOpenError
+	)
+	switch {
+	case errors.As(err, &rs):
+		return false
+	case errors.As(err, &co):
+		return true
+	case errors.Is(err, provider.ErrAuth), errors.Is(err, provider.ErrOverloaded),
+		errors.Is(err, provider.ErrRateLimited), errors.Is(err, provider.ErrTimeout):
+		return true
+	}
+	return provider.Transport(err)
+}
@@ -0,0 +1,231 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+
+	"gollm-mini/internal/provider"
+	"gollm-mini/internal/types"
+)
+
+// scripted 的行为由模型名决定：
+//
+//	ok-*        正常回答（模型名）；工具调用时第一轮要求调用 now，之后给出最终回答
+//	fail-<kind> 总是返回该分类的错误（kind 见 failKinds）
+//	partial-*   流式先发出一个 chunk 再返回过载错误
+//	picky-*     最后一条消息为 "fail" 时返回鉴权错误，否则正常回答
+type scripted struct{ model string }
+
+var failKinds = map[string]error{
+	"auth":       provider.ErrAuth,
+	"overloaded": provider.ErrOverloaded,
+	"ratelimit":  provider.ErrRateLimited,
+	"timeout":    provider.ErrTimeout,
+	"invalid":    provider.ErrInvalidRequest,
+	"ctxlen":     provider.ErrContextLength,
+	"notfound":   provider.ErrModelNotFound,
+}
+
+var (
+	scriptedMu    sync.Mutex
+	scriptedCalls = map[string]int{}
+)
+
+func calls(model string) int {
+	scriptedMu.Lock()
+	defer scriptedMu.Unlock()
+	return scriptedCalls[model]
+}
+
+func (s *scripted) fail() error {
+	scriptedMu.Lock()
+	scriptedCalls[s.model]++
+	scriptedMu.Unlock()
+	if kind, ok := strings.CutPrefix(s.model, "fail-"); ok {
+		name, _, _ := strings.Cut(kind, "-")
+		if k, ok := failKinds[name]; ok {
+			return &provider.Error{Kind: k, Provider: "scripted", Err: errors.New(s.model)}
+		}
+		return errors.New(s.model) // 未分类
+	}
+	return nil
+}
+
+func (s *scripted) Generate(_ context.Context, msgs []types.Message, _ types.GenerateOptions) (string, types.Usage, error) {
+	if err := s.fail(); err != nil {
+		return "", types.Usage{}, err
+	}
+	if strings.HasPrefix(s.model, "picky-") && msgs[len(msgs)-1].Content == "fail" {
+		return "", types.Usage{}, &provider.Error{Kind: provider.ErrAuth, Provider: "scripted", Err: errors.New(s.model)}
+	}
+	return s.model, types.Usage{}, nil
+}
+
+func (s *scripted) Stream(_ context.Context, _ []types.Message, _ types.GenerateOptions, cb func(types.Chunk)) (types.Usage, error) {
+	if err := s.fail(); err != nil {
+		return types.Usage{}, err
+	}
+	cb(types.Chunk{Content: s.model})
+	if strings.HasPrefix(s.model, "partial-") {
+		return types.Usage{}, &provider.Error{Kind: provider.ErrOverloaded, Provider: "scripted", Err: errors.New("cut")}
+	}
+	return types.Usage{}, nil
+}
+
+func (s *scripted) GenerateWithTools(_ context.Context, msgs []types.Message, _ []types.Tool, _ types.GenerateOptions) (types.Message, types.Usage, error) {
+	if err := s.fail(); err != nil {
+		return types.Message{}, types.Usage{}, err
+	}
+	if msgs[len(msgs)-1].Role == types.RoleTool {
+		return types.Message{Role: types.RoleAssistant, Content: "answered by " + s.model}, types.Usage{}, nil
+	}
+	return types.Message{Role: types.RoleAssistant, ToolCalls: []types.ToolCall{{ID: "c1", Name: "now"}}}, types.Usage{}, nil
+}
+
+func init() {
+	provider.Register("scripted", provider.Simple("ok-default", func(m string) *scripted { return &scripted{model: m} }))
+}
+
+func chain(t *testing.T, models ...string) *LLM {
+	t.Helper()
+	targets := make([]Target, len(models))
+	for i, m := range models {
+		targets[i] = Target{Provider: "scripted", Model: m}
+	}
+	llm, err := NewChain(targets...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return llm
+}
+
+func TestFallbackClasses(t *testing.T) {
+	cases := []struct {
+		kind     string
+		fallback bool
+	}{
+		{"auth", true},
+		{"ratelimit", true},
+		{"overloaded", true},
+		{"timeout", true},
+		{"invalid", false},
+		{"ctxlen", false},
+		{"notfound", false},
+		{"unclassified", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.kind, func(t *testing.T) {
+			primary := "fail-" + tc.kind + "-" + t.Name()
+			llm := chain(t, primary, "ok-backup")
+			res, err := llm.Generate(context.Background(), prompt)
+			if tc.fallback {
+				if err != nil || res.Text != "ok-backup" || res.Served.Model != "ok-backup" {
+					t.Errorf("txt = %q, err = %v, served %v; want fallback", res.Text, err, res.Served)
+				}
+				return
+			}
+			if err == nil || res.Served.Model != primary {
+				t.Errorf("txt = %q, err = %v, served %v; want the primary's error", res.Text, err, res.Served)
+			}
+		})
+	}
+}
+
+// 同一个 LLM 被并发调用时，每次调用报告各自实际服务的目标
+func TestServedPerCall(t *testing.T) {
+	llm := chain(t, "picky-primary", "ok-backup-served")
+	// 一半请求在主目标上失败，放宽熔断，避免主目标被熔断后全部切走
+	b := breakerFor(llm.Target())
+	b.mu.Lock()
+	b.cfg.MinRequests = math.MaxInt
+	b.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			content, want := "hi", "picky-primary"
+			if i%2 == 1 {
+				content, want = "fail", "ok-backup-served"
+			}
+			res, err := llm.Generate(context.Background(), []types.Message{{Role: types.RoleUser, Content: content}})
+			if err != nil || res.Text != want || res.Served.Model != want {
+				t.Errorf("call %d: txt = %q, err = %v, served %v; want %s", i, res.Text, err, res.Served, want)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestStreamNoFallbackAfterFirstChunk(t *testing.T) {
+	llm := chain(t, "partial-stream", "ok-backup-stream")
+	var got strings.Builder
+	res, err := llm.Stream(context.Background(), prompt, func(ch types.Chunk) { got.WriteString(ch.Content) })
+	if !errors.Is(err, provider.ErrOverloaded) {
+		t.Fatalf("err = %v, want the primary's error", err)
+	}
+	var rs *RetryStop
+	if errors.As(err, &rs) {
+		t.Error("RetryStop leaked to the caller")
+	}
+	if res.Text != "partial-stream" || res.Served.Model != "partial-stream" {
+		t.Errorf("result text %q, served %v", res.Text, res.Served)
+	}
+	if got.String() != "partial-stream" || calls("partial-stream") != 1 || calls("ok-backup-stream") != 0 {
+		t.Errorf("streamed %q, primary calls %d, backup calls %d", got.String(), calls("partial-stream"), calls("ok-backup-stream"))
+	}
+
+	// 尚未发出 chunk 时仍然切换
+	llm = chain(t, "fail-auth-stream", "ok-backup-stream2")
+	got.Reset()
+	res, err = llm.Stream(context.Background(), prompt, func(ch types.Chunk) { got.WriteString(ch.Content) })
+	if err != nil || got.String() != "ok-backup-stream2" || res.Served.Model != "ok-backup-stream2" {
+		t.Errorf("streamed %q, err %v, served %v; want fallback before the first chunk", got.String(), err, res.Served)
+	}
+}
+
+func TestRunToolsPinsTarget(t *testing.T) {
+	llm := chain(t, "fail-auth-tools", "ok-tools")
+	llm.RegisterTool(types.Tool{Name: "now"}, func(context.Context, json.RawMessage) (string, error) { return "12:00", nil })
+
+	res, convo, err := llm.RunTools(context.Background(), prompt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Text != "answered by ok-tools" || res.Served.Model != "ok-tools" || len(convo) != 4 {
+		t.Errorf("final = %q from %v, convo %d messages", res.Text, res.Served, len(convo))
+	}
+	// 第二轮直接发给第一轮实际服务的目标，不再先试主目标
+	if n := calls("fail-auth-tools"); n != 1 {
+		t.Errorf("primary called %d times, want 1", n)
+	}
+	if n := calls("ok-tools"); n != 2 {
+		t.Errorf("backup called %d times, want 2", n)
+	}
+}
+
+func TestRunToolsNoFallbackAfterFirstRound(t *testing.T) {
+	llm := chain(t, "ok-pinned", "ok-never")
+	round := 0
+	llm.RegisterTool(types.Tool{Name: "now"}, func(context.Context, json.RawMessage) (string, error) {
+		round++
+		// 模拟主目标在第二轮前失效：把主目标替换成一直过载的实例
+		llm.p = &scripted{model: fmt.Sprintf("fail-overloaded-pinned-%d", round)}
+		return "12:00", nil
+	})
+
+	_, _, err := llm.RunTools(context.Background(), prompt)
+	if !errors.Is(err, provider.ErrOverloaded) {
+		t.Fatalf("err = %v, want the pinned target's error", err)
+	}
+	if n := calls("ok-never"); n != 0 {
+		t.Errorf("fallback called %d times after the first round", n)
+	}
+}
@@ -1,44 +1,281 @@
 package core
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
-	"time"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
 
 	"gollm-mini/internal/helper"
+	"gollm-mini/internal/monitor"
+	"gollm-mini/internal/provider"
 	"gollm-mini/internal/types"
 )
 
 const structuredRetries = 3
 
-// StructuredGenerate 给定 schema & prompt，自动重试直到输出合法 JSON
-func (l *LLM) StructuredGenerate(
+// jsonInstruction 两种模式都附带 schema：原生约束解码时用于引导内容，提示词约束时是唯一约束
+const jsonInstruction = "请仅以符合以下 JSON Schema 的 JSON 输出，勿添加解释。\nSchema: "
+
+// repairInstruction 修复轮次：把上一次输出的错误逐条反馈给模型
+const repairInstruction = "你上一次的输出未通过校验：\n%s\n请修正以上问题，仅输出完整的 JSON，勿添加解释。"
+
+// StructuredAttempt 一次模型调用的结果
+type StructuredAttempt struct {
+	Mode   string   `json:"mode"`             // native / prompt
+	Result string   `json:"result"`           // ok / repaired / invalid_json / schema_mismatch / error
+	Errors []string `json:"errors,omitempty"` // 解析错误或逐条 schema 错误（即反馈给模型的内容）
+	Output string   `json:"output,omitempty"` // 未通过校验时模型的原始输出
+}
+
+// StructuredReport StructuredGenerate 的过程报告
+type StructuredReport struct {
+	Attempts    int                 `json:"attempts"`     // 模型调用次数，1 表示首次即通过
+	LocalRepair bool                `json:"local_repair"` // 最终结果经本地 JSON 修复（helper.RepairJSON）后才通过
+	History     []StructuredAttempt `json:"history"`
+	Usage       types.Usage         `json:"usage"` // 所有尝试累计
+	Served      Target              `json:"-"`     // 最后一次尝试实际使用的目标（响应里另有 provider / model）
+}
+
+// StructuredGenerate 给定 schema 文件 & prompt，输出合法 JSON 并写入 out（schema 中的相对 $ref 按文件路径解析）。
+func (l *LLM) StructuredGenerate(ctx context.Context, prompt []types.Message, schemaPath string, out interface{}) (StructuredReport, error) {
+	return l.structuredFile(ctx, prompt, schemaPath, out, nil)
+}
+
+// StructuredGenerateSchema 同 StructuredGenerate，schema 直接给出（内联 / 注册表 / 由类型生成）
+func (l *LLM) StructuredGenerateSchema(ctx context.Context, prompt []types.Message, schema json.RawMessage, out interface{}) (StructuredReport, error) {
+	return l.StructuredStreamSchema(ctx, prompt, schema, out, nil)
+}
+
+// StructuredPartial 流式结构化输出中的一个部分结果
+type StructuredPartial struct {
+	Attempt int              `json:"attempt"` // 从 1 开始；修复轮次重新生成时快照从头开始
+	Value   interface{}      `json:"value"`   // 补齐括号后的部分对象（尚未做 schema 校验）
+	Patch   []helper.PatchOp `json:"patch"`   // 相对本轮上一个快照的 JSON Patch；本轮第一个快照是对根的 add
+}
+
+// StructuredStream 同 StructuredGenerate，但流式调用模型，每当部分对象发生变化时回调 onPartial。
+// 完整对象在结束时仍按 schema 校验，不通过时照常本地修复 / 进入修复轮次（新的一轮同样流式回调）。
+func (l *LLM) StructuredStream(ctx context.Context, prompt []types.Message, schemaPath string, out interface{}, onPartial func(StructuredPartial)) (StructuredReport, error) {
+	return l.structuredFile(ctx, prompt, schemaPath, out, onPartial)
+}
+
+// StructuredStreamSchema 同 StructuredStream，schema 直接给出；onPartial 为 nil 时即一次性生成
+func (l *LLM) StructuredStreamSchema(ctx context.Context, prompt []types.Message, schema json.RawMessage, out interface{}, onPartial func(StructuredPartial)) (StructuredReport, error) {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, schema); err != nil {
+		return StructuredReport{}, fmt.Errorf("parse schema: %w", err)
+	}
+	schema = compact.Bytes()
+	return l.structured(ctx, prompt, schema, func(data []byte) error {
+		return helper.ValidateJSONSchemaBytes(schema, data)
+	}, out, onPartial)
+}
+
+// structuredFile 读取 schema 文件；校验按文件路径进行，以便解析相对 $ref
+func (l *LLM) structuredFile(ctx context.Context, prompt []types.Message, schemaPath string, out interface{}, onPartial func(StructuredPartial)) (StructuredReport, error) {
+	schema, err := os.ReadFile(schemaPath)
+	if err != nil {
+		return StructuredReport{}, fmt.Errorf("read schema: %w", err)
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, schema); err != nil {
+		return StructuredReport{}, fmt.Errorf("parse schema %s: %w", schemaPath, err)
+	}
+	return l.structured(ctx, prompt, compact.Bytes(), func(data []byte) error {
+		return helper.ValidateJSONSchema(schemaPath, data)
+	}, out, onPartial)
+}
+
+// partials 把一轮流式输出增量解析为部分对象，只在对象变化时回调
+func partials(attempt int, onPartial func(StructuredPartial)) func(types.Chunk) {
+	var (
+		js   helper.JSONStream
+		last string
+		prev interface{}
+	)
+	return func(ch types.Chunk) {
+		if js.Done() {
+			return
+		}
+		js.Write(ch.Content)
+		txt, ok := js.Partial()
+		if !ok || txt == last {
+			return
+		}
+		var v interface{}
+		if json.Unmarshal([]byte(txt), &v) != nil {
+			return // 结尾是没写完的数字 / 字面量，等下一块
+		}
+		last = txt
+		patch := helper.DiffJSON(prev, v)
+		prev = v
+		if len(patch) > 0 {
+			onPartial(StructuredPartial{Attempt: attempt, Value: v, Patch: patch})
+		}
+	}
+}
+
+// structured 目标 Provider 支持原生约束解码（provider.SchemaConstrained）时把 schema 交给后端，否则只靠提示词约束。
+// 输出不合法时先做本地修复；仍不通过则把上一次输出与解析 / 校验错误作为修复轮次发回模型，最多 structuredRetries 次。
+func (l *LLM) structured(
 	ctx context.Context,
 	prompt []types.Message,
-	schemaPath string,
+	schema json.RawMessage,
+	validate func([]byte) error,
 	out interface{},
-) (types.Usage, error) {
-
-	var usage types.Usage
-	err := Retry(ctx, structuredRetries, 300*time.Millisecond, func() error {
-		// 1. 在系统指令前追加“严格输出 JSON”提示
-		enforced := append(
-			[]types.Message{{Role: types.RoleSystem, Content: "请仅以符合 schema 的 JSON 输出，勿添加解释。"}},
-			prompt...,
+	onPartial func(StructuredPartial), // 非 nil 时流式生成
+) (StructuredReport, error) {
+
+	var (
+		rep StructuredReport
+		err error
+	)
+	msgs := prompt
+	for i := 0; i < structuredRetries; i++ {
+		var onChunk func(types.Chunk)
+		if onPartial != nil {
+			onChunk = partials(i+1, onPartial)
+		}
+		txt, u, mode, served, genErr := l.generateJSON(ctx, msgs, schema, onChunk)
+		rep.Served = served
+		rep.Attempts++
+		rep.Usage.PromptTokens += u.PromptTokens
+		rep.Usage.CompletionTokens += u.CompletionTokens
+		att := StructuredAttempt{Mode: mode}
+		if genErr != nil {
+			// Provider 错误已在 generate 内按分类重试并尝试过备用目标
+			att.Result, att.Errors = "error", []string{genErr.Error()}
+			rep.History = append(rep.History, att)
+			countStructured(served, mode, att.Result)
+			return rep, genErr
+		}
+
+		raw, repaired, checkErr := checkJSON(txt, validate)
+		if checkErr == nil {
+			if err := json.Unmarshal(raw, out); err != nil {
+				return rep, err
+			}
+			att.Result = "ok"
+			if repaired {
+				att.Result = "repaired"
+			}
+			rep.LocalRepair = repaired
+			rep.History = append(rep.History, att)
+			countStructured(served, mode, att.Result)
+			return rep, nil
+		}
+
+		err = checkErr
+		att.Result, att.Errors, att.Output = "invalid_json", []string{checkErr.Error()}, txt
+		var se *helper.SchemaError
+		if errors.As(checkErr, &se) {
+			att.Result, att.Errors = "schema_mismatch", se.Details
+		}
+		rep.History = append(rep.History, att)
+		countStructured(served, mode, att.Result)
+
+		// 修复轮次：上一次输出 + 错误清单
+		msgs = append(msgs[:len(msgs):len(msgs)],
+			types.Message{Role: types.RoleAssistant, Content: txt},
+			types.Message{Role: types.RoleUser, Content: fmt.Sprintf(repairInstruction, "- "+strings.Join(att.Errors, "\n- "))},
 		)
+	}
+	return rep, fmt.Errorf("structured output failed after %d attempts: %w", rep.Attempts, err)
+}
+
+// checkJSON 解析并按 schema 校验模型输出；不通过时先本地修复再校验一次。
+// 修复后能解析时报告修复后的错误（通常是更具体的 schema 错误），否则报告原始错误。
+func checkJSON(txt string, validate func([]byte) error) (json.RawMessage, bool, error) {
+	raw, err := validateJSON(txt, validate)
+	if err == nil {
+		return raw, false, nil
+	}
+	fixed, changed := helper.RepairJSON(txt)
+	if !changed {
+		return nil, false, err
+	}
+	raw, fixErr := validateJSON(fixed, validate)
+	if fixErr == nil {
+		return raw, true, nil
+	}
+	var se *helper.SchemaError
+	if errors.As(fixErr, &se) {
+		return nil, false, fixErr
+	}
+	return nil, false, err
+}
+
+// validateJSON 校验模型的原始输出（而不是反序列化到调用方类型后的结果）
+func validateJSON(txt string, validate func([]byte) error) (json.RawMessage, error) {
+	var raw json.RawMessage
+	if err := helper.ParseJSON(txt, &raw); err != nil {
+		return nil, err
+	}
+	if err := validate(raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
 
-		txt, u, err := l.Generate(ctx, enforced)
-		usage = u
-		if err != nil {
-			return err
+// generateJSON 按每个目标（含备用目标）的能力选择约束方式，返回完整输出与实际使用的模式（native / prompt）。
+// onChunk 非 nil 时流式调用，与 Stream 一样只在尚未发出 chunk 时切换目标
+func (l *LLM) generateJSON(ctx context.Context, prompt []types.Message, schema json.RawMessage, onChunk func(types.Chunk)) (string, types.Usage, string, Target, error) {
+	msgs := append([]types.Message{{Role: types.RoleSystem, Content: jsonInstruction + string(schema)}}, prompt...)
+
+	var (
+		txt   string
+		usage types.Usage
+		mode  string
+		sent  bool
+	)
+	call := func(c *LLM, opts types.GenerateOptions) error {
+		if onChunk == nil {
+			var e error
+			txt, usage, e = c.generate(ctx, msgs, opts)
+			return e
+		}
+		var buf strings.Builder
+		u, e := c.stream(ctx, msgs, opts, func(ch types.Chunk) {
+			sent = true
+			buf.WriteString(ch.Content)
+			onChunk(ch)
+		})
+		txt, usage = buf.String(), u
+		if e != nil && sent {
+			return &RetryStop{e}
+		}
+		return e
+	}
+	served, err := l.route(ctx, "structured", func(c *LLM) error {
+		opts := c.opts
+		mode = "prompt"
+		if sc, ok := provider.As[provider.SchemaConstrained](c.p); ok && sc.SupportsJSONSchema() {
+			mode = "native"
+			opts.JSONSchema = schema
 		}
 
-		if err := helper.ParseJSON(txt, out); err != nil {
-			return err // 触发重试
+		e := call(c, opts)
+		if e != nil && mode == "native" && !sent && errors.Is(e, provider.ErrInvalidRequest) {
+			// 后端拒绝 schema（版本过旧 / 不支持的关键字）：同一目标退回提示词约束
+			log.Printf("[WARN] %s rejected native JSON schema, falling back to prompt: %v", c.Target(), e)
+			mode = "prompt"
+			opts.JSONSchema = nil
+			e = call(c, opts)
 		}
-		// 二次验证 schema
-		raw, _ := json.Marshal(out)
-		return helper.ValidateJSONSchema(schemaPath, raw)
+		return e
 	})
-	return usage, err
+	var rs *RetryStop
+	if errors.As(err, &rs) {
+		err = rs.error
+	}
+	return txt, usage, mode, served, err
+}
+
+func countStructured(t Target, mode, result string) {
+	monitor.StructuredAttempts.WithLabelValues(t.Provider, mode, result).Inc()
 }
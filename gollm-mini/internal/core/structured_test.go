@@ -0,0 +1,220 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"gollm-mini/internal/provider"
+	"gollm-mini/internal/types"
+)
+
+// schemaModel 记录收到的 JSONSchema 与消息；native 控制是否声明原生约束解码，reject 模拟后端拒绝 schema，
+// replies 按顺序作为回答（用完后固定返回合法 JSON）
+type schemaModel struct {
+	native, reject bool
+	replies        []string
+
+	mu   sync.Mutex
+	seen [][]byte
+	msgs [][]types.Message
+}
+
+func (s *schemaModel) Generate(_ context.Context, msgs []types.Message, opts types.GenerateOptions) (string, types.Usage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seen = append(s.seen, opts.JSONSchema)
+	s.msgs = append(s.msgs, msgs)
+	if s.reject && len(opts.JSONSchema) > 0 {
+		return "", types.Usage{}, &provider.Error{Kind: provider.ErrInvalidRequest, Provider: "schema", Err: context.Canceled}
+	}
+	if len(s.replies) > 0 {
+		r := s.replies[0]
+		s.replies = s.replies[1:]
+		return r, types.Usage{PromptTokens: 1, CompletionTokens: 1}, nil
+	}
+	return `{"name":"Tokyo","country":"Japan"}`, types.Usage{PromptTokens: 1, CompletionTokens: 1}, nil
+}
+
+// Stream 把 Generate 的回答切成 4 字节一块
+func (s *schemaModel) Stream(ctx context.Context, msgs []types.Message, opts types.GenerateOptions, cb func(types.Chunk)) (types.Usage, error) {
+	txt, u, err := s.Generate(ctx, msgs, opts)
+	for i := 0; i < len(txt); i += 4 {
+		cb(types.Chunk{Content: txt[i:min(i+4, len(txt))], Delta: 1})
+	}
+	return u, err
+}
+
+func (s *schemaModel) SupportsJSONSchema() bool { return s.native }
+
+func TestStructuredConstraintMode(t *testing.T) {
+	cases := []struct {
+		name  string
+		model *schemaModel
+		want  []bool // 每次调用是否带 schema
+	}{
+		{"native", &schemaModel{native: true}, []bool{true}},
+		{"prompt", &schemaModel{}, []bool{false}},
+		{"rejected", &schemaModel{native: true, reject: true}, []bool{true, false}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := tc.model
+			provider.Register("schema-"+tc.name, func(string) (provider.Provider, error) { return m, nil })
+			llm, err := New("schema-"+tc.name, "m")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out map[string]any
+			if _, err := llm.StructuredGenerate(context.Background(), prompt, "testdata/city.schema.json", &out); err != nil {
+				t.Fatal(err)
+			}
+			if out["name"] != "Tokyo" {
+				t.Errorf("out = %v", out)
+			}
+			if len(m.seen) != len(tc.want) {
+				t.Fatalf("%d calls, want %d", len(m.seen), len(tc.want))
+			}
+			for i, w := range tc.want {
+				if got := len(m.seen[i]) > 0; got != w {
+					t.Errorf("call %d: schema sent = %v, want %v", i, got, w)
+				}
+			}
+		})
+	}
+}
+
+func TestStructuredRepair(t *testing.T) {
+	cases := []struct {
+		name    string
+		replies []string
+		calls   int
+		local   bool
+		results []string
+	}{
+		{"first try", nil, 1, false, []string{"ok"}},
+		{"local repair", []string{"```json\n{'name': 'Tokyo', 'country': 'Japan',}\n```"}, 1, true, []string{"repaired"}},
+		{"schema feedback", []string{`{"name":"Tokyo","population":"14m"}`}, 2, false, []string{"schema_mismatch", "ok"}},
+		{"parse feedback", []string{"Tokyo is in Japan."}, 2, false, []string{"invalid_json", "ok"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := &schemaModel{replies: tc.replies}
+			name := "repair-" + tc.name
+			provider.Register(name, func(string) (provider.Provider, error) { return m, nil })
+			llm, err := New(name, "m")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out map[string]any
+			rep, err := llm.StructuredGenerate(context.Background(), prompt, "testdata/city.schema.json", &out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out["name"] != "Tokyo" || out["country"] != "Japan" {
+				t.Errorf("out = %v", out)
+			}
+			if rep.Attempts != tc.calls || len(m.msgs) != tc.calls || rep.LocalRepair != tc.local {
+				t.Errorf("report = %+v, %d calls", rep, len(m.msgs))
+			}
+			if rep.Usage.Total() != 2*tc.calls {
+				t.Errorf("usage = %+v", rep.Usage)
+			}
+			for i, want := range tc.results {
+				if i >= len(rep.History) || rep.History[i].Result != want {
+					t.Fatalf("history = %+v, want results %v", rep.History, tc.results)
+				}
+			}
+			if tc.calls < 2 {
+				return
+			}
+			// 修复轮次：上一次输出作为 assistant 消息，错误清单作为 user 消息
+			retry := m.msgs[1]
+			prev, fix := retry[len(retry)-2], retry[len(retry)-1]
+			if prev.Role != types.RoleAssistant || prev.Content != tc.replies[0] {
+				t.Errorf("previous output not fed back: %+v", prev)
+			}
+			for _, e := range rep.History[0].Errors {
+				if !strings.Contains(fix.Content, e) {
+					t.Errorf("repair turn %q misses error %q", fix.Content, e)
+				}
+			}
+		})
+	}
+}
+
+func TestStructuredGivesUp(t *testing.T) {
+	m := &schemaModel{replies: []string{"no", "still no", "nope"}}
+	provider.Register("repair-give-up", func(string) (provider.Provider, error) { return m, nil })
+	llm, err := New("repair-give-up", "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]any
+	rep, err := llm.StructuredGenerate(context.Background(), prompt, "testdata/city.schema.json", &out)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if rep.Attempts != structuredRetries || len(rep.History) != structuredRetries {
+		t.Errorf("report = %+v", rep)
+	}
+	if n := len(m.msgs[2]) - len(m.msgs[0]); n != 4 {
+		t.Errorf("third call has %d extra messages, want 4 (two repair turns)", n)
+	}
+}
+
+func TestStructuredStream(t *testing.T) {
+	m := &schemaModel{native: true, replies: []string{
+		`{"name":"Tokyo","population":"big"}`, // schema 不通过，进入修复轮次
+		"```json\n" + `{"name":"Tokyo","country":"Japan","population":37400000}` + "\n```",
+	}}
+	provider.Register("structured-stream", func(string) (provider.Provider, error) { return m, nil })
+	llm, err := New("structured-stream", "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var parts []StructuredPartial
+	var out map[string]any
+	rep, err := llm.StructuredStream(context.Background(), prompt, "testdata/city.schema.json", &out, func(p StructuredPartial) {
+		parts = append(parts, p)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out["country"] != "Japan" || rep.Attempts != 2 || rep.History[0].Result != "schema_mismatch" {
+		t.Fatalf("out = %v, report = %+v", out, rep)
+	}
+	if len(parts) < 4 {
+		t.Fatalf("only %d partials", len(parts))
+	}
+
+	// 每轮的第一个 patch 是对根的 add；最后一个快照就是完整对象
+	attempt := 0
+	for _, p := range parts {
+		if p.Attempt != attempt {
+			if p.Attempt != attempt+1 || len(p.Patch) != 1 || p.Patch[0].Path != "" {
+				t.Fatalf("attempt %d starts with %+v", p.Attempt, p.Patch)
+			}
+			attempt = p.Attempt
+		}
+	}
+	if attempt != 2 {
+		t.Errorf("partials cover %d attempts, want 2", attempt)
+	}
+	last := parts[len(parts)-1].Value.(map[string]any)
+	if last["name"] != "Tokyo" || last["population"] != 37400000.0 {
+		t.Errorf("last snapshot = %v", last)
+	}
+	// 名称逐块增长："To" → "Toky" → "Tokyo"
+	var names []any
+	for _, p := range parts {
+		if v, ok := p.Value.(map[string]any)["name"]; ok && p.Attempt == 1 {
+			names = append(names, v)
+		}
+	}
+	if len(names) < 2 || names[0] == "Tokyo" {
+		t.Errorf("name did not stream incrementally: %v", names)
+	}
+}
@@ -0,0 +1,156 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"gollm-mini/internal/helper"
+	"gollm-mini/internal/monitor"
+	"gollm-mini/internal/provider"
+	"gollm-mini/internal/tokenizer"
+	"gollm-mini/internal/types"
+)
+
+// maxToolRounds 单次 RunTools 最多允许的“模型→工具”往返次数
+const maxToolRounds = 8
+
+// ToolHandler 执行一次工具调用；args 为模型给出的 JSON 参数，返回值作为 tool 消息回填
+type ToolHandler func(ctx context.Context, args json.RawMessage) (string, error)
+
+type registeredTool struct {
+	def     types.Tool
+	handler ToolHandler
+}
+
+// RegisterTool 注册一个 Go 工具，RunTools 时会把定义发给模型；同名工具会被替换（保持原有顺序）
+func (l *LLM) RegisterTool(def types.Tool, h ToolHandler) {
+	if l.tools == nil {
+		l.tools = map[string]registeredTool{}
+	}
+	if _, dup := l.tools[def.Name]; !dup {
+		l.toolOrder = append(l.toolOrder, def.Name)
+	}
+	l.tools[def.Name] = registeredTool{def: def, handler: h}
+}
+
+// GenerateWithTools 单轮调用：返回的 assistant 消息可能包含 ToolCalls，由调用方自行执行；
+// Result.Text 即消息正文
+func (l *LLM) GenerateWithTools(ctx context.Context, messages []types.Message, tools []types.Tool) (types.Message, Result, error) {
+	msg, usage, served, err := l.toolsVia(ctx, append([]*LLM{l}, l.fallbacks...), messages, tools)
+	res := Result{Text: msg.Content, Usage: usage, Served: l.Target()}
+	if served != nil {
+		res.Served = served.Target()
+	}
+	return msg, res, err
+}
+
+// toolsVia 在 chain 上路由一次工具调用，额外返回实际服务的目标
+func (l *LLM) toolsVia(ctx context.Context, chain []*LLM, messages []types.Message, tools []types.Tool) (types.Message, types.Usage, *LLM, error) {
+	var (
+		msg   types.Message
+		usage types.Usage
+	)
+	served, err := l.routeVia(ctx, chain, "tools", func(c *LLM) error {
+		var e error
+		msg, usage, e = c.generateWithTools(ctx, messages, tools)
+		return e
+	})
+	return msg, usage, served, err
+}
+
+// generateWithTools 在单个目标上调用；按该目标的 tokenizer 截断（工具调用与其结果整组保留或丢弃）
+func (l *LLM) generateWithTools(ctx context.Context, messages []types.Message, tools []types.Tool) (types.Message, types.Usage, error) {
+	tc, ok := provider.As[provider.ToolCaller](l.p)
+	if !ok {
+		return types.Message{}, types.Usage{}, &provider.Error{Kind: provider.ErrInvalidRequest, Provider: l.name,
+			Err: fmt.Errorf("provider %s does not support tools", l.name)}
+	}
+	messages = helper.TruncateMessagesFor(l.tok, messages, maxCtx)
+
+	start := time.Now()
+	var (
+		msg   types.Message
+		usage types.Usage
+	)
+	est := tokenizer.CountMessages(l.tok, messages) // 限流预留
+	err := Retry(ctx, 3, 300*time.Millisecond, l.guard(ctx, l.throttle(ctx, est, &usage, func() error {
+		var e error
+		msg, usage, e = tc.GenerateWithTools(ctx, messages, tools, l.opts)
+		return e
+	})))
+
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	monitor.Latency.WithLabelValues(l.name, "tools", status).Observe(time.Since(start).Seconds())
+	monitor.Tokens.WithLabelValues(l.name, "prompt").Add(float64(usage.PromptTokens))
+	monitor.Tokens.WithLabelValues(l.name, "completion").Add(float64(usage.CompletionTokens))
+	return msg, usage, err
+}
+
+// RunTools 循环执行已注册工具，直到模型给出不含工具调用的最终回答。
+// 返回最终结果（文本、累计 Usage、实际服务的目标）以及完整对话（含 assistant/tool 中间消息）。
+func (l *LLM) RunTools(ctx context.Context, messages []types.Message) (Result, []types.Message, error) {
+	defs := make([]types.Tool, 0, len(l.toolOrder))
+	for _, name := range l.toolOrder {
+		defs = append(defs, l.tools[name].def)
+	}
+
+	res := Result{Served: l.Target()}
+	// convo 保留完整对话，每轮发送前由实际目标截断
+	convo := append([]types.Message(nil), messages...)
+
+	// 第一轮可按 fallback 链切换；之后固定在实际服务的目标上，
+	// 否则 tool_call id 与中间消息会被送到另一个后端
+	chain := append([]*LLM{l}, l.fallbacks...)
+	for round := 0; round < maxToolRounds; round++ {
+		msg, u, served, err := l.toolsVia(ctx, chain, convo, defs)
+		res.Usage.PromptTokens += u.PromptTokens
+		res.Usage.CompletionTokens += u.CompletionTokens
+		if served != nil {
+			res.Served = served.Target()
+		}
+		if err != nil {
+			return res, convo, err
+		}
+		convo = append(convo, msg)
+		chain = []*LLM{served}
+
+		if len(msg.ToolCalls) == 0 {
+			res.Text = msg.Content
+			return res, convo, nil
+		}
+
+		for _, call := range msg.ToolCalls {
+			convo = append(convo, types.Message{
+				Role:       types.RoleTool,
+				Name:       call.Name,
+				ToolCallID: call.ID,
+				Content:    l.invokeTool(ctx, call),
+			})
+		}
+	}
+	return res, convo, fmt.Errorf("tool loop exceeded %d rounds", maxToolRounds)
+}
+
+// invokeTool 执行单个调用；错误以文本形式回填，让模型有机会自行纠正
+func (l *LLM) invokeTool(ctx context.Context, call types.ToolCall) string {
+	t, ok := l.tools[call.Name]
+	if !ok {
+		monitor.ToolCalls.WithLabelValues(call.Name, "unknown").Inc()
+		return fmt.Sprintf("error: unknown tool %q", call.Name)
+	}
+
+	out, err := t.handler(ctx, call.Arguments)
+	if err != nil {
+		monitor.ToolCalls.WithLabelValues(call.Name, "error").Inc()
+		log.Printf("[TOOL] %s failed: %v", call.Name, err)
+		return "error: " + err.Error()
+	}
+	monitor.ToolCalls.WithLabelValues(call.Name, "ok").Inc()
+	return out
+}
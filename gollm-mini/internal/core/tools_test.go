@@ -0,0 +1,119 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"gollm-mini/internal/provider"
+	"gollm-mini/internal/tokenizer"
+	"gollm-mini/internal/types"
+)
+
+// toolRecorder 记录每次收到的消息；前 3 轮各要求调用 big 与 small，第 4 轮给出最终回答。
+// 模型名以 fail- 开头时总是返回鉴权错误
+type toolRecorder struct {
+	model string
+	mu    sync.Mutex
+	reqs  [][]types.Message
+}
+
+var (
+	recordersMu sync.Mutex
+	recorders   = map[string]*toolRecorder{}
+)
+
+func (r *toolRecorder) Generate(context.Context, []types.Message, types.GenerateOptions) (string, types.Usage, error) {
+	return "", types.Usage{}, errors.New("not used")
+}
+
+func (r *toolRecorder) Stream(context.Context, []types.Message, types.GenerateOptions, func(types.Chunk)) (types.Usage, error) {
+	return types.Usage{}, errors.New("not used")
+}
+
+func (r *toolRecorder) GenerateWithTools(_ context.Context, msgs []types.Message, _ []types.Tool, _ types.GenerateOptions) (types.Message, types.Usage, error) {
+	if strings.HasPrefix(r.model, "fail-") {
+		return types.Message{}, types.Usage{}, &provider.Error{Kind: provider.ErrAuth, Provider: "toolrec", Err: errors.New(r.model)}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reqs = append(r.reqs, append([]types.Message(nil), msgs...))
+	if n := len(r.reqs); n < 4 {
+		return types.Message{Role: types.RoleAssistant, ToolCalls: []types.ToolCall{
+			{ID: fmt.Sprintf("c%d-big", n), Name: "big"},
+			{ID: fmt.Sprintf("c%d-small", n), Name: "small"},
+		}}, types.Usage{}, nil
+	}
+	return types.Message{Role: types.RoleAssistant, Content: "done"}, types.Usage{}, nil
+}
+
+func init() {
+	provider.Register("toolrec", provider.Simple("toolrec-default", func(m string) *toolRecorder {
+		recordersMu.Lock()
+		defer recordersMu.Unlock()
+		r := &toolRecorder{model: m}
+		recorders[m] = r
+		return r
+	}))
+}
+
+// charTokenizer 每个字节算一个 token，比默认估算大 4 倍
+type charTokenizer struct{}
+
+func (charTokenizer) Name() string          { return "char" }
+func (charTokenizer) Count(text string) int { return len(text) }
+
+func TestRunToolsTruncation(t *testing.T) {
+	llm, err := NewChain(Target{Provider: "toolrec", Model: "fail-primary"}, Target{Provider: "toolrec", Model: "backup"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 备用目标的上下文更“小”：必须按它自己的 tokenizer 截断
+	llm.fallbacks[0].tok = charTokenizer{}
+	llm.RegisterTool(types.Tool{Name: "big"}, func(context.Context, json.RawMessage) (string, error) {
+		return strings.Repeat("x", 900), nil
+	})
+	llm.RegisterTool(types.Tool{Name: "small"}, func(context.Context, json.RawMessage) (string, error) {
+		return strings.Repeat("x", 100), nil
+	})
+
+	res, convo, err := llm.RunTools(context.Background(), prompt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 返回的对话是完整的：user + 3 ×（调用 + 2 个结果）+ 最终回答
+	if res.Text != "done" || len(convo) != 11 || convo[0].Content != prompt[0].Content {
+		t.Fatalf("final %q, convo %d messages", res.Text, len(convo))
+	}
+
+	recordersMu.Lock()
+	rec := recorders["backup"]
+	recordersMu.Unlock()
+	if len(rec.reqs) != 4 {
+		t.Fatalf("backup received %d requests, want 4", len(rec.reqs))
+	}
+	last := rec.reqs[3]
+	if len(last) >= 10 {
+		t.Fatalf("last request has %d messages, want it truncated", len(last))
+	}
+	for i, req := range rec.reqs {
+		total := 0
+		calls := map[string]bool{}
+		for _, m := range req {
+			total += tokenizer.MessageTokens(charTokenizer{}, m)
+			for _, c := range m.ToolCalls {
+				calls[c.ID] = true
+			}
+			if m.Role == types.RoleTool && !calls[m.ToolCallID] {
+				t.Errorf("request %d: tool result %s without its assistant call", i+1, m.ToolCallID)
+			}
+		}
+		if total > maxCtx {
+			t.Errorf("request %d: %d tokens by the served target's tokenizer, limit %d", i+1, total, maxCtx)
+		}
+	}
+}
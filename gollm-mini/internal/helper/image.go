@@ -0,0 +1,41 @@
+package helper
+
+import (
+	"encoding/base64"
+	"net/http"
+	"os"
+	"strings"
+
+	"gollm-mini/internal/types"
+)
+
+// LoadImage 读取本地图片为 base64 片段；http(s) / data URL 直接透传
+func LoadImage(src string) (types.ContentPart, error) {
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") || strings.HasPrefix(src, "data:") {
+		return types.ImagePart(src)
+	}
+	b, err := os.ReadFile(src)
+	if err != nil {
+		return types.ContentPart{}, err
+	}
+	return types.ContentPart{
+		Type:      types.PartImage,
+		ImageData: base64.StdEncoding.EncodeToString(b),
+		MIMEType:  http.DetectContentType(b),
+	}, nil
+}
+
+// AttachImages 把图片追加到最后一条 user 消息（返回新切片，不修改入参）
+func AttachImages(msgs []types.Message, imgs []types.ContentPart) []types.Message {
+	if len(imgs) == 0 {
+		return msgs
+	}
+	out := append([]types.Message(nil), msgs...)
+	for i := len(out) - 1; i >= 0; i-- {
+		if out[i].Role == types.RoleUser {
+			out[i].Parts = append(append([]types.ContentPart(nil), out[i].Parts...), imgs...)
+			return out
+		}
+	}
+	return append(out, types.Message{Role: types.RoleUser, Parts: imgs})
+}
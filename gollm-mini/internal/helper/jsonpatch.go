@@ -0,0 +1,102 @@
+package helper
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PatchOp 一条 JSON Patch（RFC 6902）操作
+type PatchOp struct {
+	Op    string      `json:"op"` // add / remove / replace
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// MarshalJSON 除 remove 外总是带 value（值可能是 null）
+func (op PatchOp) MarshalJSON() ([]byte, error) {
+	if op.Op == "remove" {
+		return json.Marshal(struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+		}{op.Op, op.Path})
+	}
+	return json.Marshal(struct {
+		Op    string      `json:"op"`
+		Path  string      `json:"path"`
+		Value interface{} `json:"value"`
+	}{op.Op, op.Path, op.Value})
+}
+
+// DiffJSON 计算把 from 变成 to 的 JSON Patch；两者为 encoding/json 解码出的值（map / slice / 标量）。
+// from 为 nil 时返回对根的 add。对象按 key 排序比较，数组逐下标比较（尾部新增用 add，多余的从尾部 remove）。
+func DiffJSON(from, to interface{}) []PatchOp {
+	if from == nil {
+		return []PatchOp{{Op: "add", Path: "", Value: to}}
+	}
+	return diff(nil, "", from, to)
+}
+
+func diff(ops []PatchOp, path string, from, to interface{}) []PatchOp {
+	switch a := from.(type) {
+	case map[string]interface{}:
+		b, ok := to.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(a)+len(b))
+		for k := range a {
+			keys = append(keys, k)
+		}
+		for k := range b {
+			if _, ok := a[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			p := path + "/" + escapePointer(k)
+			av, inA := a[k]
+			bv, inB := b[k]
+			switch {
+			case !inB:
+				ops = append(ops, PatchOp{Op: "remove", Path: p})
+			case !inA:
+				ops = append(ops, PatchOp{Op: "add", Path: p, Value: bv})
+			default:
+				ops = diff(ops, p, av, bv)
+			}
+		}
+		return ops
+	case []interface{}:
+		b, ok := to.([]interface{})
+		if !ok {
+			break
+		}
+		n := len(a)
+		if len(b) < n {
+			n = len(b)
+		}
+		for i := 0; i < n; i++ {
+			ops = diff(ops, path+"/"+strconv.Itoa(i), a[i], b[i])
+		}
+		for i := n; i < len(b); i++ {
+			ops = append(ops, PatchOp{Op: "add", Path: path + "/" + strconv.Itoa(i), Value: b[i]})
+		}
+		for i := len(a) - 1; i >= n; i-- {
+			ops = append(ops, PatchOp{Op: "remove", Path: path + "/" + strconv.Itoa(i)})
+		}
+		return ops
+	}
+	if reflect.DeepEqual(from, to) {
+		return ops
+	}
+	return append(ops, PatchOp{Op: "replace", Path: path, Value: to})
+}
+
+// escapePointer JSON Pointer 转义：~ → ~0，/ → ~1
+func escapePointer(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
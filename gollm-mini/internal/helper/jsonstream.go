@@ -0,0 +1,171 @@
+package helper
+
+import "strings"
+
+// JSONStream 增量扫描流式输出的 JSON：逐块 Write，随时用 Partial 取得补齐后的 JSON 文本。
+// 扫描时顺带规范化（单引号字符串改为双引号、丢弃尾随逗号），JSON 起点之前与顶层值闭合之后的文字被忽略。
+// 每块只扫描新增内容，Partial 只复制当前状态并补齐结尾。
+type JSONStream struct {
+	out     []byte
+	stack   []jsonFrame
+	quote   byte // 当前字符串的引号，0 表示不在字符串中
+	escape  bool
+	started bool
+	done    bool
+}
+
+// jsonFrame 一层未闭合的对象 / 数组；对象记录当前成员的起点与进度，截断时据此丢弃半个成员
+type jsonFrame struct {
+	object bool
+	member int // 当前成员在 out 中的起点
+	state  int // 0 待 key，1 已有 key，2 已有冒号，3 已有值
+}
+
+// Write 追加一段输出
+func (s *JSONStream) Write(chunk string) {
+	for i := 0; i < len(chunk) && !s.done; i++ {
+		s.scan(chunk[i])
+	}
+}
+
+// Done 顶层值已闭合
+func (s *JSONStream) Done() bool { return s.done }
+
+// Partial 返回补齐字符串与括号后的 JSON 文本；尚未遇到 '{' / '[' 时返回 false。
+// 结尾处没写完的数字或字面量（如 "tr"）不会被补齐，调用方解析失败时等下一块即可。
+func (s *JSONStream) Partial() (string, bool) {
+	if !s.started {
+		return "", false
+	}
+	c := JSONStream{out: append([]byte(nil), s.out...), stack: append([]jsonFrame(nil), s.stack...)}
+	if s.quote != 0 {
+		c.out = trimPartialEscape(c.out)
+		c.out = append(c.out, '"')
+	}
+	for len(c.stack) > 0 {
+		c.closeFrame()
+	}
+	return string(c.out), true
+}
+
+func (s *JSONStream) scan(ch byte) {
+	if !s.started {
+		if ch != '{' && ch != '[' {
+			return
+		}
+		s.started = true
+	}
+	if s.quote != 0 {
+		switch {
+		case s.escape:
+			s.escape = false
+			if s.quote == '\'' && ch == '\'' {
+				s.out = append(s.out, '\'')
+			} else {
+				s.out = append(s.out, '\\', ch)
+			}
+		case ch == '\\':
+			s.escape = true
+		case ch == s.quote:
+			s.out = append(s.out, '"')
+			s.quote = 0
+		case s.quote == '\'' && ch == '"':
+			s.out = append(s.out, '\\', '"')
+		default:
+			s.out = append(s.out, ch)
+		}
+		return
+	}
+
+	switch ch {
+	case '"', '\'':
+		s.value()
+		s.quote = ch
+		s.out = append(s.out, '"')
+	case '{', '[':
+		s.value()
+		s.out = append(s.out, ch)
+		s.stack = append(s.stack, jsonFrame{object: ch == '{', member: len(s.out)})
+	case '}', ']':
+		s.closeFrame()
+		if len(s.stack) == 0 {
+			s.done = true // 顶层值结束，丢弃后面的说明文字
+		}
+	case ',':
+		s.out = append(s.out, ch)
+		if f := s.top(); f != nil && f.object {
+			f.member, f.state = len(s.out), 0
+		}
+	case ':':
+		s.out = append(s.out, ch)
+		if f := s.top(); f != nil && f.object && f.state == 1 {
+			f.state = 2
+		}
+	case ' ', '\t', '\r', '\n':
+		s.out = append(s.out, ch)
+	default:
+		s.value()
+		s.out = append(s.out, ch)
+	}
+}
+
+func (s *JSONStream) top() *jsonFrame {
+	if len(s.stack) == 0 {
+		return nil
+	}
+	return &s.stack[len(s.stack)-1]
+}
+
+// value 当前对象成员开始出现 key 或 value
+func (s *JSONStream) value() {
+	if f := s.top(); f != nil && f.object {
+		switch f.state {
+		case 0:
+			f.state = 1
+		case 2:
+			f.state = 3
+		}
+	}
+}
+
+func (s *JSONStream) closeFrame() {
+	f := s.stack[len(s.stack)-1]
+	s.stack = s.stack[:len(s.stack)-1]
+	if f.object && (f.state == 1 || f.state == 2) {
+		s.out = s.out[:f.member] // 只有 key 没有值
+	}
+	s.out = []byte(strings.TrimRight(string(s.out), " \t\r\n"))
+	if n := len(s.out); n > 0 && s.out[n-1] == ',' {
+		s.out = s.out[:n-1]
+	}
+	if f.object {
+		s.out = append(s.out, '}')
+	} else {
+		s.out = append(s.out, ']')
+	}
+}
+
+// trimPartialEscape 去掉字符串末尾没写完的 \uXXXX 转义
+func trimPartialEscape(b []byte) []byte {
+	i := len(b) - 1
+	for i >= 0 && len(b)-i <= 4 && isHex(b[i]) {
+		i--
+	}
+	if i >= 1 && b[i] == 'u' && b[i-1] == '\\' && len(b)-i-1 < 4 && !escaped(b, i-1) {
+		return b[:i-1]
+	}
+	return b
+}
+
+// escaped b[i] 之前是否有奇数个反斜杠（即 b[i] 本身被转义）
+func escaped(b []byte, i int) bool {
+	n := 0
+	for j := i - 1; j >= 0 && b[j] == '\\'; j-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
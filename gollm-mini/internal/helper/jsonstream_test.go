@@ -0,0 +1,152 @@
+package helper
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const streamDoc = "Here you go:\n```json\n" +
+	`{"name": "Tōkyō \"東京\"", "tags": ["capital", "megacity"], "geo": {"lat": 35.68, "lon": 139.69}, "ok": true, "note": null}` +
+	"\n```\nAnything else?"
+
+func TestJSONStreamPrefixes(t *testing.T) {
+	var final interface{}
+	if err := json.Unmarshal([]byte(streamDoc[strings.Index(streamDoc, "{"):strings.LastIndex(streamDoc, "}")+1]), &final); err != nil {
+		t.Fatal(err)
+	}
+
+	// 任意位置切块：每个快照要么是合法 JSON，要么只是结尾的数字 / 字面量没写完
+	for _, size := range []int{1, 2, 5, 17} {
+		var (
+			js     JSONStream
+			parsed int
+			last   interface{}
+		)
+		for i := 0; i < len(streamDoc); i += size {
+			js.Write(streamDoc[i:min(i+size, len(streamDoc))])
+			txt, ok := js.Partial()
+			if !ok {
+				continue
+			}
+			var v interface{}
+			if err := json.Unmarshal([]byte(txt), &v); err != nil {
+				tail := strings.TrimRight(txt, "]}")
+				if c := tail[len(tail)-1]; !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c == '.' || c == '-') {
+					t.Fatalf("size %d: snapshot %q: %v", size, txt, err)
+				}
+				continue
+			}
+			parsed++
+			last = v
+		}
+		if !js.Done() {
+			t.Errorf("size %d: top-level value not closed", size)
+		}
+		if parsed < 3 {
+			t.Errorf("size %d: only %d parsable snapshots", size, parsed)
+		}
+		if !reflect.DeepEqual(last, final) {
+			t.Errorf("size %d: final snapshot %v, want %v", size, last, final)
+		}
+	}
+}
+
+func TestJSONStreamEscapes(t *testing.T) {
+	var js JSONStream
+	js.Write(`{"s": "a\`)
+	if txt, _ := js.Partial(); txt != `{"s": "a"}` {
+		t.Errorf("pending escape: %q", txt)
+	}
+	js.Write(`u00e9 \u26`)
+	if txt, _ := js.Partial(); txt != `{"s": "a\u00e9 "}` {
+		t.Errorf("partial unicode escape: %q", txt)
+	}
+	js.Write(`05"}`)
+	txt, _ := js.Partial()
+	var v map[string]string
+	if err := json.Unmarshal([]byte(txt), &v); err != nil || v["s"] != "aé ★" {
+		t.Errorf("final %q: %v %v", txt, v, err)
+	}
+}
+
+func TestDiffJSON(t *testing.T) {
+	docs := []string{
+		`{}`,
+		`{"name":"To"}`,
+		`{"name":"Tokyo","tags":["cap"]}`,
+		`{"name":"Tokyo","tags":["capital","mega"],"geo":{"lat":35}}`,
+		`{"name":"Tokyo","tags":["capital"],"geo":{"lat":35,"lon":139},"a/b~":null}`,
+		`{"name":"Tokyo","geo":{"lat":35.6,"lon":139}}`,
+	}
+	var prev interface{}
+	var doc interface{}
+	for _, d := range docs {
+		var next interface{}
+		_ = json.Unmarshal([]byte(d), &next)
+		ops := DiffJSON(prev, next)
+		b, _ := json.Marshal(ops)
+		var decoded []PatchOp
+		_ = json.Unmarshal(b, &decoded) // 经过一次 JSON 编解码，与客户端看到的一致
+		doc = applyPatch(t, doc, decoded)
+		if !reflect.DeepEqual(doc, next) {
+			t.Fatalf("after %s: got %v, want %v", b, doc, next)
+		}
+		prev = next
+	}
+	if ops := DiffJSON(prev, prev); len(ops) != 0 {
+		t.Errorf("diff of equal docs = %v", ops)
+	}
+}
+
+// applyPatch 测试用的最小 JSON Patch 实现（add / replace / remove）
+func applyPatch(t *testing.T, doc interface{}, ops []PatchOp) interface{} {
+	t.Helper()
+	for _, op := range ops {
+		if op.Path == "" {
+			doc = op.Value
+			continue
+		}
+		parts := strings.Split(op.Path[1:], "/")
+		for i, p := range parts {
+			parts[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(p)
+		}
+		doc = applyAt(t, doc, parts, op)
+	}
+	return doc
+}
+
+func applyAt(t *testing.T, node interface{}, parts []string, op PatchOp) interface{} {
+	key := parts[0]
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if len(parts) > 1 {
+			n[key] = applyAt(t, n[key], parts[1:], op)
+		} else if op.Op == "remove" {
+			delete(n, key)
+		} else {
+			n[key] = op.Value
+		}
+		return n
+	case []interface{}:
+		i, err := strconv.Atoi(key)
+		if err != nil {
+			t.Fatalf("bad index %q", key)
+		}
+		switch {
+		case len(parts) > 1:
+			n[i] = applyAt(t, n[i], parts[1:], op)
+		case op.Op == "remove":
+			n = append(n[:i], n[i+1:]...)
+		case op.Op == "add":
+			n = append(n[:i], append([]interface{}{op.Value}, n[i:]...)...)
+		default:
+			n[i] = op.Value
+		}
+		return n
+	}
+	t.Fatalf("cannot apply %v to %v", op, node)
+	return nil
+}
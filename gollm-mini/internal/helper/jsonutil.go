@@ -2,7 +2,6 @@ package helper
 
 import (
 	"encoding/json"
-	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -17,23 +16,57 @@ func ParseJSON(raw string, v interface{}) error {
 	return json.Unmarshal([]byte(trim), v)
 }
 
-// ValidateJSONSchema 验证 bytes 是否符合 schema
+// SchemaError schema 校验失败；Details 为 gojsonschema 给出的逐条错误（"字段: 描述"）
+type SchemaError struct {
+	Details []string
+}
+
+func (e *SchemaError) Error() string {
+	return "schema invalid: " + strings.Join(e.Details, "; ")
+}
+
+// ValidateJSONSchema 验证 bytes 是否符合 schema 文件；不符合时返回 *SchemaError
 func ValidateJSONSchema(schemaPath string, data []byte) error {
 	absPath, err := filepath.Abs(schemaPath)
 	if err != nil {
 		return err
 	}
-	sl := gojsonschema.NewReferenceLoader("file://" + absPath)
-	dl := gojsonschema.NewBytesLoader(data)
-	res, err := gojsonschema.Validate(sl, dl)
+	return validate(gojsonschema.NewReferenceLoader("file://"+absPath), data)
+}
+
+// ValidateJSONSchemaBytes 同 ValidateJSONSchema，schema 直接给出（内联 / 注册表中的 schema）
+func ValidateJSONSchemaBytes(schema, data []byte) error {
+	return validate(gojsonschema.NewBytesLoader(schema), data)
+}
+
+func validate(sl gojsonschema.JSONLoader, data []byte) error {
+	res, err := gojsonschema.Validate(sl, gojsonschema.NewBytesLoader(data))
 	if err != nil {
 		return err
 	}
 	if !res.Valid() {
-		return fmt.Errorf("schema invalid: %v", res.Errors())
+		se := &SchemaError{}
+		for _, e := range res.Errors() {
+			se.Details = append(se.Details, e.String())
+		}
+		return se
 	}
 	return nil
 }
 
+// RepairJSON 本地修复模型输出中常见的 JSON 瑕疵：前后的说明文字 / Markdown 代码块、尾随逗号、
+// 单引号字符串、被截断的字符串与括号（丢弃没写完的对象成员后补齐）。
+// 返回修复结果以及是否有改动；找不到 JSON 起点时原样返回。
+func RepairJSON(raw string) (string, bool) {
+	src := strings.TrimSpace(raw)
+	var js JSONStream
+	js.Write(src)
+	fixed, ok := js.Partial()
+	if !ok {
+		return raw, false
+	}
+	return fixed, fixed != src
+}
+
 // LoadFile convenience
 func LoadFile(path string) ([]byte, error) { return os.ReadFile(path) }
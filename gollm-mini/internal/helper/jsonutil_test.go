@@ -0,0 +1,41 @@
+package helper
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRepairJSON(t *testing.T) {
+	cases := []struct {
+		name, in, want string
+	}{
+		{"valid", `{"a":1}`, `{"a":1}`},
+		{"fenced", "```json\n{\"a\":1}\n```", `{"a":1}`},
+		{"surrounding text", `Sure! {"a":1} Hope this helps.`, `{"a":1}`},
+		{"trailing commas", `{"a":[1,2,],"b":{"c":3,},}`, `{"a":[1,2],"b":{"c":3}}`},
+		{"single quotes", `{'a': 'it\'s "x"'}`, `{"a": "it's \"x\""}`},
+		{"truncated string", `{"a":"hel`, `{"a":"hel"}`},
+		{"truncated nested", `{"a":[{"b":1},{"c":2`, `{"a":[{"b":1},{"c":2}]}`},
+		{"dangling key", `{"a":1,"b":`, `{"a":1}`},
+		{"dangling comma", `{"a":1,`, `{"a":1}`},
+		{"array", `[1,2,`, `[1,2]`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, _ := RepairJSON(tc.in)
+			if got != tc.want {
+				t.Fatalf("RepairJSON(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+			if !json.Valid([]byte(got)) {
+				t.Fatalf("result %q is not valid JSON", got)
+			}
+		})
+	}
+
+	if _, changed := RepairJSON(`{"a":1}`); changed {
+		t.Error("valid JSON reported as changed")
+	}
+	if got, changed := RepairJSON("no json here"); changed || got != "no json here" {
+		t.Errorf("RepairJSON(no json) = %q, %v", got, changed)
+	}
+}
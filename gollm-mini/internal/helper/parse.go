@@ -1,8 +1,11 @@
 package helper
 
 import (
+	"fmt"
 	"regexp"
 	"strconv"
+	"strings"
+	"time"
 )
 
 func ParseFloat(s string) float64 {
@@ -17,3 +20,22 @@ func ParseFloat(s string) float64 {
 	}
 	return f
 }
+
+// ParseKeepAlive 解析 Ollama 风格的 keep_alive："10m" / "0"（立即卸载）/ "-1"（永久常驻）/ 纯数字按秒
+func ParseKeepAlive(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.Atoi(s); err == nil {
+		if n < 0 {
+			return -1, nil
+		}
+		return time.Duration(n) * time.Second, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid keep_alive %q", s)
+	}
+	if d < 0 {
+		return -1, nil
+	}
+	return d, nil
+}
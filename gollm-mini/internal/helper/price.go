@@ -1,17 +1,33 @@
 package helper
 
+import "sync"
+
 // 单位：USD / 1K tokens
 var priceTable = map[string]struct {
 	Prompt, Completion float64
 }{
 	"openai:gpt-4o-mini":   {0.005, 0.015},
 	"openai:gpt-3.5-turbo": {0.0005, 0.0015},
+	// Embedding 只按输入计费
+	"openai:text-embedding-3-small": {0.00002, 0},
+	"openai:text-embedding-3-large": {0.00013, 0},
 	// 本地 Ollama 视为 0
 }
 
+var priceMu sync.RWMutex
+
+// SetPrice 设置（或覆盖）某个模型的单价，单位 USD / 1K tokens
+func SetPrice(provider, model string, prompt, completion float64) {
+	priceMu.Lock()
+	defer priceMu.Unlock()
+	priceTable[provider+":"+model] = struct{ Prompt, Completion float64 }{prompt, completion}
+}
+
 func CalcCost(provider, model string, promptTok, compTok int) float64 {
 	key := provider + ":" + model
+	priceMu.RLock()
 	p, ok := priceTable[key]
+	priceMu.RUnlock()
 	if !ok {
 		return 0
 	}
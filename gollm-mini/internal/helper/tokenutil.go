@@ -1,23 +1,29 @@
 package helper
 
 import (
+	"gollm-mini/internal/tokenizer"
 	"gollm-mini/internal/types"
-	"unicode/utf8"
 )
 
-// RoughTokenCount ＝词数近似；80% 情况够用，后续可换 tiktoken
-func RoughTokenCount(s string) int {
-	return utf8.RuneCountInString(s) / 4
+// TruncateMessages 使用默认 tokenizer 截断，见 TruncateMessagesFor
+func TruncateMessages(msgs []types.Message, limit int) []types.Message {
+	return TruncateMessagesFor(tokenizer.Default(), msgs, limit)
 }
 
-// TruncateMessages 保留 history 尾部，直至 token 总量 ≤ limit
-func TruncateMessages(msgs []types.Message, limit int) []types.Message {
+// TruncateMessagesFor 保留 history 尾部，直至 token 总量（含每条消息的格式开销）≤ limit。
+// 带 ToolCalls 的 assistant 消息与其后的 tool 结果作为一组整体丢弃，
+// 不会留下缺少对应调用的 tool 消息（后端会拒绝这种序列）
+func TruncateMessagesFor(tok tokenizer.Tokenizer, msgs []types.Message, limit int) []types.Message {
 	var total int
 	// 从后往前累加
 	for i := len(msgs) - 1; i >= 0; i-- {
-		total += RoughTokenCount(msgs[i].Content)
+		total += tokenizer.MessageTokens(tok, msgs[i])
 		if total > limit {
-			return msgs[i+1:]
+			kept := msgs[i+1:]
+			for len(kept) > 0 && kept[0].Role == types.RoleTool {
+				kept = kept[1:]
+			}
+			return kept
 		}
 	}
 	return msgs
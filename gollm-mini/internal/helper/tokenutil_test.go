@@ -0,0 +1,42 @@
+package helper
+
+import (
+	"strings"
+	"testing"
+
+	"gollm-mini/internal/tokenizer"
+	"gollm-mini/internal/types"
+)
+
+func TestTruncateMessagesFor(t *testing.T) {
+	tok := tokenizer.Approx{}
+	long := strings.Repeat("word ", 100) // ≈ 125 token
+	call := types.Message{Role: types.RoleAssistant, ToolCalls: []types.ToolCall{{ID: "c1", Name: "a"}, {ID: "c2", Name: "b"}}}
+	msgs := []types.Message{
+		{Role: types.RoleUser, Content: long},
+		call,
+		{Role: types.RoleTool, ToolCallID: "c1", Content: long},
+		{Role: types.RoleTool, ToolCallID: "c2", Content: "short"},
+		{Role: types.RoleAssistant, Content: "done"},
+	}
+
+	if got := TruncateMessagesFor(tok, msgs, 10_000); len(got) != len(msgs) {
+		t.Errorf("within limit: kept %d of %d", len(got), len(msgs))
+	}
+
+	// 预算只够最后两条：c1 的结果已丢，c2 的结果不能单独留下
+	got := TruncateMessagesFor(tok, msgs, 60)
+	if len(got) != 1 || got[0].Content != "done" {
+		t.Errorf("kept %+v, want only the final answer", got)
+	}
+
+	// 预算够整组：assistant 调用与两条结果一起保留
+	budget := 0
+	for _, m := range msgs[1:] {
+		budget += tokenizer.MessageTokens(tok, m)
+	}
+	got = TruncateMessagesFor(tok, msgs, budget)
+	if len(got) != 4 || len(got[0].ToolCalls) != 2 {
+		t.Errorf("kept %d messages starting with %+v, want the whole tool group", len(got), got[0])
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	bolt "go.etcd.io/bbolt"
 	"gollm-mini/internal/helper"
+	"gollm-mini/internal/tokenizer"
 	"gollm-mini/internal/types"
 	"sync"
+	"time"
 )
 
 const (
@@ -16,23 +18,43 @@ const (
 
 var (
 	db   *bolt.DB
-	once sync.Once
+	dbMu sync.Mutex
 )
 
-func open() *bolt.DB {
-	once.Do(func() {
-		db, _ = bolt.Open(dbPath, 0600, nil)
-	})
-	return db
+// open 单例；打开失败不缓存错误，下次调用重试。带超时，文件被其他进程锁住时不会永久阻塞
+func open() (*bolt.DB, error) {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+	if db != nil {
+		return db, nil
+	}
+	d, err := bolt.Open(dbPath, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	db = d
+	return db, nil
+}
+
+// Ping 检查 memory.db 能否打开并开启读事务（用于就绪检查）
+func Ping() error {
+	db, err := open()
+	if err != nil {
+		return err
+	}
+	return db.View(func(tx *bolt.Tx) error { return nil })
 }
 
 func bucketName(id string) []byte { return []byte(bucketPrefix + id) }
 
-// Load returns history truncated to maxCtxTok tokens (oldest first)
-func Load(id string) ([]types.Message, error) {
+// Load returns history truncated to maxCtxTok tokens (oldest first), counted with tok
+func Load(id string, tok tokenizer.Tokenizer) ([]types.Message, error) {
 	var msgs []types.Message
-	db := open()
-	err := db.View(func(tx *bolt.Tx) error {
+	db, err := open()
+	if err != nil {
+		return nil, err
+	}
+	err = db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(bucketName(id))
 		if b == nil {
 			return nil
@@ -55,12 +77,16 @@ func Load(id string) ([]types.Message, error) {
 	})
 
 	// 截断
-	return helper.TruncateMessages(msgs, maxCtxTok), err
+	return helper.TruncateMessagesFor(tok, msgs, maxCtxTok), err
 }
 
 // Append writes user & assistant message pair
 func Append(sessionID string, msgs []types.Message) error {
-	return open().Update(func(tx *bolt.Tx) error {
+	db, err := open()
+	if err != nil {
+		return err
+	}
+	return db.Update(func(tx *bolt.Tx) error {
 		b, _ := tx.CreateBucketIfNotExists([]byte(bucketPrefix + sessionID))
 
 		// 读取旧历史
@@ -74,7 +100,11 @@ func Append(sessionID string, msgs []types.Message) error {
 }
 
 func Delete(sessionID string) error {
-	return open().Update(func(tx *bolt.Tx) error {
+	db, err := open()
+	if err != nil {
+		return err
+	}
+	return db.Update(func(tx *bolt.Tx) error {
 		return tx.DeleteBucket(bucketName(sessionID))
 	})
 }
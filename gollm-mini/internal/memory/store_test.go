@@ -0,0 +1,43 @@
+package memory
+
+import (
+	"os"
+	"testing"
+
+	"gollm-mini/internal/tokenizer"
+	"gollm-mini/internal/types"
+)
+
+func TestOpenRetriesAfterFailure(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Cleanup(func() {
+		if db != nil {
+			_ = db.Close()
+			db = nil
+		}
+	})
+
+	// memory.db 被一个目录占住：打开失败
+	if err := os.Mkdir(dbPath, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := Ping(); err == nil {
+		t.Fatal("Ping succeeded with memory.db as a directory")
+	}
+
+	// 问题排除后无需重启即可恢复
+	if err := os.Remove(dbPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := Ping(); err != nil {
+		t.Fatalf("Ping after recovery: %v", err)
+	}
+	msg := types.Message{Role: types.RoleUser, Content: "hi"}
+	if err := Append("s1", []types.Message{msg}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Load("s1", tokenizer.Approx{})
+	if err != nil || len(got) != 1 || got[0].Content != "hi" {
+		t.Fatalf("Load = %+v, %v", got, err)
+	}
+}
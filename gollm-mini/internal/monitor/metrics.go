@@ -44,6 +44,87 @@ var (
 		Name: "prompt_cache_miss_total", Help: "LLM prompt cache miss",
 	})
 
+	ToolCalls = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "llm_tool_calls_total",
+			Help: "Tool invocations requested by the model",
+		},
+		[]string{"tool", "status"},
+	)
+
+	EmbeddingTokens = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "llm_embedding_tokens_total",
+			Help: "Input tokens sent to embedding models",
+		},
+		[]string{"provider", "model"},
+	)
+
+	EmbeddingCost = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "llm_embedding_cost_usd_total",
+			Help: "Accumulated embedding cost (USD)",
+		},
+		[]string{"provider", "model"},
+	)
+
+	Fallbacks = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "llm_fallback_total",
+			Help: "Requests that fell through from one provider/model target to the next",
+		},
+		[]string{"from", "to", "endpoint"},
+	)
+
+	Served = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "llm_served_total",
+			Help: "Successful requests by the provider/model target that actually served them",
+		},
+		[]string{"provider", "model", "endpoint", "fallback"},
+	)
+
+	BreakerState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "llm_breaker_state",
+			Help: "Circuit breaker state per provider/model (0 = closed, 1 = half-open, 2 = open)",
+		},
+		[]string{"provider", "model"},
+	)
+
+	BreakerTransitions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "llm_breaker_transitions_total",
+			Help: "Circuit breaker state changes per provider/model",
+		},
+		[]string{"provider", "model", "to"},
+	)
+
+	RateLimitWait = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "llm_ratelimit_wait_seconds",
+			Help:    "Time spent waiting for client-side RPM/TPM quota",
+			Buckets: []float64{0.001, 0.01, 0.1, 0.5, 1, 5, 15, 30, 60},
+		},
+		[]string{"provider", "model"},
+	)
+
+	ComponentUp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "health_component_up",
+			Help: "Readiness probe result per component (1 = ok, 0 = down)",
+		},
+		[]string{"component"},
+	)
+
+	ComponentLatency = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "health_component_latency_seconds",
+			Help: "Latency of the last readiness probe per component",
+		},
+		[]string{"component"},
+	)
+
 	CompareLatency = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name:    "llm_compare_latency_seconds",
@@ -52,8 +133,18 @@ var (
 		},
 		[]string{"provider"},
 	)
+
+	StructuredAttempts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "llm_structured_attempts_total",
+			Help: "Structured-output generations by constraint mode (native / prompt) and result",
+		},
+		[]string{"provider", "mode", "result"},
+	)
 )
 
 func init() {
-	prometheus.MustRegister(Latency, Tokens, CostUSD, OptScore, CacheHit, CacheMiss, CompareLatency)
+	prometheus.MustRegister(Latency, Tokens, CostUSD, OptScore, CacheHit, CacheMiss, ToolCalls, EmbeddingTokens, EmbeddingCost,
+		Fallbacks, Served, BreakerState, BreakerTransitions, RateLimitWait, ComponentUp, ComponentLatency, CompareLatency,
+		StructuredAttempts)
 }
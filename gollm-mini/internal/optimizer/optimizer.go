@@ -41,7 +41,10 @@ func RunVariants(
 	只返回最终综合分数，其他文字省略。
 	`
 
-	recDB, _ := Open("optimize.db") // 评分落库
+	recDB, dbErr := Open("optimize.db") // 评分落库；打不开时只跳过落库
+	if dbErr == nil {
+		defer recDB.Close()
+	}
 	scores = map[string]float64{}
 	answers = map[string]string{}
 	latencies = map[string]float64{}
@@ -77,11 +80,12 @@ func RunVariants(
 		}
 
 		start := time.Now()
-		answer, _, e := llm.Generate(ctx, msgs)
+		res, e := llm.Generate(ctx, msgs)
 		if e != nil {
 			err = e
 			return
 		}
+		answer := res.Text
 		lat := time.Since(start).Seconds()
 
 		answers[key] = answer
@@ -90,7 +94,7 @@ func RunVariants(
 
 		// 3. 评分
 		scorePrompt := fmt.Sprintf("Question:%s\nAnswer:%s\nScore:", question, answer)
-		scoreTxt, _, e := judgeLLM.Generate(ctx, append(judgePrompt, types.Message{
+		judged, e := judgeLLM.Generate(ctx, append(judgePrompt, types.Message{
 			Role: types.RoleUser, Content: scorePrompt,
 		}))
 
@@ -98,11 +102,14 @@ func RunVariants(
 			err = e
 			return
 		}
-		sc := helper.ParseFloat(scoreTxt)
+		sc := helper.ParseFloat(judged.Text)
 		scores[key] = sc
 		monitor.OptScore.WithLabelValues(v.Provider, v.TplName).Observe(sc)
 
 		// 4. 落库
+		if dbErr != nil {
+			continue
+		}
 		_ = recDB.Save(Record{
 			VariantKey: key,
 			Input:      question,
@@ -0,0 +1,96 @@
+package optimizer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gollm-mini/internal/provider/replay"
+	"gollm-mini/internal/template"
+
+	_ "gollm-mini/internal/provider/ollama"
+)
+
+// 默认回放仓库根目录的 testdata/cassettes（core / server / optimizer 共用）；
+// GOLLM_REPLAY=record go test 可对真实后端重新录制
+func TestMain(m *testing.M) {
+	mode := replay.ModeReplay
+	if v := os.Getenv("GOLLM_REPLAY"); v != "" {
+		var err error
+		if mode, err = replay.ParseMode(v); err != nil {
+			panic(err)
+		}
+	}
+	// 测试会切换工作目录（optimize.db 写在当前目录），cassette 路径需固定为绝对路径
+	cassettes, err := filepath.Abs("../../testdata/cassettes")
+	if err != nil {
+		panic(err)
+	}
+	if err := replay.Install(cassettes, mode, "ollama"); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestRunVariantsPicksBest(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	store, err := template.Open("templates.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	for _, tpl := range []template.Template{
+		{Name: "explain", Version: 1, Content: "Explain {{.input}}.", Vars: []string{"input"}},
+		{Name: "explain", Version: 2, Content: "Explain {{.input}} in one short sentence.", Vars: []string{"input"}},
+	} {
+		if err := store.Save(tpl); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	variants := []Variant{
+		{Provider: "ollama", Model: "llama3", TplName: "explain", Version: 1},
+		{Provider: "ollama", Model: "llama3", TplName: "explain", Version: 2},
+	}
+	best, scores, answers, latencies, err := RunVariants(context.Background(), variants,
+		map[string]string{"input": "Go"}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if best != variants[1] {
+		t.Errorf("best = %+v, want version 2 (scores %v)", best, scores)
+	}
+	if scores[variants[0].Key()] != 6 || scores[variants[1].Key()] != 8 {
+		t.Errorf("scores = %v", scores)
+	}
+	for _, v := range variants {
+		if answers[v.Key()] == "" {
+			t.Errorf("missing answer for %s", v.Key())
+		}
+		if _, ok := latencies[v.Key()]; !ok {
+			t.Errorf("missing latency for %s", v.Key())
+		}
+	}
+	if _, err := os.Stat("optimize.db"); err != nil {
+		t.Errorf("scores not persisted: %v", err)
+	}
+}
+
+func TestRunVariantsMissingTemplate(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	store, err := template.Open("templates.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	_, _, _, _, err = RunVariants(context.Background(),
+		[]Variant{{Provider: "ollama", Model: "llama3", TplName: "nope"}}, nil, store)
+	if err == nil {
+		t.Fatal("expected an error for an unknown template")
+	}
+}
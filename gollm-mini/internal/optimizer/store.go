@@ -31,6 +31,9 @@ func Open(path string) (*Store, error) {
 	return &Store{db: db}, err
 }
 
+// Close 释放 bbolt 文件锁
+func (s *Store) Close() error { return s.db.Close() }
+
 func (s *Store) Save(rec Record) error {
 	return s.db.Update(func(tx *bolt.Tx) error {
 		b, _ := tx.CreateBucketIfNotExists([]byte(recordBucket))
@@ -0,0 +1,346 @@
+package anthropic
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"gollm-mini/internal/provider"
+	"gollm-mini/internal/types"
+)
+
+const (
+	defaultBaseURL   = "https://api.anthropic.com"
+	apiVersion       = "2023-06-01"
+	defaultMaxTokens = 1024 // Messages API 要求必须给出 max_tokens
+)
+
+// Anthropic 基于 Messages API（/v1/messages）实现 Provider
+type Anthropic struct {
+	client  *http.Client
+	apiKey  string
+	model   string
+	baseURL string
+}
+
+// ---------------------------------------------------------------------
+// 构造 & 配置
+// ---------------------------------------------------------------------
+
+func New(model string) *Anthropic {
+	base := os.Getenv("ANTHROPIC_BASE_URL") // 允许覆盖（代理 / 测试桩）
+	if base == "" {
+		base = defaultBaseURL
+	}
+	return &Anthropic{
+		client:  &http.Client{Timeout: 120 * time.Second},
+		apiKey:  os.Getenv("ANTHROPIC_API_KEY"),
+		model:   model,
+		baseURL: strings.TrimRight(base, "/"),
+	}
+}
+
+// Model 实际使用的模型名
+func (a *Anthropic) Model() string { return a.model }
+
+// ---------------------------------------------------------------------
+// 请求 / 响应结构
+// ---------------------------------------------------------------------
+
+// message.Content 为纯文本 string，或多模态时的 []block
+type message struct {
+	Role    string `json:"role"`
+	Content any    `json:"content"`
+}
+
+type block struct {
+	Type   string       `json:"type"`
+	Text   string       `json:"text,omitempty"`
+	Source *imageSource `json:"source,omitempty"`
+}
+
+type imageSource struct {
+	Type      string `json:"type"` // base64 / url
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+type request struct {
+	Model         string    `json:"model"`
+	MaxTokens     int       `json:"max_tokens"`
+	System        string    `json:"system,omitempty"`
+	Messages      []message `json:"messages"`
+	Stream        bool      `json:"stream,omitempty"`
+	Temperature   *float64  `json:"temperature,omitempty"`
+	TopP          *float64  `json:"top_p,omitempty"`
+	StopSequences []string  `json:"stop_sequences,omitempty"`
+}
+
+type usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type response struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Usage usage `json:"usage"`
+}
+
+type apiError struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// streamEvent 覆盖 SSE 中会用到的所有事件字段
+type streamEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		Usage usage `json:"usage"`
+	} `json:"message"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+	Usage usage `json:"usage"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// ---------------------------------------------------------------------
+// 非流式
+// ---------------------------------------------------------------------
+
+func (a *Anthropic) Generate(ctx context.Context, msgs []types.Message, opts types.GenerateOptions) (string, types.Usage, error) {
+	r, err := a.buildRequest(msgs, opts, false)
+	if err != nil {
+		return "", types.Usage{}, err
+	}
+	resp, err := a.do(ctx, r)
+	if err != nil {
+		return "", types.Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	var out response
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		// 响应体损坏是服务端 / 传输问题，不是调用方的错误：保留原始错误，由 Transport 判断能否重试
+		return "", types.Usage{}, provider.FromTransport("anthropic", fmt.Errorf("decode anthropic response: %w", err))
+	}
+
+	var sb strings.Builder
+	for _, c := range out.Content {
+		if c.Type == "text" {
+			sb.WriteString(c.Text)
+		}
+	}
+	u := types.Usage{
+		PromptTokens:     out.Usage.InputTokens,
+		CompletionTokens: out.Usage.OutputTokens,
+	}
+	return sb.String(), u, nil
+}
+
+// ---------------------------------------------------------------------
+// 流式：解析 SSE 事件
+// ---------------------------------------------------------------------
+
+func (a *Anthropic) Stream(ctx context.Context, msgs []types.Message, opts types.GenerateOptions, cb func(types.Chunk)) (types.Usage, error) {
+	r, err := a.buildRequest(msgs, opts, true)
+	if err != nil {
+		return types.Usage{}, err
+	}
+	resp, err := a.do(ctx, r)
+	if err != nil {
+		return types.Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	var u types.Usage
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue // 忽略 event: / 空行 / 注释
+		}
+		var ev streamEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(line[5:])), &ev); err != nil {
+			return u, fmt.Errorf("decode anthropic event: %w", err) // 同上，不归为调用方错误
+		}
+
+		switch ev.Type {
+		case "message_start":
+			u.PromptTokens = ev.Message.Usage.InputTokens
+			u.CompletionTokens = ev.Message.Usage.OutputTokens
+		case "content_block_delta":
+			if ev.Delta.Type == "text_delta" && ev.Delta.Text != "" {
+				cb(types.Chunk{Content: ev.Delta.Text, Delta: 1})
+			}
+		case "message_delta":
+			// output_tokens 为累计值
+			u.CompletionTokens = ev.Usage.OutputTokens
+		case "error":
+			return u, provider.Classify("anthropic", ev.Error.Type, fmt.Sprintf("anthropic stream %s: %s", ev.Error.Type, ev.Error.Message))
+		case "message_stop":
+			return u, nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return u, provider.FromTransport("anthropic", err)
+	}
+	// 连接在 message_stop 之前结束：回答被截断，不能当作成功（尚未发出 chunk 时可重试）
+	return u, fmt.Errorf("anthropic stream ended before message_stop: %w", io.ErrUnexpectedEOF)
+}
+
+// ---------------------------------------------------------------------
+// 辅助函数
+// ---------------------------------------------------------------------
+
+// buildRequest 把 system 消息抽到顶层 system 字段，其余按顺序放进 messages
+func (a *Anthropic) buildRequest(msgs []types.Message, opts types.GenerateOptions, stream bool) (*request, error) {
+	if opts.Temperature != nil && *opts.Temperature > 1 {
+		return nil, &provider.Error{Kind: provider.ErrInvalidRequest, Provider: "anthropic",
+			Err: fmt.Errorf("temperature must be within [0, 1], got %v", *opts.Temperature)}
+	}
+	if opts.Seed != nil {
+		provider.WarnUnsupported("anthropic", "seed")
+	}
+
+	var (
+		sys []string
+		out = make([]message, 0, len(msgs))
+	)
+	for _, m := range msgs {
+		switch m.Role {
+		case types.RoleSystem:
+			sys = append(sys, m.Text())
+			continue
+		case types.RoleTool:
+			// Messages API 只接受 user / assistant；本 Provider 不支持工具调用，也就没有可对应的 tool_use
+			return nil, &provider.Error{Kind: provider.ErrInvalidRequest, Provider: "anthropic",
+				Err: errors.New("anthropic provider does not support tool messages")}
+		}
+		out = append(out, message{Role: string(m.Role), Content: toContent(m)})
+	}
+	r := &request{
+		Model:         a.model,
+		MaxTokens:     defaultMaxTokens,
+		System:        strings.Join(sys, "\n\n"),
+		Messages:      out,
+		Stream:        stream,
+		Temperature:   opts.Temperature,
+		TopP:          opts.TopP,
+		StopSequences: opts.Stop,
+	}
+	if opts.MaxTokens > 0 {
+		r.MaxTokens = opts.MaxTokens
+	}
+	return r, nil
+}
+
+// toContent 无图片时直接用字符串，否则转换为 text / image block 数组
+func toContent(m types.Message) any {
+	if len(m.Parts) == 0 {
+		return m.Content
+	}
+	var blocks []block
+	if m.Content != "" {
+		blocks = append(blocks, block{Type: "text", Text: m.Content})
+	}
+	for _, p := range m.Parts {
+		switch p.Type {
+		case types.PartText:
+			blocks = append(blocks, block{Type: "text", Text: p.Text})
+		case types.PartImage:
+			src := &imageSource{Type: "base64", MediaType: p.MediaType(), Data: p.ImageData}
+			if p.ImageURL != "" {
+				src = &imageSource{Type: "url", URL: p.ImageURL}
+			}
+			blocks = append(blocks, block{Type: "image", Source: src})
+		}
+	}
+	return blocks
+}
+
+var errNoKey = &provider.Error{Kind: provider.ErrAuth, Provider: "anthropic", Err: errors.New("ANTHROPIC_API_KEY not set")}
+
+// do 发送 Messages 请求
+func (a *Anthropic) do(ctx context.Context, r *request) (*http.Response, error) {
+	if a.apiKey == "" {
+		return nil, errNoKey
+	}
+	body, _ := json.Marshal(r)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", a.baseURL+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.Stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+	return a.send(req)
+}
+
+// HealthCheck 用 GET /v1/models?limit=1 验证地址与 key，不消耗 token
+func (a *Anthropic) HealthCheck(ctx context.Context) error {
+	if a.apiKey == "" {
+		return errNoKey
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", a.baseURL+"/v1/models?limit=1", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := a.send(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// send 附加鉴权头并发送；非 2xx 时解析错误体并返回
+func (a *Anthropic) send(req *http.Request) (*http.Response, error) {
+	req.Header.Set("x-api-key", a.apiKey)
+	req.Header.Set("anthropic-version", apiVersion)
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, provider.FromTransport("anthropic", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		raw, _ := io.ReadAll(resp.Body)
+		var ae apiError
+		if json.Unmarshal(raw, &ae) == nil && ae.Error.Message != "" {
+			err = fmt.Errorf("anthropic API %s: %s: %s", resp.Status, ae.Error.Type, ae.Error.Message)
+			return nil, provider.FromStatus("anthropic", resp.StatusCode, resp.Header, ae.Error.Message, err)
+		}
+		err = fmt.Errorf("anthropic API %s", resp.Status)
+		return nil, provider.FromStatus("anthropic", resp.StatusCode, resp.Header, string(raw), err)
+	}
+	return resp, nil
+}
+
+// ---------------------------------------------------------------------
+// 注册到 provider 工厂
+// ---------------------------------------------------------------------
+
+func init() {
+	provider.Register("anthropic", provider.Simple("claude-3-5-haiku-latest", New))
+}
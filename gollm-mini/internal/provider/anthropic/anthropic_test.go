@@ -0,0 +1,227 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gollm-mini/internal/provider"
+	"gollm-mini/internal/types"
+)
+
+// newStub 启动一个模拟 Messages API 的 httptest 服务，并把请求体交给 check
+func newStub(t *testing.T, check func(r request), reply func(w http.ResponseWriter, r request)) *Anthropic {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/v1/messages" {
+			http.NotFound(w, req)
+			return
+		}
+		if req.Header.Get("x-api-key") != "test-key" || req.Header.Get("anthropic-version") != apiVersion {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"type":"error","error":{"type":"authentication_error","message":"bad key"}}`)
+			return
+		}
+		var r request
+		if err := json.NewDecoder(req.Body).Decode(&r); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		check(r)
+		reply(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	t.Setenv("ANTHROPIC_API_KEY", "test-key")
+	t.Setenv("ANTHROPIC_BASE_URL", srv.URL)
+	return New("claude-test")
+}
+
+var conversation = []types.Message{
+	{Role: types.RoleSystem, Content: "be brief"},
+	{Role: types.RoleUser, Content: "hi"},
+	{Role: types.RoleAssistant, Content: "hello"},
+	{Role: types.RoleUser, Content: "how are you?"},
+}
+
+func checkMapping(t *testing.T, stream bool) func(r request) {
+	return func(r request) {
+		if r.System != "be brief" {
+			t.Errorf("system = %q, want top-level system", r.System)
+		}
+		if len(r.Messages) != 3 || r.Messages[0].Role != "user" || r.Messages[1].Role != "assistant" {
+			t.Errorf("messages = %+v", r.Messages)
+		}
+		if r.Model != "claude-test" || r.MaxTokens <= 0 || r.Stream != stream {
+			t.Errorf("request = %+v", r)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	a := newStub(t, checkMapping(t, false), func(w http.ResponseWriter, _ request) {
+		fmt.Fprint(w, `{"content":[{"type":"text","text":"fine, "},{"type":"text","text":"thanks"}],
+			"usage":{"input_tokens":12,"output_tokens":3}}`)
+	})
+
+	txt, u, err := a.Generate(context.Background(), conversation, types.GenerateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txt != "fine, thanks" {
+		t.Errorf("text = %q", txt)
+	}
+	if u.PromptTokens != 12 || u.CompletionTokens != 3 {
+		t.Errorf("usage = %+v", u)
+	}
+}
+
+func TestStream(t *testing.T) {
+	events := []string{
+		`{"type":"message_start","message":{"usage":{"input_tokens":12,"output_tokens":1}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"ping"}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"fine"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":", thanks"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":4}}`,
+		`{"type":"message_stop"}`,
+	}
+	a := newStub(t, checkMapping(t, true), func(w http.ResponseWriter, _ request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, ev := range events {
+			var head struct{ Type string }
+			_ = json.Unmarshal([]byte(ev), &head)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", head.Type, ev)
+		}
+	})
+
+	var sb strings.Builder
+	u, err := a.Stream(context.Background(), conversation, types.GenerateOptions{}, func(ch types.Chunk) {
+		sb.WriteString(ch.Content)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sb.String() != "fine, thanks" {
+		t.Errorf("streamed = %q", sb.String())
+	}
+	if u.PromptTokens != 12 || u.CompletionTokens != 4 {
+		t.Errorf("usage = %+v", u)
+	}
+}
+
+func TestStreamTruncated(t *testing.T) {
+	a := newStub(t, func(request) {}, func(w http.ResponseWriter, _ request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":12}}}\n\n")
+		fmt.Fprint(w, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"fine\"}}\n\n")
+		// 没有 message_stop 就断开
+	})
+
+	var sb strings.Builder
+	_, err := a.Stream(context.Background(), conversation, types.GenerateOptions{}, func(ch types.Chunk) {
+		sb.WriteString(ch.Content)
+	})
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("err = %v, want io.ErrUnexpectedEOF", err)
+	}
+	if !provider.Retryable(err) {
+		t.Error("truncated stream should be retryable")
+	}
+	if sb.String() != "fine" {
+		t.Errorf("streamed = %q", sb.String())
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	a := newStub(t, func(request) {}, func(w http.ResponseWriter, _ request) {})
+	a.apiKey = "wrong"
+
+	_, _, err := a.Generate(context.Background(), conversation, types.GenerateOptions{})
+	if err == nil || !strings.Contains(err.Error(), "authentication_error") {
+		t.Fatalf("err = %v, want authentication_error", err)
+	}
+	if !errors.Is(err, provider.ErrAuth) || provider.Retryable(err) {
+		t.Fatalf("err = %v, want non-retryable provider.ErrAuth", err)
+	}
+}
+
+func TestBuildRequestOptions(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	seed := 7
+	cases := []struct {
+		name    string
+		in      types.GenerateOptions
+		want    request // 只比较采样相关字段
+		wantErr bool
+	}{
+		{"defaults", types.GenerateOptions{}, request{MaxTokens: defaultMaxTokens}, false},
+		{"all", types.GenerateOptions{Temperature: f(0), TopP: f(0.9), MaxTokens: 64, Stop: []string{"##"}},
+			request{MaxTokens: 64, Temperature: f(0), TopP: f(0.9), StopSequences: []string{"##"}}, false},
+		{"seed ignored", types.GenerateOptions{Seed: &seed}, request{MaxTokens: defaultMaxTokens}, false},
+		{"temperature above 1", types.GenerateOptions{Temperature: f(1.5)}, request{}, true},
+	}
+	a := New("claude-test")
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := a.buildRequest(conversation, tc.in, false)
+			if tc.wantErr {
+				if !errors.Is(err, provider.ErrInvalidRequest) {
+					t.Errorf("err = %v, want ErrInvalidRequest", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := request{MaxTokens: r.MaxTokens, Temperature: r.Temperature, TopP: r.TopP, StopSequences: r.StopSequences}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("request = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDecodeFailureIsNotClientError(t *testing.T) {
+	cases := []struct {
+		name      string
+		reply     func(w http.ResponseWriter)
+		retryable bool
+	}{
+		{"malformed body", func(w http.ResponseWriter) { fmt.Fprint(w, `{"content": nope}`) }, false},
+		{"cut off body", func(w http.ResponseWriter) {
+			w.Header().Set("Content-Length", "100")
+			fmt.Fprint(w, `{"content":[`)
+		}, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			a := newStub(t, func(request) {}, func(w http.ResponseWriter, _ request) { tc.reply(w) })
+			_, _, err := a.Generate(context.Background(), conversation, types.GenerateOptions{})
+			if err == nil || provider.ClientError(err) {
+				t.Fatalf("err = %v, want a non-client error", err)
+			}
+			if provider.Retryable(err) != tc.retryable {
+				t.Errorf("Retryable(%v) = %v, want %v", err, !tc.retryable, tc.retryable)
+			}
+		})
+	}
+}
+
+func TestRejectToolMessages(t *testing.T) {
+	a := newStub(t, func(request) { t.Error("request sent") }, func(http.ResponseWriter, request) {})
+	msgs := append(conversation[:len(conversation):len(conversation)], types.Message{Role: types.RoleTool, ToolCallID: "c1", Content: "12:00"})
+	if _, _, err := a.Generate(context.Background(), msgs, types.GenerateOptions{}); !errors.Is(err, provider.ErrInvalidRequest) {
+		t.Errorf("Generate err = %v, want ErrInvalidRequest", err)
+	}
+	if _, err := a.Stream(context.Background(), msgs, types.GenerateOptions{}, func(types.Chunk) {}); !errors.Is(err, provider.ErrInvalidRequest) {
+		t.Errorf("Stream err = %v, want ErrInvalidRequest", err)
+	}
+}
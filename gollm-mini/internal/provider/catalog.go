@@ -0,0 +1,101 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"gollm-mini/internal/types"
+)
+
+// ErrNoModelList Provider 未实现 ModelLister
+var ErrNoModelList = errors.New("provider does not support model listing")
+
+// defaultModelsTTL 模型列表缓存时间，可用 GOLLM_MODELS_TTL 覆盖（如 "30s"、"10m"）
+const defaultModelsTTL = 5 * time.Minute
+
+// Info 描述一个已注册 Provider 支持的可选能力
+type Info struct {
+	Name       string `json:"name"`
+	Streaming  bool   `json:"streaming"`
+	Tools      bool   `json:"tools"`
+	Embeddings bool   `json:"embeddings"`
+	ListModels bool   `json:"list_models"`
+	Manage     bool   `json:"manage_models"` // 支持拉取 / 删除 / 常驻控制
+}
+
+type catalogEntry struct {
+	models  []types.ModelInfo
+	fetched time.Time
+}
+
+var catalog = struct {
+	sync.Mutex
+	entries map[string]catalogEntry
+}{entries: map[string]catalogEntry{}}
+
+// Describe 用默认模型实例探测 Provider 实现了哪些可选接口
+func Describe(name string) (Info, error) {
+	p, err := Get(name, "")
+	if err != nil {
+		return Info{}, err
+	}
+	info := Info{Name: name, Streaming: true} // Stream 是 Provider 接口的一部分
+	_, info.Tools = As[ToolCaller](p)
+	_, info.Embeddings = As[Embedder](p)
+	_, info.ListModels = As[ModelLister](p)
+	_, info.Manage = As[ModelManager](p)
+	return info, nil
+}
+
+// Models 返回 Provider 的模型列表；结果按 TTL 缓存，refresh=true 时强制重新拉取
+func Models(ctx context.Context, name string, refresh bool) ([]types.ModelInfo, error) {
+	catalog.Lock()
+	e, ok := catalog.entries[name]
+	catalog.Unlock()
+	if ok && !refresh && time.Since(e.fetched) < modelsTTL() {
+		return e.models, nil
+	}
+
+	p, err := Get(name, "")
+	if err != nil {
+		return nil, err
+	}
+	ml, ok := As[ModelLister](p)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", name, ErrNoModelList)
+	}
+	models, err := ml.ListModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	catalog.Lock()
+	catalog.entries[name] = catalogEntry{models: models, fetched: time.Now()}
+	catalog.Unlock()
+	return models, nil
+}
+
+// InvalidateModels 丢弃缓存的模型列表（拉取 / 删除模型后调用）
+func InvalidateModels(name string) {
+	catalog.Lock()
+	delete(catalog.entries, name)
+	catalog.Unlock()
+}
+
+func modelsTTL() time.Duration {
+	v := os.Getenv("GOLLM_MODELS_TTL")
+	if v == "" {
+		return defaultModelsTTL
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("[WARN] invalid GOLLM_MODELS_TTL %q: %v", v, err)
+		return defaultModelsTTL
+	}
+	return d
+}
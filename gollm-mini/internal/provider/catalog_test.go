@@ -0,0 +1,96 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gollm-mini/internal/types"
+)
+
+// lister 统计 ListModels 调用次数，每次返回带序号的模型名
+type lister struct {
+	stub
+	calls *atomic.Int64
+}
+
+func (l *lister) ListModels(context.Context) ([]types.ModelInfo, error) {
+	n := l.calls.Add(1)
+	return []types.ModelInfo{{ID: string(rune('a' + n - 1))}}, nil
+}
+
+func newCountingLister(t *testing.T) (string, *atomic.Int64) {
+	t.Helper()
+	name := "catalog-" + t.Name()
+	var calls atomic.Int64
+	Register(name, Simple("default", func(m string) *lister { return &lister{stub: stub{model: m}, calls: &calls} }))
+	t.Cleanup(func() { InvalidateModels(name) })
+	return name, &calls
+}
+
+func TestModelsCache(t *testing.T) {
+	t.Setenv("GOLLM_MODELS_TTL", "1h")
+	name, calls := newCountingLister(t)
+	ctx := context.Background()
+
+	first, err := Models(ctx, name, false)
+	if err != nil || len(first) != 1 || first[0].ID != "a" {
+		t.Fatalf("first = %v, %v", first, err)
+	}
+	// TTL 内命中缓存
+	if got, _ := Models(ctx, name, false); got[0].ID != "a" || calls.Load() != 1 {
+		t.Errorf("cached = %v after %d calls, want a cache hit", got, calls.Load())
+	}
+	// refresh=true 强制重新拉取，并更新缓存
+	if got, _ := Models(ctx, name, true); got[0].ID != "b" || calls.Load() != 2 {
+		t.Errorf("refresh = %v after %d calls", got, calls.Load())
+	}
+	if got, _ := Models(ctx, name, false); got[0].ID != "b" || calls.Load() != 2 {
+		t.Errorf("after refresh = %v after %d calls, want the refreshed entry", got, calls.Load())
+	}
+	// InvalidateModels 丢弃缓存
+	InvalidateModels(name)
+	if got, _ := Models(ctx, name, false); got[0].ID != "c" || calls.Load() != 3 {
+		t.Errorf("after invalidate = %v after %d calls", got, calls.Load())
+	}
+}
+
+func TestModelsCacheExpiry(t *testing.T) {
+	t.Setenv("GOLLM_MODELS_TTL", "30ms")
+	name, calls := newCountingLister(t)
+	ctx := context.Background()
+
+	_, _ = Models(ctx, name, false)
+	_, _ = Models(ctx, name, false)
+	if calls.Load() != 1 {
+		t.Fatalf("calls = %d before expiry, want 1", calls.Load())
+	}
+	time.Sleep(40 * time.Millisecond)
+	if got, _ := Models(ctx, name, false); got[0].ID != "b" || calls.Load() != 2 {
+		t.Errorf("after expiry = %v after %d calls, want a refetch", got, calls.Load())
+	}
+}
+
+func TestModelsTTL(t *testing.T) {
+	cases := map[string]time.Duration{
+		"":      defaultModelsTTL,
+		"10m":   10 * time.Minute,
+		"0s":    0, // 不缓存
+		"later": defaultModelsTTL,
+	}
+	for v, want := range cases {
+		t.Setenv("GOLLM_MODELS_TTL", v)
+		if got := modelsTTL(); got != want {
+			t.Errorf("GOLLM_MODELS_TTL=%q: ttl = %v, want %v", v, got, want)
+		}
+	}
+}
+
+func TestModelsWithoutLister(t *testing.T) {
+	Register("catalog-plain", Simple("default", func(m string) *stub { return &stub{model: m} }))
+	if _, err := Models(context.Background(), "catalog-plain", false); !errors.Is(err, ErrNoModelList) {
+		t.Errorf("err = %v, want ErrNoModelList", err)
+	}
+}
@@ -0,0 +1,173 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// 错误分类：各 Provider 把 HTTP / SDK 错误映射到这些哨兵值，
+// core 据此决定是否重试、等待多久，用 errors.Is 判断。
+var (
+	ErrAuth           = errors.New("authentication failed")   // 401 / 403，key 无效或无权限
+	ErrRateLimited    = errors.New("rate limited")            // 429，可能携带 Retry-After
+	ErrContextLength  = errors.New("context length exceeded") // 输入超出模型上下文
+	ErrModelNotFound  = errors.New("model not found")         // 404 / 模型未拉取
+	ErrOverloaded     = errors.New("provider overloaded")     // 5xx / 529 / HF 模型加载中
+	ErrTimeout        = errors.New("request timed out")       // 408 / 504 / 网络超时
+	ErrInvalidRequest = errors.New("invalid request")         // 其余 4xx：参数错误，重试无意义
+)
+
+// Error 带分类的 Provider 错误
+type Error struct {
+	Kind       error         // 上面的哨兵之一
+	Provider   string        // 产生错误的 Provider
+	StatusCode int           // HTTP 状态码（未知为 0）
+	RetryAfter time.Duration // 服务端建议的等待时间（Retry-After / estimated_time）
+	Err        error         // 原始错误
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %v: %v", e.Provider, e.Kind, e.Err)
+}
+
+// Unwrap 同时暴露分类与原始错误，errors.Is(err, ErrAuth) 与 errors.As(err, *SDKError) 均可用
+func (e *Error) Unwrap() []error { return []error{e.Kind, e.Err} }
+
+// Retryable 该类错误是否值得在同一目标上重试：只有限流 / 过载 / 超时与网络层错误。
+// 未分类的错误默认不重试，避免把编码错误、参数错误等当作瞬时故障反复调用。
+func Retryable(err error) bool {
+	switch {
+	case err == nil, errors.Is(err, context.Canceled):
+		return false
+	case errors.Is(err, ErrRateLimited), errors.Is(err, ErrOverloaded), errors.Is(err, ErrTimeout):
+		return true
+	}
+	return Transport(err)
+}
+
+// Transport 是否为网络层错误：net.Error（含连接被拒、重置）、连接中途断开
+func Transport(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// ClientError 是否为调用方自身的问题（请求参数、上下文过长），不应计入后端健康统计
+func ClientError(err error) bool {
+	return errors.Is(err, ErrContextLength) || errors.Is(err, ErrInvalidRequest)
+}
+
+// RetryAfter 取出错误中携带的建议等待时间
+func RetryAfter(err error) time.Duration {
+	var pe *Error
+	if errors.As(err, &pe) {
+		return pe.RetryAfter
+	}
+	return 0
+}
+
+// FromStatus 按 HTTP 状态码与错误消息分类；msg 一般是响应体中的错误描述
+func FromStatus(provider string, status int, header http.Header, msg string, err error) error {
+	if err == nil {
+		err = fmt.Errorf("HTTP %d: %s", status, msg)
+	}
+	e := &Error{Provider: provider, StatusCode: status, Err: err}
+	if header != nil {
+		e.RetryAfter = ParseRetryAfter(header.Get("Retry-After"))
+	}
+	switch {
+	case status == 401 || status == 403:
+		e.Kind = ErrAuth
+	case status == 429:
+		e.Kind = ErrRateLimited
+	case status == 404:
+		e.Kind = ErrModelNotFound
+	case status == 408 || status == 504:
+		e.Kind = ErrTimeout
+	case status == 500 || status == 502 || status == 503 || status == 529:
+		e.Kind = ErrOverloaded
+	case status >= 400 && status < 500:
+		e.Kind = ErrInvalidRequest
+		if contextLengthMessage(msg) {
+			e.Kind = ErrContextLength
+		}
+	default:
+		e.Kind = ErrOverloaded
+	}
+	return e
+}
+
+// FromTransport 分类网络层错误；超时之外原样返回（是否可重试由 Transport 判断）
+func FromTransport(provider string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var ne net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &ne) && ne.Timeout()) {
+		return &Error{Kind: ErrTimeout, Provider: provider, Err: err}
+	}
+	return err
+}
+
+// Classify 按错误类型与消息内容分类（用于流中途的错误事件等）；无法识别时返回普通错误
+func Classify(provider string, kind string, msg string) error {
+	err := errors.New(msg)
+	k := strings.ToLower(kind + " " + msg)
+	e := &Error{Provider: provider, Err: err}
+	switch {
+	case contextLengthMessage(msg):
+		e.Kind = ErrContextLength
+	case strings.Contains(k, "overloaded"), strings.Contains(k, "unavailable"), strings.Contains(k, "loading"),
+		strings.Contains(k, "api_error"): // Anthropic 的内部错误
+
+		e.Kind = ErrOverloaded
+	case strings.Contains(k, "rate_limit"), strings.Contains(k, "rate limit"):
+		e.Kind = ErrRateLimited
+	case strings.Contains(k, "authentication"), strings.Contains(k, "permission"):
+		e.Kind = ErrAuth
+	case strings.Contains(k, "not_found"), strings.Contains(k, "not found"):
+		e.Kind = ErrModelNotFound
+	default:
+		return err
+	}
+	return e
+}
+
+// ParseRetryAfter 支持秒数与 HTTP 日期两种格式
+func ParseRetryAfter(v string) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if s, err := strconv.ParseFloat(v, 64); err == nil && s > 0 {
+		return time.Duration(s * float64(time.Second))
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// contextLengthMessage 各家“上下文过长”的常见措辞
+func contextLengthMessage(msg string) bool {
+	m := strings.ToLower(msg)
+	for _, s := range []string{
+		"context_length_exceeded", "context length", "maximum context",
+		"prompt is too long", "input is too long", "too many tokens",
+		"input validation error: `inputs` tokens", // TGI
+	} {
+		if strings.Contains(m, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestFromStatus(t *testing.T) {
+	cases := []struct {
+		status int
+		msg    string
+		want   error
+	}{
+		{401, "invalid api key", ErrAuth},
+		{403, "forbidden", ErrAuth},
+		{404, "model 'x' not found", ErrModelNotFound},
+		{408, "", ErrTimeout},
+		{429, "slow down", ErrRateLimited},
+		{400, "bad temperature", ErrInvalidRequest},
+		{422, "unprocessable", ErrInvalidRequest},
+		{400, "This model's maximum context length is 8192 tokens", ErrContextLength},
+		{413, "prompt is too long: 210000 tokens", ErrContextLength},
+		{500, "", ErrOverloaded},
+		{502, "", ErrOverloaded},
+		{503, "", ErrOverloaded},
+		{504, "", ErrTimeout},
+		{529, "overloaded", ErrOverloaded},
+		{599, "", ErrOverloaded},
+	}
+	for _, tc := range cases {
+		err := FromStatus("p", tc.status, nil, tc.msg, nil)
+		if !errors.Is(err, tc.want) {
+			t.Errorf("FromStatus(%d, %q) = %v, want %v", tc.status, tc.msg, err, tc.want)
+		}
+		var pe *Error
+		if !errors.As(err, &pe) || pe.StatusCode != tc.status || pe.Provider != "p" {
+			t.Errorf("FromStatus(%d) = %#v", tc.status, err)
+		}
+	}
+
+	// 原始错误保留，Retry-After 被解析
+	orig := errors.New("sdk error")
+	h := http.Header{"Retry-After": {"7"}}
+	err := FromStatus("p", 429, h, "", orig)
+	if !errors.Is(err, orig) || RetryAfter(err) != 7*time.Second {
+		t.Errorf("err = %v, retry after %v", err, RetryAfter(err))
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	cases := []struct {
+		in       string
+		min, max time.Duration
+	}{
+		{"", 0, 0},
+		{"0", 0, 0},
+		{"-3", 0, 0},
+		{"garbage", 0, 0},
+		{"12", 12 * time.Second, 12 * time.Second},
+		{" 1.5 ", 1500 * time.Millisecond, 1500 * time.Millisecond},
+		{time.Now().Add(30 * time.Second).UTC().Format(http.TimeFormat), 28 * time.Second, 30 * time.Second},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, 0}, // 已过去的日期
+	}
+	for _, tc := range cases {
+		if got := ParseRetryAfter(tc.in); got < tc.min || got > tc.max {
+			t.Errorf("ParseRetryAfter(%q) = %v, want [%v, %v]", tc.in, got, tc.min, tc.max)
+		}
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"canceled", context.Canceled, false},
+		{"auth", &Error{Kind: ErrAuth}, false},
+		{"context length", &Error{Kind: ErrContextLength}, false},
+		{"model not found", &Error{Kind: ErrModelNotFound}, false},
+		{"invalid request", &Error{Kind: ErrInvalidRequest}, false},
+		{"rate limited", &Error{Kind: ErrRateLimited}, true},
+		{"overloaded", &Error{Kind: ErrOverloaded}, true},
+		{"timeout", &Error{Kind: ErrTimeout}, true},
+		{"deadline via FromTransport", FromTransport("p", context.DeadlineExceeded), true},
+		{"connection refused", refused, true},
+		{"bare ECONNREFUSED", fmt.Errorf("dial: %w", syscall.ECONNREFUSED), true},
+		{"unexpected EOF", fmt.Errorf("stream: %w", io.ErrUnexpectedEOF), true},
+		{"unclassified", errors.New("decode response: invalid character"), false},
+		{"classified wrapped", fmt.Errorf("round 2: %w", &Error{Kind: ErrOverloaded}), true},
+	}
+	for _, tc := range cases {
+		if got := Retryable(tc.err); got != tc.want {
+			t.Errorf("%s: Retryable(%v) = %v, want %v", tc.name, tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		kind, msg string
+		want      error
+	}{
+		{"overloaded_error", "Overloaded", ErrOverloaded},
+		{"rate_limit_error", "too fast", ErrRateLimited},
+		{"authentication_error", "bad key", ErrAuth},
+		{"invalid_request_error", "prompt is too long", ErrContextLength},
+		{"not_found_error", "no such model", ErrModelNotFound},
+		{"api_error", "Internal server error", ErrOverloaded},
+	}
+	for _, tc := range cases {
+		if err := Classify("p", tc.kind, tc.msg); !errors.Is(err, tc.want) {
+			t.Errorf("Classify(%q, %q) = %v, want %v", tc.kind, tc.msg, err, tc.want)
+		}
+	}
+	if err := Classify("p", "mystery_error", "something odd"); Retryable(err) {
+		t.Errorf("unrecognised stream error %v should not be retryable", err)
+	}
+}
@@ -0,0 +1,60 @@
+package huggingface
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gollm-mini/internal/provider"
+	"gollm-mini/internal/types"
+)
+
+// Embed 计算文本向量：
+//   - local / tgi：hf-api 或 text-embeddings-inference 的 /embed
+//   - inference：api-inference 的 feature-extraction（BASE/<model>）
+//
+// 三者请求都是 {"inputs": [...]}，响应都是 [[float, ...], ...]
+func (h *HF) Embed(ctx context.Context, inputs []string) ([][]float32, types.Usage, error) {
+	var url string
+	switch h.mode {
+	case modeRouter:
+		return nil, types.Usage{}, &provider.Error{Kind: provider.ErrInvalidRequest, Provider: "hf",
+			Err: errors.New("hf router mode does not support embeddings")}
+	case modeInference:
+		if h.apiKey == "" {
+			return nil, types.Usage{}, errNoKey
+		}
+		url = fmt.Sprintf("%s/%s", h.baseURL, h.modelID)
+	default:
+		url = strings.TrimSuffix(h.baseURL, "/generate") + "/embed"
+	}
+
+	payload := map[string]any{"inputs": inputs}
+	if h.mode == modeLocal {
+		payload["model"] = h.modelID // 只有 hf-api 按请求动态加载模型；TEI / inference 由部署或 URL 决定
+	}
+	resp, err := h.post(ctx, h.client, url, payload)
+	if err != nil {
+		return nil, types.Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.Usage{}, err
+	}
+	var vecs [][]float32
+	if err := json.Unmarshal(body, &vecs); err != nil {
+		return nil, types.Usage{}, fmt.Errorf("decode HF embeddings: %w", err)
+	}
+
+	// 服务端不回报 token 数，按本地 tokenizer 估算
+	var usage types.Usage
+	for _, s := range inputs {
+		usage.PromptTokens += h.tok.Count(s)
+	}
+	return vecs, usage, nil
+}
@@ -1,6 +1,7 @@
 package huggingface
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -12,15 +13,33 @@ import (
 	"strings"
 	"time"
 
+	"gollm-mini/internal/chattemplate"
 	"gollm-mini/internal/provider"
+	"gollm-mini/internal/tokenizer"
 	"gollm-mini/internal/types"
 )
 
+const maxStopSequences = 4
+
+// mode 决定请求格式与接口路径
+type mode string
+
+const (
+	modeLocal     mode = "local"     // 仓库自带的 hf-api（FastAPI）
+	modeTGI       mode = "tgi"       // text-generation-inference 服务
+	modeInference mode = "inference" // api-inference.huggingface.co/models/<id>
+	modeRouter    mode = "router"    // router.huggingface.co/v1（OpenAI 兼容 chat-completions）
+)
+
 type HF struct {
-	client  *http.Client
-	apiKey  string
-	modelID string
-	baseURL string
+	client       *http.Client // 非流式，带整体超时
+	streamClient *http.Client // 流式，仅受 ctx 控制
+	apiKey       string
+	modelID      string
+	baseURL      string
+	mode         mode
+	tpl          chattemplate.Template // 按模型家族渲染对话
+	tok          tokenizer.Tokenizer
 }
 
 // ---------------------------------------------------------------------
@@ -33,188 +52,322 @@ func New(model string) *HF {
 	if base == "" {
 		base = "https://api-inference.huggingface.co/models"
 	}
+	base = strings.TrimRight(base, "/")
 	return &HF{
-		client:  &http.Client{Timeout: 60 * time.Second},
-		apiKey:  key,
-		modelID: model,
-		baseURL: strings.TrimRight(base, "/"),
+		client:       &http.Client{Timeout: 60 * time.Second},
+		streamClient: &http.Client{},
+		apiKey:       key,
+		modelID:      model,
+		baseURL:      base,
+		mode:         detectMode(os.Getenv("HF_MODE"), base),
+		tpl:          chattemplate.ForModel(model),
+		tok:          tokenizer.ForModel("hf", model),
+	}
+}
+
+// detectMode 优先使用 HF_MODE，否则按 URL 推断
+func detectMode(explicit, base string) mode {
+	switch m := mode(strings.ToLower(explicit)); m {
+	case modeLocal, modeTGI, modeInference, modeRouter:
+		return m
+	}
+	switch {
+	case strings.Contains(base, "router.huggingface.co"):
+		return modeRouter
+	case strings.Contains(base, "api-inference.huggingface.co"):
+		return modeInference
+	default:
+		return modeLocal
 	}
 }
 
-func (h *HF) SetModel(m string) { h.modelID = m }
+// Model 实际使用的模型名
+func (h *HF) Model() string { return h.modelID }
+
+// SupportsJSONSchema 只有 TGI 支持 grammar 约束解码
+func (h *HF) SupportsJSONSchema() bool { return h.mode == modeTGI }
+
+func (h *HF) remote() bool { return h.mode == modeInference || h.mode == modeRouter }
 
 // ---------------------------------------------------------------------
 // 核心：Generate
 // ---------------------------------------------------------------------
 
-func (h *HF) Generate(ctx context.Context, msgs []types.Message) (string, types.Usage, error) {
+func (h *HF) Generate(ctx context.Context, msgs []types.Message, opts types.GenerateOptions) (string, types.Usage, error) {
 	// -------------------- 1) 参数检查 --------------------
-	isRemote := strings.Contains(h.baseURL, "api-inference.huggingface.co")
-	if isRemote && h.apiKey == "" {
-		return "", types.Usage{}, errors.New("HF_API_KEY not set (remote HF API)")
-	}
-
-	// -------------------- 2) 拼 prompt --------------------
-	prompt := buildPrompt(msgs)
-
-	// -------------------- 3) 计算 URL --------------------
-	url := h.baseURL
-	if isRemote {
-		// 远端：BASE/models/<model>
-		url = fmt.Sprintf("%s/%s", h.baseURL, h.modelID)
-	} else {
-		// 本地：确保以 /generate 结尾
-		if !strings.HasSuffix(h.baseURL, "/generate") {
-			url = h.baseURL + "/generate"
-		}
-	}
-
-	// -------------------- 4) 构造请求体 --------------------
-	var payload any
-	if isRemote {
-		payload = map[string]string{"inputs": prompt}
-	} else {
-		payload = map[string]string{
-			"input": prompt,
-			"model": h.modelID, // 便于 FastAPI 端动态加载
-		}
+	if err := h.check(msgs); err != nil {
+		return "", types.Usage{}, err
 	}
-	body, _ := json.Marshal(payload)
-
-	req, _ := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
-	req.Header.Set("Content-Type", "application/json")
-	if isRemote {
-		req.Header.Set("Authorization", "Bearer "+h.apiKey)
+	if h.mode == modeRouter {
+		return h.generateChat(ctx, msgs, opts)
 	}
 
-	// -------------------- 5) 发送 --------------------
-
-	resp, err := h.client.Do(req)
+	// -------------------- 2) 拼 prompt & 发送 --------------------
+	prompt := h.tpl.Render(msgs)
+	resp, err := h.post(ctx, h.client, h.endpoint(false), h.payload(prompt, opts, false))
 	if err != nil {
 		return "", types.Usage{}, err
 	}
 	defer resp.Body.Close()
 
-	// 常见重试场景：503 正在加载权重
-	if resp.StatusCode == 503 {
-		return "", types.Usage{}, errors.New("model loading on HF, retry later")
-	}
-	if resp.StatusCode != 200 {
-		return "", types.Usage{}, fmt.Errorf("HF API %s", resp.Status)
-	}
-
-	// -------------------- 6) 解析响应 --------------------
+	// -------------------- 3) 解析响应 --------------------
 	respBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return "", types.Usage{}, err
 	}
 
-	// 6-a 远端格式：[{ "generated_text": "..." }]
+	// 3-a 远端格式：[{ "generated_text": "..." }]
 	var arr []struct {
 		GeneratedText string `json:"generated_text"`
 	}
 	if json.Unmarshal(respBytes, &arr) == nil && len(arr) > 0 {
-		txt := strings.TrimSpace(arr[0].GeneratedText)
+		txt := h.tpl.Clean(arr[0].GeneratedText, "")
 		usage := types.Usage{
-			PromptTokens:     approxTokens(prompt),
-			CompletionTokens: approxTokens(txt),
+			PromptTokens:     h.tok.Count(prompt),
+			CompletionTokens: h.tok.Count(txt),
 		}
 		return txt, usage, nil
 	}
 
-	// 6-b 本地格式：{ "text": "..." }
+	// 3-b TGI { "generated_text": "..." } / 本地 { "text": "..." }
 	var obj struct {
-		Text string `json:"text"`
+		Text          string `json:"text"`
+		GeneratedText string `json:"generated_text"`
 	}
 	if err := json.Unmarshal(respBytes, &obj); err != nil {
 		return "", types.Usage{}, fmt.Errorf("decode HF response: %w", err)
 	}
-	txt := postProcess(obj.Text)
+	// 本地 hf-api 回显 prompt + 回答，TGI 只返回新生成部分
+	txt := h.tpl.Clean(obj.GeneratedText, "")
+	if obj.Text != "" {
+		txt = h.tpl.Clean(obj.Text, prompt)
+	}
 	usage := types.Usage{
-		PromptTokens:     approxTokens(prompt),
-		CompletionTokens: approxTokens(txt),
+		PromptTokens:     h.tok.Count(prompt),
+		CompletionTokens: h.tok.Count(txt),
 	}
 	return txt, usage, nil
 }
 
 // ---------------------------------------------------------------------
-// Stream：沿用你原来的“空格伪流”实现
+// Stream：TGI / hf-api 的 /generate_stream，router 走 chat-completions SSE
 // ---------------------------------------------------------------------
 
-func (h *HF) Stream(ctx context.Context, msgs []types.Message, cb func(types.Chunk)) (types.Usage, error) {
-	txt, usage, err := h.Generate(ctx, msgs)
+// streamEvent 是 TGI generate_stream 的单个 SSE 事件
+type streamEvent struct {
+	Token struct {
+		Text    string `json:"text"`
+		Special bool   `json:"special"`
+	} `json:"token"`
+	GeneratedText *string `json:"generated_text"`
+	Details       *struct {
+		GeneratedTokens int `json:"generated_tokens"`
+	} `json:"details"`
+	Error     string `json:"error"`
+	ErrorType string `json:"error_type"`
+}
+
+func (h *HF) Stream(ctx context.Context, msgs []types.Message, opts types.GenerateOptions, cb func(types.Chunk)) (types.Usage, error) {
+	if err := h.check(msgs); err != nil {
+		return types.Usage{}, err
+	}
+	if h.mode == modeRouter {
+		return h.streamChat(ctx, msgs, opts, cb)
+	}
+
+	prompt := h.tpl.Render(msgs)
+	resp, err := h.post(ctx, h.streamClient, h.endpoint(true), h.payload(prompt, opts, true))
 	if err != nil {
-		return usage, err
-	}
-	for _, tok := range strings.Split(txt, " ") {
-		select {
-		case <-ctx.Done():
-			return usage, ctx.Err()
-		default:
-			cb(types.Chunk{Content: tok + " ", Delta: 1})
-			time.Sleep(60 * time.Millisecond)
-		}
+		return types.Usage{}, err
 	}
-	return usage, nil
+	defer resp.Body.Close()
+
+	usage := types.Usage{PromptTokens: h.tok.Count(prompt)}
+	err = readSSE(resp.Body, func(data []byte) error {
+		var ev streamEvent
+		if err := json.Unmarshal(data, &ev); err != nil {
+			return fmt.Errorf("decode HF stream event: %w", err)
+		}
+		if ev.Error != "" {
+			return provider.Classify("hf", ev.ErrorType, fmt.Sprintf("HF stream %s: %s", ev.ErrorType, ev.Error))
+		}
+		if !ev.Token.Special && ev.Token.Text != "" {
+			cb(types.Chunk{Content: ev.Token.Text, Delta: 1})
+			usage.CompletionTokens++
+		}
+		if ev.Details != nil && ev.Details.GeneratedTokens > 0 {
+			usage.CompletionTokens = ev.Details.GeneratedTokens
+		}
+		return nil
+	})
+	return usage, err
 }
 
 // ---------------------------------------------------------------------
 // 辅助函数
 // ---------------------------------------------------------------------
 
-func buildPrompt(msgs []types.Message) string {
-	var sys, user string
-	for _, m := range msgs {
-		switch m.Role {
-		case types.RoleSystem:
-			sys = m.Content
-		case types.RoleUser:
-			user = m.Content
-		}
+func (h *HF) check(msgs []types.Message) error {
+	if types.HasImages(msgs) {
+		return &provider.Error{Kind: provider.ErrInvalidRequest, Provider: "hf", Err: errors.New("hf provider does not support image input")}
 	}
-	if sys == "" {
-		sys = "You are a helpful assistant."
+	if h.remote() && h.apiKey == "" {
+		return errNoKey
 	}
-	return fmt.Sprintf(
-		"<s>"+
-			"<|system|>\n%s</s>\n"+
-			"<|user|>\n%s</s>\n"+
-			"<|assistant|>\n",
-		sys, user,
-	)
+	return nil
+}
+
+var errNoKey = &provider.Error{Kind: provider.ErrAuth, Provider: "hf", Err: errors.New("HF_API_KEY not set (remote HF API)")}
 
+// endpoint 计算请求地址
+func (h *HF) endpoint(stream bool) string {
+	switch h.mode {
+	case modeInference:
+		// 远端：BASE/models/<model>，流式靠 payload 中的 stream 字段
+		return fmt.Sprintf("%s/%s", h.baseURL, h.modelID)
+	case modeRouter:
+		return h.baseURL + "/chat/completions"
+	}
+	// 本地 / TGI：/generate 与 /generate_stream（兼容 HF_BASE_URL 已带 /generate 的旧配置）
+	base := strings.TrimSuffix(h.baseURL, "/generate")
+	if stream {
+		return base + "/generate_stream"
+	}
+	return base + "/generate"
 }
 
-// 本地返回值里可能带多余 tag，简单裁剪一下
-func postProcess(txt string) string {
-	// ---------- 1) 保留 Assistant 之后的内容 ----------
-	if i := strings.Index(txt, "<|assistant|>"); i != -1 {
-		txt = txt[i+len("<|assistant|>"):]
+// payload 构造请求体
+func (h *HF) payload(prompt string, opts types.GenerateOptions, stream bool) any {
+	// 模板的回合结束符放在最前面作为停止序列（TGI 默认最多 4 个），超出部分只能舍弃调用方的停止序列
+	opts.Stop = append(append([]string(nil), h.tpl.Stop...), opts.Stop...)
+	if len(opts.Stop) > maxStopSequences {
+		for _, s := range opts.Stop[maxStopSequences:] {
+			provider.WarnUnsupported("hf", fmt.Sprintf("stop %q (at most %d stop sequences)", s, maxStopSequences))
+		}
+		opts.Stop = opts.Stop[:maxStopSequences]
 	}
+	params := toParameters(opts)
+	switch h.mode {
+	case modeInference:
+		params["return_full_text"] = false
+		return map[string]any{"inputs": prompt, "parameters": params, "stream": stream}
+	case modeTGI:
+		if len(opts.JSONSchema) > 0 {
+			params["grammar"] = map[string]any{"type": "json", "value": opts.JSONSchema}
+		}
+		return map[string]any{"inputs": prompt, "parameters": params}
+	default:
+		return map[string]any{
+			"input":      prompt,
+			"model":      h.modelID, // 便于 FastAPI 端动态加载
+			"parameters": params,
+		}
+	}
+}
+
+// post 发送 JSON 请求并处理常见错误状态
+func (h *HF) post(ctx context.Context, client *http.Client, url string, payload any) (*http.Response, error) {
+	body, _ := json.Marshal(payload)
 
-	// ---------- 2) 去掉前缀 <s>（TinyLlama 有时会给两个） ----------
-	txt = strings.TrimSpace(txt)
-	for strings.HasPrefix(txt, "<s>") {
-		txt = strings.TrimPrefix(txt, "<s>")
-		txt = strings.TrimSpace(txt)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+h.apiKey)
 	}
 
-	// ---------- 3) 删掉结尾 "</s>" 及其后噪声 ----------
-	if j := strings.Index(txt, "</s>"); j != -1 {
-		txt = txt[:j]
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, provider.FromTransport("hf", err)
+	}
+	if resp.StatusCode == 200 {
+		return resp, nil
 	}
 
-	// ---------- 收尾 ----------
-	return strings.TrimSpace(txt)
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var apiErr struct {
+		Error         any     `json:"error"`
+		EstimatedTime float64 `json:"estimated_time"` // 模型加载预计耗时（秒）
+	}
+	_ = json.Unmarshal(raw, &apiErr)
+	msg := string(raw)
+	if apiErr.Error != nil {
+		msg = fmt.Sprint(apiErr.Error)
+	}
+
+	// 常见重试场景：503 正在加载权重，按 estimated_time 等待
+	if resp.StatusCode == 503 {
+		e := provider.FromStatus("hf", 503, resp.Header, msg, errors.New("model loading on HF, retry later")).(*provider.Error)
+		if e.RetryAfter == 0 && apiErr.EstimatedTime > 0 {
+			e.RetryAfter = time.Duration(apiErr.EstimatedTime * float64(time.Second))
+		}
+		return nil, e
+	}
+	return nil, provider.FromStatus("hf", resp.StatusCode, resp.Header, msg, fmt.Errorf("HF API %s: %s", resp.Status, msg))
 }
 
-// 粗略 token 估算：按空格数
-func approxTokens(s string) int { return len(strings.Split(s, " ")) }
+// readSSE 逐条读取 data: 行，遇到 [DONE] 结束
+func readSSE(r io.Reader, fn func(data []byte) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(line[5:])
+		if data == "[DONE]" {
+			return nil
+		}
+		if data == "" {
+			continue
+		}
+		if err := fn([]byte(data)); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// toParameters 映射为 TGI / hf-api 通用的 parameters 字段
+func toParameters(opts types.GenerateOptions) map[string]any {
+	p := map[string]any{}
+	if opts.Temperature != nil {
+		if *opts.Temperature == 0 {
+			// TGI 要求 temperature > 0；0 等价于贪心解码
+			p["do_sample"] = false
+		} else {
+			p["temperature"] = *opts.Temperature
+			p["do_sample"] = true
+		}
+	}
+	if opts.TopP != nil {
+		if *opts.TopP < 1 {
+			p["top_p"] = *opts.TopP
+		} else {
+			// TGI 要求 top_p < 1；不发送与 top_p=1（不截断）等价，router 模式则原样转发
+			provider.WarnUnsupported("hf", fmt.Sprintf("top_p %v (TGI requires < 1)", *opts.TopP))
+		}
+	}
+	if opts.MaxTokens > 0 {
+		p["max_new_tokens"] = opts.MaxTokens
+	}
+	if len(opts.Stop) > 0 {
+		p["stop"] = opts.Stop
+	}
+	if opts.Seed != nil {
+		p["seed"] = *opts.Seed
+	}
+	return p
+}
 
 // ---------------------------------------------------------------------
 // 注册到 provider 工厂
 // ---------------------------------------------------------------------
 
 func init() {
-	provider.Register("hf", New("TinyLlama/TinyLlama-1.1B-Chat-v1.0"))
+	provider.Register("hf", provider.Simple("TinyLlama/TinyLlama-1.1B-Chat-v1.0", New))
 }
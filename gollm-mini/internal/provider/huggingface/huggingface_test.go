@@ -0,0 +1,316 @@
+package huggingface
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gollm-mini/internal/provider"
+	"gollm-mini/internal/types"
+)
+
+// newStub 把 HF_BASE_URL 指向 httptest 服务
+func newStub(t *testing.T, mode, model string, h http.HandlerFunc) *HF {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	t.Setenv("HF_API_KEY", "hf-test")
+	t.Setenv("HF_BASE_URL", srv.URL)
+	t.Setenv("HF_MODE", mode)
+	return New(model)
+}
+
+func TestRejectImages(t *testing.T) {
+	h := newStub(t, "tgi", "tgi", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s", r.URL.Path)
+	})
+	msgs := []types.Message{{
+		Role:  types.RoleUser,
+		Parts: []types.ContentPart{{Type: types.PartImage, ImageURL: "https://example.com/a.png"}},
+	}}
+
+	_, _, err := h.Generate(context.Background(), msgs, types.GenerateOptions{})
+	if !errors.Is(err, provider.ErrInvalidRequest) {
+		t.Errorf("Generate err = %v, want ErrInvalidRequest", err)
+	}
+	_, err = h.Stream(context.Background(), msgs, types.GenerateOptions{}, func(types.Chunk) {})
+	if !errors.Is(err, provider.ErrInvalidRequest) {
+		t.Errorf("Stream err = %v, want ErrInvalidRequest", err)
+	}
+}
+
+func TestEmbed(t *testing.T) {
+	cases := []struct {
+		mode, path string
+		sendModel  bool
+	}{
+		{"local", "/embed", true}, // hf-api 按请求加载模型
+		{"tgi", "/embed", false},  // text-embeddings-inference
+		{"inference", "/BAAI/bge-small-en-v1.5", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.mode, func(t *testing.T) {
+			h := newStub(t, tc.mode, "BAAI/bge-small-en-v1.5", func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != tc.path {
+					t.Errorf("path = %s, want %s", r.URL.Path, tc.path)
+				}
+				if r.Header.Get("Authorization") != "Bearer hf-test" {
+					t.Errorf("authorization = %q", r.Header.Get("Authorization"))
+				}
+				var body map[string]any
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				if _, ok := body["model"]; ok != tc.sendModel {
+					t.Errorf("body = %v, model sent = %v", body, ok)
+				}
+				if in, _ := body["inputs"].([]any); len(in) != 2 {
+					t.Errorf("inputs = %v", body["inputs"])
+				}
+				_, _ = w.Write([]byte(`[[0.1,0.2],[0.3,0.4]]`))
+			})
+
+			vecs, u, err := h.Embed(context.Background(), []string{"hello", "world"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(vecs) != 2 || vecs[1][0] != 0.3 {
+				t.Errorf("vecs = %v", vecs)
+			}
+			if u.PromptTokens == 0 {
+				t.Error("usage should be estimated locally")
+			}
+		})
+	}
+}
+
+func TestEmbedRouterUnsupported(t *testing.T) {
+	h := newStub(t, "router", "m", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s", r.URL.Path)
+	})
+	if _, _, err := h.Embed(context.Background(), []string{"hi"}); !errors.Is(err, provider.ErrInvalidRequest) {
+		t.Errorf("err = %v, want ErrInvalidRequest", err)
+	}
+}
+
+func TestPayloadStops(t *testing.T) {
+	t.Setenv("HF_CHAT_TEMPLATE", "chatml")
+	h := newStub(t, "tgi", "m", func(http.ResponseWriter, *http.Request) {})
+
+	// 回合结束符总是保留，超出上限时舍弃调用方多余的停止序列
+	opts := types.GenerateOptions{Stop: []string{"a", "b", "c", "d"}}
+	body := h.payload("p", opts, false).(map[string]any)
+	stop := body["parameters"].(map[string]any)["stop"]
+	if want := []string{"<|im_end|>", "<|endoftext|>", "a", "b"}; !reflect.DeepEqual(stop, want) {
+		t.Errorf("stop = %v, want %v", stop, want)
+	}
+	if len(opts.Stop) != 4 {
+		t.Errorf("caller's stops modified: %v", opts.Stop)
+	}
+}
+
+// writeEvents 以 SSE 写出若干 data 行，每条之后 flush
+func writeEvents(w http.ResponseWriter, events ...string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, ev := range events {
+		_, _ = io.WriteString(w, "data:"+ev+"\n\n")
+		w.(http.Flusher).Flush()
+	}
+}
+
+// collect 流式调用并拼接收到的内容
+func collect(t *testing.T, h *HF) (string, types.Usage, error) {
+	t.Helper()
+	var sb strings.Builder
+	u, err := h.Stream(context.Background(), []types.Message{{Role: types.RoleUser, Content: "Say hello."}},
+		types.GenerateOptions{}, func(ch types.Chunk) { sb.WriteString(ch.Content) })
+	return sb.String(), u, err
+}
+
+func TestStreamTGI(t *testing.T) {
+	h := newStub(t, "tgi", "m", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/generate_stream" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		writeEvents(w,
+			`{"token":{"text":"Hel","special":false},"generated_text":null,"details":null}`,
+			`{"token":{"text":"lo","special":false},"generated_text":null,"details":null}`,
+			`{"token":{"text":"<|im_end|>","special":true},"generated_text":"Hello","details":{"generated_tokens":3}}`,
+		)
+	})
+	txt, u, err := collect(t, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txt != "Hello" {
+		t.Errorf("streamed %q, want special tokens skipped", txt)
+	}
+	// 最后一个事件的 details 覆盖逐 token 的计数
+	if u.CompletionTokens != 3 || u.PromptTokens == 0 {
+		t.Errorf("usage = %+v", u)
+	}
+}
+
+func TestStreamRouter(t *testing.T) {
+	h := newStub(t, "router", "m", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if r.URL.Path != "/chat/completions" || body["stream"] != true || body["stream_options"] == nil {
+			t.Errorf("path %s, body %v", r.URL.Path, body)
+		}
+		writeEvents(w,
+			`{"choices":[{"delta":{"role":"assistant","content":"Hel"}}]}`,
+			`{"choices":[{"delta":{"content":"lo"}}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":9,"completion_tokens":2}}`,
+			` [DONE]`,
+			`{"choices":[{"delta":{"content":" after done"}}]}`, // [DONE] 之后的内容不再处理
+		)
+	})
+	txt, u, err := collect(t, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txt != "Hello" || u.PromptTokens != 9 || u.CompletionTokens != 2 {
+		t.Errorf("streamed %q, usage %+v", txt, u)
+	}
+}
+
+func TestStreamErrorEvent(t *testing.T) {
+	cases := []struct {
+		mode, event string
+		kind        error
+	}{
+		{"tgi", `{"error":"Model is overloaded","error_type":"overloaded"}`, provider.ErrOverloaded},
+		{"router", `{"error":{"message":"Rate limit reached","type":"rate_limit_error"}}`, provider.ErrRateLimited},
+	}
+	for _, tc := range cases {
+		t.Run(tc.mode, func(t *testing.T) {
+			h := newStub(t, tc.mode, "m", func(w http.ResponseWriter, r *http.Request) {
+				if tc.mode == "tgi" {
+					writeEvents(w, `{"token":{"text":"Hi","special":false}}`, tc.event)
+				} else {
+					writeEvents(w, `{"choices":[{"delta":{"content":"Hi"}}]}`, tc.event)
+				}
+			})
+			txt, _, err := collect(t, h)
+			if !errors.Is(err, tc.kind) {
+				t.Errorf("err = %v, want %v", err, tc.kind)
+			}
+			if txt != "Hi" {
+				t.Errorf("streamed %q before the error", txt)
+			}
+		})
+	}
+}
+
+func TestStreamDisconnect(t *testing.T) {
+	for _, mode := range []string{"tgi", "router"} {
+		t.Run(mode, func(t *testing.T) {
+			h := newStub(t, mode, "m", func(w http.ResponseWriter, r *http.Request) {
+				if mode == "tgi" {
+					writeEvents(w, `{"token":{"text":"Hi","special":false}}`)
+				} else {
+					writeEvents(w, `{"choices":[{"delta":{"content":"Hi"}}]}`)
+				}
+				// 不写结束块直接断开连接
+				conn, _, err := w.(http.Hijacker).Hijack()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				_ = conn.Close()
+			})
+			txt, _, err := collect(t, h)
+			if err == nil || !provider.Transport(err) {
+				t.Errorf("err = %v, want a transport error", err)
+			}
+			if txt != "Hi" {
+				t.Errorf("streamed %q before the disconnect", txt)
+			}
+		})
+	}
+}
+
+// 服务端不返回 usage 时按本地 tokenizer 估算，不能是 0
+func TestRouterUsageFallback(t *testing.T) {
+	h := newStub(t, "router", "m", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["stream"] == true {
+			writeEvents(w, `{"choices":[{"delta":{"content":"Hello there"}}]}`, `[DONE]`)
+			return
+		}
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"Hello there"}}]}`))
+	})
+	msgs := []types.Message{{Role: types.RoleUser, Content: "Say hello."}}
+	txt, u, err := h.Generate(context.Background(), msgs, types.GenerateOptions{})
+	if err != nil || txt != "Hello there" {
+		t.Fatalf("Generate = %q, %v", txt, err)
+	}
+	if u.PromptTokens == 0 || u.CompletionTokens == 0 {
+		t.Errorf("Generate usage = %+v, want a local estimate", u)
+	}
+	if _, su, err := collect(t, h); err != nil || su != u {
+		t.Errorf("Stream usage = %+v (%v), want %+v", su, err, u)
+	}
+}
+
+// captureLog 收集测试期间的日志输出
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+func TestOptionMapping(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	seed := 7
+	cases := []struct {
+		name   string
+		in     types.GenerateOptions
+		params map[string]any // TGI / hf-api parameters
+		chat   map[string]any // router 请求体中的对应字段
+		warn   string
+	}{
+		{"unset", types.GenerateOptions{}, map[string]any{}, map[string]any{}, ""},
+		{"greedy", types.GenerateOptions{Temperature: f(0)},
+			map[string]any{"do_sample": false}, map[string]any{"temperature": 0.0}, ""},
+		{"sampling", types.GenerateOptions{Temperature: f(0.7), TopP: f(0.9)},
+			map[string]any{"temperature": 0.7, "do_sample": true, "top_p": 0.9},
+			map[string]any{"temperature": 0.7, "top_p": 0.9}, ""},
+		{"top_p 1 dropped for TGI", types.GenerateOptions{TopP: f(1)},
+			map[string]any{}, map[string]any{"top_p": 1.0}, "top_p 1"},
+		{"limits", types.GenerateOptions{MaxTokens: 64, Stop: []string{"##"}, Seed: &seed},
+			map[string]any{"max_new_tokens": 64, "stop": []string{"##"}, "seed": 7},
+			map[string]any{"max_tokens": 64, "stop": []string{"##"}, "seed": 7}, ""},
+	}
+	h := &HF{modelID: "m"}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			logs := captureLog(t)
+			if got := toParameters(tc.in); !reflect.DeepEqual(got, tc.params) {
+				t.Errorf("toParameters = %v, want %v", got, tc.params)
+			}
+			if tc.warn == "" && logs.Len() > 0 || !strings.Contains(logs.String(), tc.warn) {
+				t.Errorf("log = %q, want warning %q", logs.String(), tc.warn)
+			}
+
+			body := h.chatPayload(nil, tc.in, false)
+			for _, k := range []string{"model", "messages", "stream"} {
+				delete(body, k)
+			}
+			if !reflect.DeepEqual(body, tc.chat) {
+				t.Errorf("chatPayload = %v, want %v", body, tc.chat)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package huggingface
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"gollm-mini/internal/provider"
+	"gollm-mini/internal/types"
+)
+
+// staticModels HF 没有统一的列表接口，这里给出已配好聊天模板的常用模型
+var staticModels = []types.ModelInfo{
+	{ID: "TinyLlama/TinyLlama-1.1B-Chat-v1.0", Streaming: true, ContextWindow: 2048},
+	{ID: "HuggingFaceH4/zephyr-7b-beta", Streaming: true, ContextWindow: 32768},
+	{ID: "mistralai/Mistral-7B-Instruct-v0.3", Streaming: true, ContextWindow: 32768},
+	{ID: "meta-llama/Meta-Llama-3-8B-Instruct", Streaming: true, ContextWindow: 8192},
+	{ID: "google/gemma-2-2b-it", Streaming: true, ContextWindow: 8192},
+	{ID: "Qwen/Qwen2.5-7B-Instruct", Streaming: true, ContextWindow: 32768},
+	{ID: "sentence-transformers/all-MiniLM-L6-v2", Embeddings: true, ContextWindow: 512},
+}
+
+// ListModels 返回静态列表；当前配置的模型不在列表中时追加到末尾
+func (h *HF) ListModels(ctx context.Context) ([]types.ModelInfo, error) {
+	out := append([]types.ModelInfo(nil), staticModels...)
+	for _, m := range out {
+		if m.ID == h.modelID {
+			return out, nil
+		}
+	}
+	return append(out, types.ModelInfo{ID: h.modelID, Streaming: true}), nil
+}
+
+// HealthCheck 按模式选择探测方式：
+//   - local / tgi：GET /health
+//   - router：GET /models
+//   - inference：无廉价探测接口，只检查 key
+func (h *HF) HealthCheck(ctx context.Context) error {
+	var url string
+	switch h.mode {
+	case modeInference:
+		if h.apiKey == "" {
+			return errNoKey
+		}
+		return nil
+	case modeRouter:
+		if h.apiKey == "" {
+			return errNoKey
+		}
+		url = h.baseURL + "/models"
+	default:
+		url = strings.TrimSuffix(h.baseURL, "/generate") + "/health"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	if h.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+h.apiKey)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return provider.FromTransport("hf", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return provider.FromStatus("hf", resp.StatusCode, resp.Header, "", fmt.Errorf("HF API %s", resp.Status))
+	}
+	return nil
+}
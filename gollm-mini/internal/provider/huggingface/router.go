@@ -0,0 +1,128 @@
+package huggingface
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gollm-mini/internal/provider"
+	"gollm-mini/internal/tokenizer"
+	"gollm-mini/internal/types"
+)
+
+// HF router（router.huggingface.co/v1）提供 OpenAI 兼容的 chat-completions，
+// 直接发送消息列表，不需要本地拼 prompt。
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+		Delta   chatMessage `json:"delta"`
+	} `json:"choices"`
+	Usage *chatUsage `json:"usage"`
+	Error any        `json:"error"`
+}
+
+func (h *HF) chatPayload(msgs []types.Message, opts types.GenerateOptions, stream bool) map[string]any {
+	cm := make([]chatMessage, len(msgs))
+	for i, m := range msgs {
+		cm[i] = chatMessage{Role: string(m.Role), Content: m.Text()}
+	}
+	p := map[string]any{"model": h.modelID, "messages": cm, "stream": stream}
+	if stream {
+		p["stream_options"] = map[string]bool{"include_usage": true}
+	}
+	if opts.Temperature != nil {
+		p["temperature"] = *opts.Temperature
+	}
+	if opts.TopP != nil {
+		p["top_p"] = *opts.TopP
+	}
+	if opts.MaxTokens > 0 {
+		p["max_tokens"] = opts.MaxTokens
+	}
+	if len(opts.Stop) > 0 {
+		p["stop"] = opts.Stop
+	}
+	if opts.Seed != nil {
+		p["seed"] = *opts.Seed
+	}
+	return p
+}
+
+func (h *HF) generateChat(ctx context.Context, msgs []types.Message, opts types.GenerateOptions) (string, types.Usage, error) {
+	resp, err := h.post(ctx, h.client, h.endpoint(false), h.chatPayload(msgs, opts, false))
+	if err != nil {
+		return "", types.Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	var out chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", types.Usage{}, fmt.Errorf("decode HF router response: %w", err)
+	}
+	if len(out.Choices) == 0 {
+		return "", types.Usage{}, fmt.Errorf("HF router: empty choices (error: %v)", out.Error)
+	}
+	txt := out.Choices[0].Message.Content
+	if out.Usage == nil {
+		return txt, h.localUsage(msgs, txt), nil
+	}
+	return txt, types.Usage{PromptTokens: out.Usage.PromptTokens, CompletionTokens: out.Usage.CompletionTokens}, nil
+}
+
+func (h *HF) streamChat(ctx context.Context, msgs []types.Message, opts types.GenerateOptions, cb func(types.Chunk)) (types.Usage, error) {
+	resp, err := h.post(ctx, h.streamClient, h.endpoint(true), h.chatPayload(msgs, opts, true))
+	if err != nil {
+		return types.Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	var (
+		usage    types.Usage
+		reported bool
+		full     strings.Builder
+	)
+	err = readSSE(resp.Body, func(data []byte) error {
+		var ev chatResponse
+		if err := json.Unmarshal(data, &ev); err != nil {
+			return fmt.Errorf("decode HF router event: %w", err)
+		}
+		if ev.Error != nil {
+			return provider.Classify("hf", "", fmt.Sprintf("HF router stream: %v", ev.Error))
+		}
+		if ev.Usage != nil { // include_usage 的最后一块
+			usage = types.Usage{PromptTokens: ev.Usage.PromptTokens, CompletionTokens: ev.Usage.CompletionTokens}
+			reported = true
+		}
+		for _, c := range ev.Choices {
+			if c.Delta.Content != "" {
+				cb(types.Chunk{Content: c.Delta.Content, Delta: 1})
+				full.WriteString(c.Delta.Content)
+			}
+		}
+		return nil
+	})
+	if !reported {
+		usage = h.localUsage(msgs, full.String())
+	}
+	return usage, err
+}
+
+// localUsage 服务端没有返回 usage 时用本地 tokenizer 估算，保证计费、指标与 TPM 限流不会记为 0
+func (h *HF) localUsage(msgs []types.Message, completion string) types.Usage {
+	return types.Usage{
+		PromptTokens:     tokenizer.CountMessages(h.tok, msgs),
+		CompletionTokens: h.tok.Count(completion),
+	}
+}
@@ -0,0 +1,116 @@
+package ollama
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/ollama/ollama/api"
+	"gollm-mini/internal/provider"
+	"gollm-mini/internal/types"
+)
+
+const (
+	maxImageBytes     = 20 << 20 // 下载远程图片的大小上限
+	maxImageRedirects = 3
+	imageFetchTimeout = 15 * time.Second
+)
+
+// imageClient 下载用户给出的图片 URL。URL 来自请求方，必须防 SSRF：
+// 只允许 http(s)、拒绝回环 / 内网 / 链路本地地址（按实际拨号的 IP 判断，DNS 重绑定也无效）、
+// 不走环境代理、限时并限制重定向次数。测试中可替换。
+var imageClient = &http.Client{
+	Timeout: imageFetchTimeout,
+	Transport: &http.Transport{
+		Proxy: nil,
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: func(_, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+					return fmt.Errorf("image host %s is not a public address", host)
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 10 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxImageRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxImageRedirects)
+		}
+		return checkScheme(req.URL)
+	},
+}
+
+// publicIP 是否为可公开访问的单播地址
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
+
+func checkScheme(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported image url scheme %q", u.Scheme)
+	}
+	return nil
+}
+
+// imageBytes Ollama 只接受原始字节：base64 直接解码，URL 经 imageClient 下载
+func imageBytes(ctx context.Context, p types.ContentPart) (api.ImageData, error) {
+	if p.ImageURL == "" {
+		b, err := p.Bytes()
+		if err != nil {
+			return nil, &provider.Error{Kind: provider.ErrInvalidRequest, Provider: "ollama", Err: fmt.Errorf("decode image: %w", err)}
+		}
+		return b, nil
+	}
+
+	u, err := url.Parse(p.ImageURL)
+	if err == nil {
+		err = checkScheme(u)
+	}
+	if err != nil {
+		return nil, imageError(p.ImageURL, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", p.ImageURL, nil)
+	if err != nil {
+		return nil, imageError(p.ImageURL, err)
+	}
+	resp, err := imageClient.Do(req)
+	if err != nil {
+		return nil, imageError(p.ImageURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, imageError(p.ImageURL, errors.New(resp.Status))
+	}
+	if resp.ContentLength > maxImageBytes {
+		return nil, imageError(p.ImageURL, fmt.Errorf("image is %d bytes, limit %d", resp.ContentLength, maxImageBytes))
+	}
+	// 多读 1 字节用于判断是否超限，超限报错而不是静默截断
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxImageBytes+1))
+	if err != nil {
+		return nil, imageError(p.ImageURL, err)
+	}
+	if len(b) > maxImageBytes {
+		return nil, imageError(p.ImageURL, fmt.Errorf("image exceeds %d bytes", maxImageBytes))
+	}
+	return b, nil
+}
+
+// imageError 图片地址不可用属于请求本身的问题，重试无意义
+func imageError(src string, err error) error {
+	return &provider.Error{Kind: provider.ErrInvalidRequest, Provider: "ollama", Err: fmt.Errorf("fetch image %s: %w", src, err)}
+}
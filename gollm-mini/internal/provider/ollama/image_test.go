@@ -0,0 +1,97 @@
+package ollama
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gollm-mini/internal/provider"
+	"gollm-mini/internal/types"
+)
+
+var png = []byte("\x89PNG\r\n\x1a\nfake")
+
+// serveImage 启动图片服务，并在测试期间放开 imageClient 的内网限制
+func serveImage(t *testing.T, body []byte) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+
+	old := imageClient
+	imageClient = srv.Client()
+	t.Cleanup(func() { imageClient = old })
+	return srv.URL + "/cat.png"
+}
+
+func TestToAPIMessagesImages(t *testing.T) {
+	u := serveImage(t, png)
+	b64, err := types.ImagePart("data:image/png;base64,iVBORw0KGgpmYWtl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := []types.Message{{
+		Role:    types.RoleUser,
+		Content: "what is this?",
+		Parts:   []types.ContentPart{b64, {Type: types.PartImage, ImageURL: u}},
+	}}
+
+	om, err := toAPIMessages(context.Background(), msgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(om) != 1 || om[0].Content != "what is this?" || len(om[0].Images) != 2 {
+		t.Fatalf("messages = %+v", om)
+	}
+	for i, img := range om[0].Images {
+		if !bytes.Equal(img, png) {
+			t.Errorf("image %d = %q, want raw bytes", i, img)
+		}
+	}
+}
+
+func TestImageTooLarge(t *testing.T) {
+	u := serveImage(t, bytes.Repeat([]byte{'x'}, maxImageBytes+1))
+	_, err := imageBytes(context.Background(), types.ContentPart{Type: types.PartImage, ImageURL: u})
+	if !errors.Is(err, provider.ErrInvalidRequest) {
+		t.Fatalf("err = %v, want ErrInvalidRequest for oversized image", err)
+	}
+}
+
+func TestImageRejectsPrivateHosts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("loopback image server must not be reached")
+	}))
+	defer srv.Close()
+
+	for _, u := range []string{srv.URL + "/cat.png", "file:///etc/passwd"} {
+		_, err := imageBytes(context.Background(), types.ContentPart{Type: types.PartImage, ImageURL: u})
+		if !errors.Is(err, provider.ErrInvalidRequest) {
+			t.Errorf("%s: err = %v, want ErrInvalidRequest", u, err)
+		}
+	}
+}
+
+func TestPublicIP(t *testing.T) {
+	cases := map[string]bool{
+		"8.8.8.8":         true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"192.168.0.1":     false,
+		"169.254.169.254": false, // 云厂商元数据
+		"0.0.0.0":         false,
+		"::1":             false,
+		"fe80::1":         false,
+	}
+	for s, want := range cases {
+		if got := publicIP(net.ParseIP(s)); got != want {
+			t.Errorf("publicIP(%s) = %v, want %v", s, got, want)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package ollama
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ollama/ollama/api"
+	"gollm-mini/internal/provider"
+	"gollm-mini/internal/types"
+)
+
+// PullModel 在每台健康主机上依次拉取模型，进度事件原样转发（多主机时带上主机地址）
+func (o *Ollama) PullModel(ctx context.Context, model string, progress func(types.PullProgress)) error {
+	if model == "" {
+		return errNoModel
+	}
+	for _, h := range o.pool.targets() {
+		err := h.client.Pull(ctx, &api.PullRequest{Model: model}, func(pr api.ProgressResponse) error {
+			if progress != nil {
+				progress(types.PullProgress{
+					Host:      o.pool.label(h),
+					Status:    pr.Status,
+					Digest:    pr.Digest,
+					Total:     pr.Total,
+					Completed: pr.Completed,
+				})
+			}
+			return nil
+		})
+		if err != nil {
+			return o.pool.hostError(h, "pull", model, streamError(err))
+		}
+	}
+	o.pool.Refresh(ctx)
+	provider.InvalidateModels("ollama")
+	return nil
+}
+
+// DeleteModel 从所有主机删除模型；任何一台都没有该模型时返回 ErrModelNotFound
+func (o *Ollama) DeleteModel(ctx context.Context, model string) error {
+	if model == "" {
+		return errNoModel
+	}
+	var notFound error
+	deleted := 0
+	for _, h := range o.pool.hosts {
+		err := mapError(h.client.Delete(ctx, &api.DeleteRequest{Model: model}))
+		switch {
+		case err == nil:
+			deleted++
+		case errors.Is(err, provider.ErrModelNotFound):
+			notFound = err
+		default:
+			return o.pool.hostError(h, "delete", model, err)
+		}
+	}
+	o.pool.Refresh(ctx)
+	provider.InvalidateModels("ollama")
+	if deleted == 0 {
+		return notFound
+	}
+	return nil
+}
+
+// ShowModel 返回第一台有该模型的主机上的详情；多主机时列出所有已有该模型的主机
+func (o *Ollama) ShowModel(ctx context.Context, model string) (types.ModelDetails, error) {
+	if model == "" {
+		return types.ModelDetails{}, errNoModel
+	}
+	var (
+		out     types.ModelDetails
+		found   bool
+		lastErr error
+	)
+	for _, h := range o.pool.hosts {
+		show, err := h.client.Show(ctx, &api.ShowRequest{Model: model})
+		if err != nil {
+			lastErr = mapError(err)
+			continue
+		}
+		if !found {
+			out = toDetails(model, show)
+			found = true
+		}
+		if l := o.pool.label(h); l != "" {
+			out.Hosts = append(out.Hosts, l)
+		}
+	}
+	if !found {
+		return types.ModelDetails{}, lastErr
+	}
+	return out, nil
+}
+
+// KeepAlive 用空 prompt 调用 /api/generate 控制模型常驻：
+// d > 0 在一台主机上加载并保持 d；d < 0 永久常驻；d == 0 在所有主机上立即卸载
+func (o *Ollama) KeepAlive(ctx context.Context, model string, d time.Duration) error {
+	if model == "" {
+		return errNoModel
+	}
+	req := &api.GenerateRequest{Model: model, KeepAlive: &api.Duration{Duration: d}}
+	noop := func(api.GenerateResponse) error { return nil }
+	if d != 0 {
+		return o.pool.Do(ctx, model, func(c *api.Client) error {
+			return streamError(c.Generate(ctx, req, noop))
+		})
+	}
+
+	var notFound error
+	unloaded := 0
+	for _, h := range o.pool.hosts {
+		err := streamError(h.client.Generate(ctx, req, noop))
+		switch {
+		case err == nil:
+			unloaded++
+		case errors.Is(err, provider.ErrModelNotFound):
+			notFound = err
+		default:
+			return o.pool.hostError(h, "unload", model, err)
+		}
+	}
+	if unloaded == 0 {
+		return notFound
+	}
+	return nil
+}
+
+// streamError 流式接口（pull / generate）的错误写在响应流的 {"error"} 中，
+// SDK 只返回消息文本，需按消息内容识别
+func streamError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var se api.StatusError
+	if errors.As(err, &se) {
+		return mapError(err)
+	}
+	if strings.Contains(err.Error(), "file does not exist") { // pull 不存在的模型
+		return &provider.Error{Kind: provider.ErrModelNotFound, Provider: "ollama", Err: err}
+	}
+	var pe *provider.Error
+	if c := provider.Classify("ollama", "", err.Error()); errors.As(c, &pe) {
+		return c
+	}
+	return mapError(err)
+}
+
+var errNoModel = &provider.Error{Kind: provider.ErrInvalidRequest, Provider: "ollama", Err: errors.New("model name required")}
+
+func toDetails(model string, show *api.ShowResponse) types.ModelDetails {
+	info := types.ModelInfo{ID: model, Streaming: true}
+	applyCapabilities(&info, show)
+	d := types.ModelDetails{
+		ModelInfo:     info,
+		Family:        show.Details.Family,
+		ParameterSize: show.Details.ParameterSize,
+		Quantization:  show.Details.QuantizationLevel,
+		Format:        show.Details.Format,
+		Parameters:    show.Parameters,
+		Template:      show.Template,
+		License:       show.License,
+		ModifiedAt:    show.ModifiedAt,
+	}
+	for _, c := range show.Capabilities {
+		d.Capabilities = append(d.Capabilities, string(c))
+	}
+	return d
+}
+
+/* ---------- pool helpers ---------- */
+
+// targets 拉取模型的目标：所有健康主机；都不健康时仍尝试全部
+func (p *Pool) targets() []*host {
+	var out []*host
+	for _, h := range p.hosts {
+		h.mu.RLock()
+		if h.healthy {
+			out = append(out, h)
+		}
+		h.mu.RUnlock()
+	}
+	if len(out) == 0 {
+		return p.hosts
+	}
+	return out
+}
+
+// label 多主机时返回主机地址，单主机时为空
+func (p *Pool) label(h *host) string {
+	if len(p.hosts) == 1 {
+		return ""
+	}
+	return h.url
+}
+
+// hostError 多主机时在消息中标注出错的主机；err 应已映射
+func (p *Pool) hostError(h *host, op, model string, err error) error {
+	if l := p.label(h); l != "" {
+		return fmt.Errorf("%s %s on %s: %w", op, model, l, err)
+	}
+	return fmt.Errorf("%s %s: %w", op, model, err)
+}
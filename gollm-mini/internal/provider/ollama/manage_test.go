@@ -0,0 +1,113 @@
+package ollama
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"gollm-mini/internal/provider"
+	"gollm-mini/internal/types"
+)
+
+func TestPullModelAllHosts(t *testing.T) {
+	a, b := newFake(t, "a", "llama3:latest"), newFake(t, "b")
+	p := newTestPool(t, LeastOutstanding, a, b)
+	o := NewWithPool("", p)
+
+	var events []types.PullProgress
+	if err := o.PullModel(context.Background(), "qwen2:7b", func(pp types.PullProgress) {
+		events = append(events, pp)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	hosts := map[string]string{} // host → 最后一条状态
+	for _, e := range events {
+		hosts[e.Host] = e.Status
+	}
+	if len(hosts) != 2 || hosts[a.URL] != "success" || hosts[b.URL] != "success" {
+		t.Fatalf("progress by host = %v", hosts)
+	}
+	if !a.has("qwen2:7b") || !b.has("qwen2:7b") {
+		t.Fatal("model not pulled on every host")
+	}
+	// 拉取后刷新连接池，新模型立即可路由
+	for _, st := range p.Status() {
+		if !slices.Contains(st.Models, "qwen2:7b") {
+			t.Errorf("host %s models %v after pull", st.URL, st.Models)
+		}
+	}
+}
+
+func TestPullMissingModel(t *testing.T) {
+	p := newTestPool(t, LeastOutstanding, newFake(t, "a"))
+	err := NewWithPool("", p).PullModel(context.Background(), "missing", nil)
+	if !errors.Is(err, provider.ErrModelNotFound) {
+		t.Fatalf("err = %v, want ErrModelNotFound", err)
+	}
+}
+
+func TestDeleteModel(t *testing.T) {
+	a, b := newFake(t, "a", "llama3:latest"), newFake(t, "b", "qwen2:7b")
+	o := NewWithPool("", newTestPool(t, LeastOutstanding, a, b))
+
+	// 只有 a 有该模型：b 的 404 不算失败
+	if err := o.DeleteModel(context.Background(), "llama3"); err != nil {
+		t.Fatal(err)
+	}
+	if a.has("llama3") || !b.has("qwen2:7b") {
+		t.Fatal("wrong models deleted")
+	}
+	if err := o.DeleteModel(context.Background(), "llama3"); !errors.Is(err, provider.ErrModelNotFound) {
+		t.Fatalf("second delete: err = %v, want ErrModelNotFound", err)
+	}
+}
+
+func TestShowModel(t *testing.T) {
+	a, b := newFake(t, "a", "llama3:latest"), newFake(t, "b", "llama3:latest")
+	o := NewWithPool("", newTestPool(t, LeastOutstanding, a, b, newFake(t, "c")))
+
+	d, err := o.ShowModel(context.Background(), "llama3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Family != "llama" || d.Quantization != "Q4_0" || d.ContextWindow != 8192 || !d.Tools {
+		t.Errorf("details = %+v", d)
+	}
+	if len(d.Hosts) != 2 {
+		t.Errorf("hosts = %v, want the two hosts that have the model", d.Hosts)
+	}
+	if _, err := o.ShowModel(context.Background(), "nope"); !errors.Is(err, provider.ErrModelNotFound) {
+		t.Errorf("unknown model: err = %v", err)
+	}
+}
+
+func TestKeepAlive(t *testing.T) {
+	a, b := newFake(t, "a", "llama3:latest"), newFake(t, "b", "llama3:latest")
+	o := NewWithPool("", newTestPool(t, LeastOutstanding, a, b))
+	ctx := context.Background()
+
+	// 预热只打到一台主机
+	if err := o.KeepAlive(ctx, "llama3", 10*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(a.keepAlive) + len(b.keepAlive); n != 1 {
+		t.Fatalf("load sent to %d hosts, want 1", n)
+	}
+
+	// 卸载打到所有主机
+	if err := o.KeepAlive(ctx, "llama3", 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []*fakeOllama{a, b} {
+		if last := f.keepAlive[len(f.keepAlive)-1]; last != `"0s"` {
+			t.Errorf("host %s keep_alive = %s, want \"0s\"", f.name, last)
+		}
+	}
+
+	if err := o.KeepAlive(ctx, "nope", 0); !errors.Is(err, provider.ErrModelNotFound) {
+		t.Errorf("unload unknown model: err = %v", err)
+	}
+}
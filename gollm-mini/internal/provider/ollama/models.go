@@ -0,0 +1,75 @@
+package ollama
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ollama/ollama/api"
+	"gollm-mini/internal/types"
+)
+
+// ListModels 读取各主机的 /api/tags（去重），再用 /api/show 补充能力与上下文长度
+func (o *Ollama) ListModels(ctx context.Context) ([]types.ModelInfo, error) {
+	var (
+		out     []types.ModelInfo
+		seen    = map[string]bool{}
+		lastErr error
+	)
+	for _, h := range o.pool.hosts {
+		tags, err := h.client.List(ctx)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		for _, m := range tags.Models {
+			if seen[m.Name] {
+				continue
+			}
+			seen[m.Name] = true
+
+			info := types.ModelInfo{ID: m.Name, Streaming: true}
+			show, err := h.client.Show(ctx, &api.ShowRequest{Model: m.Name})
+			if err == nil {
+				applyCapabilities(&info, show)
+			} // 单个模型失败不影响整体列表
+			out = append(out, info)
+		}
+	}
+	if len(out) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return out, nil
+}
+
+// HealthCheck 刷新连接池，至少一台主机可用即视为健康
+func (o *Ollama) HealthCheck(ctx context.Context) error {
+	o.pool.Refresh(ctx)
+	if !o.pool.healthy() {
+		return errors.New("no healthy ollama host")
+	}
+	return nil
+}
+
+func applyCapabilities(info *types.ModelInfo, show *api.ShowResponse) {
+	for _, c := range show.Capabilities {
+		switch c {
+		case "embedding":
+			info.Embeddings = true
+		case "tools":
+			info.Tools = true
+		case "vision":
+			info.Vision = true
+		}
+	}
+	// 纯 embedding 模型不能对话
+	if info.Embeddings && len(show.Capabilities) == 1 {
+		info.Streaming = false
+	}
+	// model_info 中的 "<arch>.context_length"
+	if arch, ok := show.ModelInfo["general.architecture"].(string); ok {
+		if n, ok := show.ModelInfo[fmt.Sprintf("%s.context_length", arch)].(float64); ok {
+			info.ContextWindow = int(n)
+		}
+	}
+}
@@ -2,6 +2,9 @@ package ollama
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+
 	"github.com/ollama/ollama/api" // 官方 SDK
 	"gollm-mini/internal/provider" // 注册表
 	"gollm-mini/internal/types"
@@ -9,36 +12,46 @@ import (
 
 // Ollama 实现 gollm-mini-mini 的 Provider 接口
 type Ollama struct {
-	client *api.Client
-	model  string
+	pool  *Pool
+	model string
 }
 
-func (o *Ollama) SetModel(m string) { o.model = m }
-
-// New 返回一个 Ollama Provider；如果你想连到远端，把 baseURL 写进去
+// New 返回一个使用进程级共享连接池的 Ollama Provider（OLLAMA_HOST / OLLAMA_HOSTS）
 func New(model string) *Ollama {
-	cli, _ := api.ClientFromEnvironment() // 读 OLLAMA_HOST，不设就用本地
-	return &Ollama{client: cli, model: model}
+	return &Ollama{pool: defaultPool(), model: model}
 }
 
+// Model 实际使用的模型名
+func (o *Ollama) Model() string { return o.model }
+
+// NewWithPool 使用指定连接池
+func NewWithPool(model string, pool *Pool) *Ollama {
+	return &Ollama{pool: pool, model: model}
+}
+
+// SupportsJSONSchema Ollama ≥ 0.5 的 format 字段接受 JSON Schema
+func (o *Ollama) SupportsJSONSchema() bool { return true }
+
 // Generate 把历史对话打给 /api/chat，取最后一条回复
-func (o *Ollama) Generate(ctx context.Context, msgs []types.Message) (string, types.Usage, error) {
-	om := make([]api.Message, len(msgs))
-	for i, m := range msgs {
-		om[i] = api.Message{Role: string(m.Role), Content: m.Content}
+func (o *Ollama) Generate(ctx context.Context, msgs []types.Message, opts types.GenerateOptions) (string, types.Usage, error) {
+	om, err := toAPIMessages(ctx, msgs)
+	if err != nil {
+		return "", types.Usage{}, err
 	}
 	stream := false
 	req := &api.ChatRequest{
 		Model:    o.model,
 		Messages: om,
 		Stream:   &stream,
+		Format:   opts.JSONSchema, // 非空时按 schema 约束解码
+		Options:  toOptions(opts),
 	}
 	var (
 		full  string
 		usage types.Usage
 	)
 	// Chat 会把每个（可能是流）chunk 交给回调
-	if err := o.client.Chat(ctx, req, func(cr api.ChatResponse) error {
+	if err := o.chat(ctx, req, func(cr api.ChatResponse) error {
 		full = cr.Message.Content
 		usage = types.Usage{
 			PromptTokens:     cr.Metrics.PromptEvalCount,
@@ -52,23 +65,31 @@ func (o *Ollama) Generate(ctx context.Context, msgs []types.Message) (string, ty
 	return full, usage, nil
 }
 
-func (o *Ollama) Stream(ctx context.Context, msgs []types.Message, cb func(types.Chunk)) (types.Usage, error) {
-	om := make([]api.Message, len(msgs))
-	for i, m := range msgs {
-		om[i] = api.Message{Role: string(m.Role), Content: m.Content}
+func (o *Ollama) Stream(ctx context.Context, msgs []types.Message, opts types.GenerateOptions, cb func(types.Chunk)) (types.Usage, error) {
+	om, err := toAPIMessages(ctx, msgs)
+	if err != nil {
+		return types.Usage{}, err
 	}
 	stream := true
-	req := &api.ChatRequest{Model: o.model, Messages: om, Stream: &stream}
+	req := &api.ChatRequest{Model: o.model, Messages: om, Stream: &stream, Format: opts.JSONSchema, Options: toOptions(opts)}
 
 	var usage types.Usage
-	if err := o.client.Chat(ctx, req, func(cr api.ChatResponse) error {
+	if err := o.chat(ctx, req, func(cr api.ChatResponse) error {
+		if cr.Done {
+			// 最后一块（done=true）携带服务端统计的准确 token 数
+			usage = types.Usage{
+				PromptTokens:     cr.Metrics.PromptEvalCount,
+				CompletionTokens: cr.Metrics.EvalCount,
+			}
+		}
 		token := cr.Message.Content
 		if token == "" {
 			return nil
 		}
-		cb(types.Chunk{Content: token, Delta: 1}) // Ollama 无法精确分词，这里假设 1
-		usage.CompletionTokens += 1
-		usage.PromptTokens = cr.Metrics.PromptEvalCount
+		cb(types.Chunk{Content: token, Delta: 1}) // 每块通常对应 1 个 token
+		if !cr.Done {
+			usage.CompletionTokens++
+		}
 		return nil
 	}); err != nil {
 		return usage, err
@@ -76,7 +97,121 @@ func (o *Ollama) Stream(ctx context.Context, msgs []types.Message, cb func(types
 	return usage, nil
 }
 
+// GenerateWithTools 携带工具定义调用 /api/chat（非流式），解析返回的 tool_calls
+func (o *Ollama) GenerateWithTools(ctx context.Context, msgs []types.Message, tools []types.Tool, opts types.GenerateOptions) (types.Message, types.Usage, error) {
+	at := make(api.Tools, len(tools))
+	for i, t := range tools {
+		at[i] = api.Tool{Type: "function"}
+		at[i].Function.Name = t.Name
+		at[i].Function.Description = t.Description
+		if len(t.Parameters) > 0 {
+			if err := json.Unmarshal(t.Parameters, &at[i].Function.Parameters); err != nil {
+				return types.Message{}, types.Usage{}, &provider.Error{Kind: provider.ErrInvalidRequest, Provider: "ollama",
+					Err: fmt.Errorf("tool %s: parameters: %w", t.Name, err)}
+			}
+		}
+	}
+
+	om, err := toAPIMessages(ctx, msgs)
+	if err != nil {
+		return types.Message{}, types.Usage{}, err
+	}
+	stream := false
+	req := &api.ChatRequest{Model: o.model, Messages: om, Tools: at, Stream: &stream, Options: toOptions(opts)}
+
+	var (
+		out   = types.Message{Role: types.RoleAssistant}
+		usage types.Usage
+	)
+	if err := o.chat(ctx, req, func(cr api.ChatResponse) error {
+		out.Content += cr.Message.Content
+		for _, tc := range cr.Message.ToolCalls {
+			args, _ := json.Marshal(tc.Function.Arguments)
+			out.ToolCalls = append(out.ToolCalls, types.ToolCall{
+				// Ollama 不返回调用 ID，这里按序号生成
+				ID:        fmt.Sprintf("call_%d", len(out.ToolCalls)),
+				Name:      tc.Function.Name,
+				Arguments: args,
+			})
+		}
+		usage = types.Usage{
+			PromptTokens:     cr.Metrics.PromptEvalCount,
+			CompletionTokens: cr.Metrics.EvalCount,
+		}
+		return nil
+	}); err != nil {
+		return out, usage, err
+	}
+	return out, usage, nil
+}
+
+// Embed 调用 /api/embed，一次请求批量计算
+func (o *Ollama) Embed(ctx context.Context, inputs []string) ([][]float32, types.Usage, error) {
+	var resp *api.EmbedResponse
+	err := o.pool.Do(ctx, o.model, func(c *api.Client) error {
+		var e error
+		resp, e = c.Embed(ctx, &api.EmbedRequest{Model: o.model, Input: inputs})
+		return e
+	})
+	if err != nil {
+		return nil, types.Usage{}, err
+	}
+	return resp.Embeddings, types.Usage{PromptTokens: resp.PromptEvalCount}, nil
+}
+
+// chat 经连接池选主机调用 /api/chat
+func (o *Ollama) chat(ctx context.Context, req *api.ChatRequest, fn api.ChatResponseFunc) error {
+	return o.pool.Do(ctx, o.model, func(c *api.Client) error { return c.Chat(ctx, req, fn) })
+}
+
+// toAPIMessages 把通用消息转换成 Ollama 格式（含图片与 assistant 的 tool_calls）
+func toAPIMessages(ctx context.Context, msgs []types.Message) ([]api.Message, error) {
+	om := make([]api.Message, len(msgs))
+	for i, m := range msgs {
+		om[i] = api.Message{Role: string(m.Role), Content: m.Text()}
+		for _, img := range m.Images() {
+			b, err := imageBytes(ctx, img)
+			if err != nil {
+				return nil, err
+			}
+			om[i].Images = append(om[i].Images, b)
+		}
+		for _, tc := range m.ToolCalls {
+			var args api.ToolCallFunctionArguments
+			_ = json.Unmarshal(tc.Arguments, &args)
+			om[i].ToolCalls = append(om[i].ToolCalls, api.ToolCall{
+				Function: api.ToolCallFunction{Name: tc.Name, Arguments: args},
+			})
+		}
+	}
+	return om, nil
+}
+
+// toOptions 把通用采样参数映射为 Ollama 的 options 字段
+func toOptions(opts types.GenerateOptions) map[string]any {
+	m := map[string]any{}
+	if opts.Temperature != nil {
+		m["temperature"] = *opts.Temperature
+	}
+	if opts.TopP != nil {
+		m["top_p"] = *opts.TopP
+	}
+	if opts.MaxTokens > 0 {
+		m["num_predict"] = opts.MaxTokens
+	}
+	if len(opts.Stop) > 0 {
+		m["stop"] = opts.Stop
+	}
+	if opts.Seed != nil {
+		m["seed"] = *opts.Seed
+	}
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
+
 // 在 init 中注册到全局表，实现“热插拔”
 func init() {
-	provider.Register("ollama", New("llama3"))
+	provider.Register("ollama", provider.Simple("llama3", New))
 }
@@ -0,0 +1,29 @@
+package ollama
+
+import (
+	"reflect"
+	"testing"
+
+	"gollm-mini/internal/types"
+)
+
+func TestToOptions(t *testing.T) {
+	temp, topP, seed := 0.0, 0.9, 7
+	cases := []struct {
+		name string
+		in   types.GenerateOptions
+		want map[string]any
+	}{
+		{"unset", types.GenerateOptions{}, nil},
+		{"temperature 0 kept", types.GenerateOptions{Temperature: &temp}, map[string]any{"temperature": 0.0}},
+		{"all", types.GenerateOptions{Temperature: &temp, TopP: &topP, MaxTokens: 64, Stop: []string{"\n\n"}, Seed: &seed},
+			map[string]any{"temperature": 0.0, "top_p": 0.9, "num_predict": 64, "stop": []string{"\n\n"}, "seed": 7}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := toOptions(tc.in); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("toOptions = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
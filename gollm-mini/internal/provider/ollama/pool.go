@@ -0,0 +1,272 @@
+package ollama
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ollama/ollama/api"
+	"gollm-mini/internal/provider"
+)
+
+// Strategy 多主机时的负载均衡策略
+type Strategy string
+
+const (
+	LeastOutstanding Strategy = "least" // 选择在途请求最少的主机（默认）
+	RoundRobin       Strategy = "rr"    // 轮询
+)
+
+// defaultRefresh 后台刷新 /api/tags 与健康状态的间隔，可用 OLLAMA_POOL_REFRESH 覆盖
+const defaultRefresh = 30 * time.Second
+
+// host 单个 Ollama 实例
+type host struct {
+	url      string
+	client   *api.Client
+	inflight atomic.Int64
+
+	mu      sync.RWMutex
+	healthy bool
+	models  map[string]bool // 规范化后的模型名（带 tag）
+}
+
+// Pool 一组 Ollama 主机；按模型可用性 + 健康状态 + 策略挑选主机
+type Pool struct {
+	hosts    []*host
+	strategy Strategy
+	next     atomic.Uint64 // 轮询游标
+}
+
+// HostStatus 主机状态快照
+type HostStatus struct {
+	URL      string   `json:"url"`
+	Healthy  bool     `json:"healthy"`
+	Inflight int64    `json:"inflight"`
+	Models   []string `json:"models"`
+}
+
+// NewPool 根据地址列表创建连接池；新建主机默认视为健康，直到探测失败
+func NewPool(urls []string, strategy Strategy) (*Pool, error) {
+	if len(urls) == 0 {
+		return nil, errors.New("ollama pool: no hosts")
+	}
+	switch strategy {
+	case "":
+		strategy = LeastOutstanding
+	case LeastOutstanding, RoundRobin:
+	default:
+		return nil, fmt.Errorf("ollama pool: unknown strategy %q", strategy)
+	}
+	p := &Pool{strategy: strategy}
+	for _, raw := range urls {
+		u, err := url.Parse(strings.TrimRight(strings.TrimSpace(raw), "/"))
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("ollama pool: invalid host %q", raw)
+		}
+		p.hosts = append(p.hosts, &host{
+			url:     u.String(),
+			client:  api.NewClient(u, http.DefaultClient),
+			healthy: true,
+		})
+	}
+	return p, nil
+}
+
+var (
+	sharedPool     *Pool
+	sharedPoolOnce sync.Once
+)
+
+// defaultPool 进程内共享的连接池：
+//   - OLLAMA_HOSTS 未设置：单主机，沿用 api.ClientFromEnvironment（OLLAMA_HOST）
+//   - OLLAMA_HOSTS=http://gpu-01:11434,http://gpu-02:11434：多主机，OLLAMA_BALANCE=least|rr
+func defaultPool() *Pool {
+	sharedPoolOnce.Do(func() {
+		if hosts := os.Getenv("OLLAMA_HOSTS"); hosts != "" {
+			p, err := NewPool(strings.Split(hosts, ","), Strategy(os.Getenv("OLLAMA_BALANCE")))
+			if err == nil {
+				p.Start(context.Background(), refreshInterval())
+				sharedPool = p
+				return
+			}
+			log.Printf("[WARN] %v, falling back to OLLAMA_HOST", err)
+		}
+		cli, _ := api.ClientFromEnvironment() // 读 OLLAMA_HOST，不设就用本地
+		sharedPool = &Pool{strategy: LeastOutstanding, hosts: []*host{{url: "env", client: cli, healthy: true}}}
+	})
+	return sharedPool
+}
+
+func refreshInterval() time.Duration {
+	if v := os.Getenv("OLLAMA_POOL_REFRESH"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+		log.Printf("[WARN] invalid OLLAMA_POOL_REFRESH %q", v)
+	}
+	return defaultRefresh
+}
+
+// Start 立即刷新一次，之后按 interval 在后台刷新，直到 ctx 结束
+func (p *Pool) Start(ctx context.Context, interval time.Duration) {
+	p.Refresh(ctx)
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				p.Refresh(ctx)
+			}
+		}
+	}()
+}
+
+// Refresh 并发拉取各主机的 /api/tags，更新模型列表与健康状态
+func (p *Pool) Refresh(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, h := range p.hosts {
+		wg.Add(1)
+		go func(h *host) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			tags, err := h.client.List(ctx)
+
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			if err != nil {
+				if h.healthy {
+					log.Printf("[OLLAMA] host %s unhealthy: %v", h.url, err)
+				}
+				h.healthy = false
+				return
+			}
+			if !h.healthy {
+				log.Printf("[OLLAMA] host %s healthy again", h.url)
+			}
+			h.healthy = true
+			h.models = make(map[string]bool, len(tags.Models))
+			for _, m := range tags.Models {
+				h.models[normalizeModel(m.Name)] = true
+			}
+		}(h)
+	}
+	wg.Wait()
+}
+
+// Do 选出一台主机执行 fn；连接层错误会把该主机标为不健康，直到下次刷新成功。
+// 返回的错误已映射为 provider 的错误分类。
+func (p *Pool) Do(ctx context.Context, model string, fn func(c *api.Client) error) error {
+	h := p.pick(model)
+	h.inflight.Add(1)
+	defer h.inflight.Add(-1)
+
+	err := fn(h.client)
+	if err != nil && ctx.Err() == nil && len(p.hosts) > 1 {
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			h.mu.Lock()
+			h.healthy = false
+			h.mu.Unlock()
+			log.Printf("[OLLAMA] host %s unhealthy: %v", h.url, err)
+		}
+	}
+	return mapError(err)
+}
+
+// mapError 把 api.StatusError / 网络错误映射为 provider 的错误分类
+func mapError(err error) error {
+	var se api.StatusError
+	if errors.As(err, &se) {
+		return provider.FromStatus("ollama", se.StatusCode, nil, se.ErrorMessage, err)
+	}
+	return provider.FromTransport("ollama", err)
+}
+
+// Status 返回各主机当前状态
+func (p *Pool) Status() []HostStatus {
+	out := make([]HostStatus, 0, len(p.hosts))
+	for _, h := range p.hosts {
+		h.mu.RLock()
+		st := HostStatus{URL: h.url, Healthy: h.healthy, Inflight: h.inflight.Load()}
+		for m := range h.models {
+			st.Models = append(st.Models, m)
+		}
+		h.mu.RUnlock()
+		out = append(out, st)
+	}
+	return out
+}
+
+// pick 候选优先级：健康且已有该模型 > 健康 > 全部（都不健康时仍尝试，避免整体不可用）
+func (p *Pool) pick(model string) *host {
+	if len(p.hosts) == 1 {
+		return p.hosts[0]
+	}
+	model = normalizeModel(model)
+	var withModel, healthy []*host
+	for _, h := range p.hosts {
+		h.mu.RLock()
+		if h.healthy {
+			healthy = append(healthy, h)
+			if h.models[model] {
+				withModel = append(withModel, h)
+			}
+		}
+		h.mu.RUnlock()
+	}
+	candidates := withModel
+	if len(candidates) == 0 {
+		candidates = healthy
+	}
+	if len(candidates) == 0 {
+		candidates = p.hosts
+	}
+
+	start := int(p.next.Add(1)-1) % len(candidates)
+	if p.strategy == RoundRobin {
+		return candidates[start]
+	}
+	// least outstanding：从轮询位置开始扫描，在途数相同时轮流分配
+	best := candidates[start]
+	for i := 1; i < len(candidates); i++ {
+		h := candidates[(start+i)%len(candidates)]
+		if h.inflight.Load() < best.inflight.Load() {
+			best = h
+		}
+	}
+	return best
+}
+
+// healthy 是否至少有一台主机可用
+func (p *Pool) healthy() bool {
+	for _, h := range p.hosts {
+		h.mu.RLock()
+		ok := h.healthy
+		h.mu.RUnlock()
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeModel "llama3" 与 "llama3:latest" 视为同一模型
+func normalizeModel(name string) string {
+	if name != "" && !strings.Contains(name, ":") {
+		return name + ":latest"
+	}
+	return name
+}
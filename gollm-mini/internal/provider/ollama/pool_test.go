@@ -0,0 +1,255 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gollm-mini/internal/types"
+)
+
+// fakeOllama 是实现 /api/tags、/api/chat 与模型管理接口的假 Ollama 实例
+type fakeOllama struct {
+	*httptest.Server
+	name  string
+	chats atomic.Int64
+	block atomic.Bool // 为 true 时 /api/chat 阻塞直到 hold 关闭
+	hold  chan struct{}
+
+	mu        sync.Mutex
+	models    []string
+	keepAlive []string // /api/generate 收到的 keep_alive 原始值
+}
+
+func (f *fakeOllama) has(model string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, m := range f.models {
+		if normalizeModel(m) == normalizeModel(model) {
+			return true
+		}
+	}
+	return false
+}
+
+func notFound(w http.ResponseWriter, model string) {
+	w.WriteHeader(http.StatusNotFound)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": "model '" + model + "' not found"})
+}
+
+func newFake(t *testing.T, name string, models ...string) *fakeOllama {
+	f := &fakeOllama{name: name, models: models, hold: make(chan struct{})}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/tags", func(w http.ResponseWriter, r *http.Request) {
+		var list []map[string]string
+		f.mu.Lock()
+		for _, m := range f.models {
+			list = append(list, map[string]string{"name": m, "model": m})
+		}
+		f.mu.Unlock()
+		_ = json.NewEncoder(w).Encode(map[string]any{"models": list})
+	})
+	mux.HandleFunc("/api/chat", func(w http.ResponseWriter, r *http.Request) {
+		f.chats.Add(1)
+		if f.block.Load() {
+			<-f.hold
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"model":             "x",
+			"message":           map[string]string{"role": "assistant", "content": f.name},
+			"done":              true,
+			"prompt_eval_count": 3,
+			"eval_count":        1,
+		})
+	})
+	mux.HandleFunc("/api/pull", func(w http.ResponseWriter, r *http.Request) {
+		var req struct{ Model string }
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		enc := json.NewEncoder(w)
+		if req.Model == "missing" {
+			_ = enc.Encode(map[string]string{"error": "pull model manifest: file does not exist"})
+			return
+		}
+		_ = enc.Encode(map[string]any{"status": "pulling manifest"})
+		for _, done := range []int{50, 100} {
+			_ = enc.Encode(map[string]any{"status": "pulling 6a0746a1ec1a", "digest": "sha256:6a0746a1ec1a", "total": 100, "completed": done})
+		}
+		_ = enc.Encode(map[string]any{"status": "success"})
+		if !f.has(req.Model) {
+			f.mu.Lock()
+			f.models = append(f.models, normalizeModel(req.Model))
+			f.mu.Unlock()
+		}
+	})
+	mux.HandleFunc("/api/delete", func(w http.ResponseWriter, r *http.Request) {
+		var req struct{ Model string }
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if !f.has(req.Model) {
+			notFound(w, req.Model)
+			return
+		}
+		f.mu.Lock()
+		kept := f.models[:0]
+		for _, m := range f.models {
+			if normalizeModel(m) != normalizeModel(req.Model) {
+				kept = append(kept, m)
+			}
+		}
+		f.models = kept
+		f.mu.Unlock()
+	})
+	mux.HandleFunc("/api/show", func(w http.ResponseWriter, r *http.Request) {
+		var req struct{ Model string }
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if !f.has(req.Model) {
+			notFound(w, req.Model)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"parameters":   `stop "<|eot_id|>"`,
+			"details":      map[string]string{"format": "gguf", "family": "llama", "parameter_size": "8.0B", "quantization_level": "Q4_0"},
+			"capabilities": []string{"completion", "tools"},
+			"model_info":   map[string]any{"general.architecture": "llama", "llama.context_length": 8192},
+		})
+	})
+	mux.HandleFunc("/api/generate", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model     string          `json:"model"`
+			KeepAlive json.RawMessage `json:"keep_alive"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		f.mu.Lock()
+		f.keepAlive = append(f.keepAlive, string(req.KeepAlive))
+		f.mu.Unlock()
+		if !f.has(req.Model) {
+			notFound(w, req.Model)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"model": req.Model, "done": true})
+	})
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func newTestPool(t *testing.T, s Strategy, fakes ...*fakeOllama) *Pool {
+	t.Helper()
+	var urls []string
+	for _, f := range fakes {
+		urls = append(urls, f.URL)
+	}
+	p, err := NewPool(urls, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Refresh(context.Background())
+	return p
+}
+
+func ask(t *testing.T, p *Pool, model string) string {
+	t.Helper()
+	txt, _, err := NewWithPool(model, p).Generate(context.Background(),
+		[]types.Message{{Role: types.RoleUser, Content: "hi"}}, types.GenerateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return txt
+}
+
+func TestRoundRobin(t *testing.T) {
+	a, b := newFake(t, "a", "llama3:latest"), newFake(t, "b", "llama3:latest")
+	p := newTestPool(t, RoundRobin, a, b)
+
+	for i := 0; i < 6; i++ {
+		ask(t, p, "llama3")
+	}
+	if a.chats.Load() != 3 || b.chats.Load() != 3 {
+		t.Fatalf("want 3/3, got a=%d b=%d", a.chats.Load(), b.chats.Load())
+	}
+}
+
+func TestRoutesByModel(t *testing.T) {
+	a, b := newFake(t, "a", "llama3:latest"), newFake(t, "b", "qwen2:7b")
+	p := newTestPool(t, LeastOutstanding, a, b)
+
+	for i := 0; i < 4; i++ {
+		if got := ask(t, p, "qwen2:7b"); got != "b" {
+			t.Fatalf("qwen2:7b served by %s", got)
+		}
+		if got := ask(t, p, "llama3"); got != "a" {
+			t.Fatalf("llama3 served by %s", got)
+		}
+	}
+}
+
+func TestLeastOutstanding(t *testing.T) {
+	a, b := newFake(t, "a", "llama3:latest"), newFake(t, "b", "llama3:latest")
+	p := newTestPool(t, LeastOutstanding, a, b)
+
+	// a 上挂一个未完成的请求，后续请求都应落到 b
+	a.block.Store(true)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for a.chats.Load() == 0 { // 轮询位置决定首个请求去向；确保它落在 a
+			ask(t, p, "llama3")
+		}
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for a.chats.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	before := b.chats.Load()
+	for i := 0; i < 4; i++ {
+		if got := ask(t, p, "llama3"); got != "b" {
+			t.Fatalf("request %d served by %s while a is busy", i, got)
+		}
+	}
+	if b.chats.Load()-before != 4 {
+		t.Fatalf("want 4 more requests on b, got %d", b.chats.Load()-before)
+	}
+	close(a.hold)
+	wg.Wait()
+}
+
+func TestUnhealthyHostDropped(t *testing.T) {
+	a, b := newFake(t, "a", "llama3:latest"), newFake(t, "b", "llama3:latest")
+	p := newTestPool(t, RoundRobin, a, b)
+
+	a.Close() // a 宕机
+	p.Refresh(context.Background())
+	for i := 0; i < 4; i++ {
+		if got := ask(t, p, "llama3"); got != "b" {
+			t.Fatalf("served by %s after a went down", got)
+		}
+	}
+	for _, st := range p.Status() {
+		if st.URL == a.URL && st.Healthy {
+			t.Fatal("a should be marked unhealthy")
+		}
+	}
+}
+
+func TestConnectionErrorMarksUnhealthy(t *testing.T) {
+	a, b := newFake(t, "a", "llama3:latest"), newFake(t, "b", "llama3:latest")
+	p := newTestPool(t, RoundRobin, a, b)
+	a.Close() // 不刷新，靠请求失败发现
+
+	var failed int
+	for i := 0; i < 4; i++ {
+		_, _, err := NewWithPool("llama3", p).Generate(context.Background(),
+			[]types.Message{{Role: types.RoleUser, Content: "hi"}}, types.GenerateOptions{})
+		if err != nil {
+			failed++
+		}
+	}
+	if failed != 1 {
+		t.Fatalf("want exactly one failed request before a is dropped, got %d", failed)
+	}
+}
@@ -0,0 +1,133 @@
+package openai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+	"gollm-mini/internal/provider"
+)
+
+// defaultCompatConfig 未设置 OPENAI_COMPAT_CONFIG 时尝试读取的文件
+const defaultCompatConfig = "openai_compat.json"
+
+// Config 描述一个 OpenAI 兼容后端（vLLM / LM Studio / llama.cpp server ...）
+type Config struct {
+	Name      string            `json:"name"`                     // 注册到 provider 表的名字
+	BaseURL   string            `json:"base_url"`                 // 例如 http://gpu-01:8000/v1
+	APIKey    string            `json:"api_key,omitempty"`        // 明文 key，优先级低于 api_key_env
+	APIKeyEnv string            `json:"api_key_env,omitempty"`    // 从环境变量读取 key
+	Model     string            `json:"model"`                    // 默认模型
+	Headers   map[string]string `json:"headers,omitempty"`        // 每个请求附带的额外 Header
+	NoSchema  bool              `json:"no_json_schema,omitempty"` // 后端不支持 response_format=json_schema
+}
+
+// NewFromConfig 按配置创建一个 OpenAI 兼容客户端
+func NewFromConfig(cfg Config) *OpenAI {
+	key := cfg.APIKey
+	if v := os.Getenv(cfg.APIKeyEnv); cfg.APIKeyEnv != "" && v != "" {
+		key = v // 环境变量为空时退回 api_key
+	}
+	cc := openai.DefaultConfig(key)
+	if cfg.BaseURL != "" {
+		cc.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	}
+	var rt http.RoundTripper = captureTransport{base: http.DefaultTransport}
+	if len(cfg.Headers) > 0 {
+		rt = &headerTransport{base: rt, headers: cfg.Headers}
+	}
+	cc.HTTPClient = &http.Client{Transport: rt}
+	return &OpenAI{client: openai.NewClientWithConfig(cc), model: cfg.Model, name: cfg.Name, noSchema: cfg.NoSchema}
+}
+
+// LoadConfigs 读取 JSON 数组格式的兼容后端列表
+func LoadConfigs(path string) ([]Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfgs []Config
+	if err := json.Unmarshal(b, &cfgs); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for i, c := range cfgs {
+		if c.Name == "" || c.BaseURL == "" {
+			return nil, fmt.Errorf("%s: entry %d needs name and base_url", path, i)
+		}
+	}
+	return cfgs, nil
+}
+
+// reservedNames 内置 Provider 的名字：各包 init 顺序不定，不能只靠 provider.Lookup 判断
+var reservedNames = map[string]bool{"openai": true, "ollama": true, "anthropic": true, "hf": true}
+
+// RegisterCompat 把每个兼容后端以自己的名字注册到 provider 表；
+// 名字与内置 Provider、已注册的 Provider 或同一列表中的其他条目重复时整体拒绝
+func RegisterCompat(cfgs []Config) error {
+	seen := make(map[string]bool, len(cfgs))
+	for _, c := range cfgs {
+		_, registered := provider.Lookup(c.Name)
+		switch {
+		case reservedNames[c.Name] || registered:
+			return fmt.Errorf("compat backend %q: name already used by a registered provider", c.Name)
+		case seen[c.Name]:
+			return fmt.Errorf("compat backend %q: duplicate name", c.Name)
+		}
+		seen[c.Name] = true
+	}
+	for _, c := range cfgs {
+		provider.Register(c.Name, compatFactory(c))
+	}
+	return nil
+}
+
+// compatFactory 每次按请求的模型复制一份配置，默认模型取 cfg.Model
+func compatFactory(cfg Config) provider.Factory {
+	return func(model string) (provider.Provider, error) {
+		c := cfg
+		if model != "" {
+			c.Model = model
+		}
+		return NewFromConfig(c), nil
+	}
+}
+
+// headerTransport 给每个请求附加固定 Header
+type headerTransport struct {
+	base    http.RoundTripper
+	headers map[string]string
+}
+
+func (t *headerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	for k, v := range t.headers {
+		r.Header.Set(k, v)
+	}
+	return t.base.RoundTrip(r)
+}
+
+// registerCompatFromEnv 读取 OPENAI_COMPAT_CONFIG（默认 openai_compat.json）
+func registerCompatFromEnv() {
+	path := os.Getenv("OPENAI_COMPAT_CONFIG")
+	explicit := path != ""
+	if !explicit {
+		path = defaultCompatConfig
+	}
+	cfgs, err := LoadConfigs(path)
+	if err != nil {
+		// 默认文件不存在属正常情况，不打扰
+		if explicit || !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("[openai] skip compat backends: %v", err)
+		}
+		return
+	}
+	if err := RegisterCompat(cfgs); err != nil {
+		log.Printf("[openai] skip compat backends: %v", err)
+	}
+}
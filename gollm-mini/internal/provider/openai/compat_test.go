@@ -0,0 +1,135 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gollm-mini/internal/provider"
+	"gollm-mini/internal/types"
+)
+
+// newCompatServer 模拟 /v1/chat/completions，记录收到的请求
+func newCompatServer(t *testing.T, seen *[]*http.Request) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*seen = append(*seen, r.Clone(context.Background()))
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		var req struct{ Model string }
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"choices":[{"index":0,"message":{"role":"assistant","content":"hi from ` + req.Model + `"}}],
+			"usage":{"prompt_tokens":3,"completion_tokens":4}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+var hi = []types.Message{{Role: types.RoleUser, Content: "hi"}}
+
+func TestCompatRequest(t *testing.T) {
+	var seen []*http.Request
+	srv := newCompatServer(t, &seen)
+	t.Setenv("COMPAT_TEST_KEY", "env-key")
+
+	cases := []struct {
+		name    string
+		cfg     Config
+		wantKey string
+	}{
+		{"env key", Config{APIKey: "file-key", APIKeyEnv: "COMPAT_TEST_KEY"}, "env-key"},
+		{"empty env falls back", Config{APIKey: "file-key", APIKeyEnv: "COMPAT_TEST_UNSET"}, "file-key"},
+		{"plain key", Config{APIKey: "file-key"}, "file-key"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			seen = nil
+			cfg := tc.cfg
+			cfg.Name, cfg.BaseURL, cfg.Model = "vllm-test", srv.URL+"/v1/", "qwen"
+			cfg.Headers = map[string]string{"X-Team": "search"}
+
+			txt, u, err := NewFromConfig(cfg).Generate(context.Background(), hi, types.GenerateOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if txt != "hi from qwen" || u.Total() != 7 {
+				t.Errorf("Generate = %q %+v", txt, u)
+			}
+			if len(seen) != 1 {
+				t.Fatalf("%d requests", len(seen))
+			}
+			r := seen[0]
+			if got := r.Header.Get("Authorization"); got != "Bearer "+tc.wantKey {
+				t.Errorf("Authorization = %q, want Bearer %s", got, tc.wantKey)
+			}
+			if r.Header.Get("X-Team") != "search" {
+				t.Errorf("extra header missing: %v", r.Header)
+			}
+		})
+	}
+}
+
+func TestLoadConfigs(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	cfgs, err := LoadConfigs(write("ok.json", `[{"name":"vllm","base_url":"http://gpu:8000/v1","model":"qwen",
+		"headers":{"X-Team":"search"},"no_json_schema":true}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfgs) != 1 || cfgs[0].Name != "vllm" || cfgs[0].Headers["X-Team"] != "search" || !cfgs[0].NoSchema {
+		t.Errorf("cfgs = %+v", cfgs)
+	}
+
+	for name, body := range map[string]string{
+		"missing name": `[{"base_url":"http://x/v1"}]`,
+		"missing url":  `[{"name":"x"}]`,
+		"not array":    `{"name":"x"}`,
+	} {
+		if _, err := LoadConfigs(write(strings.ReplaceAll(name, " ", "_")+".json", body)); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+	if _, err := LoadConfigs(filepath.Join(dir, "missing.json")); !os.IsNotExist(err) {
+		t.Errorf("missing file: err = %v", err)
+	}
+}
+
+func TestRegisterCompatNames(t *testing.T) {
+	provider.Register("compat-existing", func(string) (provider.Provider, error) { return New(""), nil })
+	for name, cfgs := range map[string][]Config{
+		"builtin":    {{Name: "openai", BaseURL: "http://x/v1"}},
+		"other kind": {{Name: "ollama", BaseURL: "http://x/v1"}},
+		"registered": {{Name: "compat-existing", BaseURL: "http://x/v1"}},
+		"duplicate":  {{Name: "compat-dup", BaseURL: "http://a/v1"}, {Name: "compat-dup", BaseURL: "http://b/v1"}},
+	} {
+		if err := RegisterCompat(cfgs); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+	if _, ok := provider.Lookup("compat-dup"); ok {
+		t.Error("rejected list was partially registered")
+	}
+
+	if err := RegisterCompat([]Config{{Name: "compat-new", BaseURL: "http://x/v1", Model: "m"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := provider.Lookup("compat-new"); !ok {
+		t.Error("compat-new not registered")
+	}
+}
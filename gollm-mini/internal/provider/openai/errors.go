@@ -0,0 +1,58 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	openai "github.com/sashabaranov/go-openai"
+	"gollm-mini/internal/provider"
+)
+
+// headerSlot 请求 ctx 中保存响应头的位置；SDK 的错误类型不带 Header，Retry-After 只能从这里拿
+type headerSlot struct{}
+
+// captureTransport 把响应头写回 ctx 中的槽位
+type captureTransport struct{ base http.RoundTripper }
+
+func (t captureTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(r)
+	if err == nil {
+		if h, ok := r.Context().Value(headerSlot{}).(*http.Header); ok {
+			*h = resp.Header
+		}
+	}
+	return resp, err
+}
+
+// withHeaders 返回带响应头槽位的 ctx
+func withHeaders(ctx context.Context) (context.Context, *http.Header) {
+	h := new(http.Header)
+	return context.WithValue(ctx, headerSlot{}, h), h
+}
+
+// mapError 把 go-openai 的错误映射为 provider 的错误分类
+func (o *OpenAI) mapError(err error, hdr *http.Header) error {
+	if err == nil {
+		return nil
+	}
+	var h http.Header
+	if hdr != nil {
+		h = *hdr
+	}
+	var ae *openai.APIError
+	if errors.As(err, &ae) && ae.HTTPStatusCode > 0 {
+		return provider.FromStatus(o.name, ae.HTTPStatusCode, h, fmt.Sprintf("%v %s", ae.Code, ae.Message), err)
+	}
+	var re *openai.RequestError
+	if errors.As(err, &re) && re.HTTPStatusCode > 0 {
+		return provider.FromStatus(o.name, re.HTTPStatusCode, h, string(re.Body), err)
+	}
+	return provider.FromTransport(o.name, err)
+}
+
+// emptyChoices 后端返回 200 但没有任何 choice（多见于兼容后端异常），按过载处理以便重试 / 回退
+func (o *OpenAI) emptyChoices() error {
+	return &provider.Error{Kind: provider.ErrOverloaded, Provider: o.name, Err: errors.New("response has no choices")}
+}
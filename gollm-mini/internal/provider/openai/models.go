@@ -0,0 +1,63 @@
+package openai
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"gollm-mini/internal/types"
+)
+
+// knownModels 按前缀推断能力；/v1/models 只返回 ID，顺序即匹配优先级（长前缀在前）
+var knownModels = []struct {
+	prefix        string
+	contextWindow int
+	tools, vision bool
+}{
+	{"gpt-4.1", 1047576, true, true},
+	{"gpt-4o", 128000, true, true},
+	{"gpt-4-turbo", 128000, true, true},
+	{"gpt-4", 8192, true, false},
+	{"gpt-3.5-turbo", 16385, true, false},
+	{"o1-mini", 128000, false, false}, // 早期 o1 变体不支持工具与图片，必须排在 "o1" 之前
+	{"o1-preview", 128000, false, false},
+	{"o1", 200000, true, true},
+	{"o3", 200000, true, true},
+	{"o4-mini", 200000, true, true},
+}
+
+// ListModels 调用 /v1/models；兼容后端（vLLM 等）同样适用
+func (o *OpenAI) ListModels(ctx context.Context) ([]types.ModelInfo, error) {
+	resp, err := o.client.ListModels(ctx)
+	if err != nil {
+		return nil, o.mapError(err, nil)
+	}
+	out := make([]types.ModelInfo, 0, len(resp.Models))
+	for _, m := range resp.Models {
+		out = append(out, modelInfo(m.ID))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+// HealthCheck 复用 /v1/models，不产生费用
+func (o *OpenAI) HealthCheck(ctx context.Context) error {
+	_, err := o.client.ListModels(ctx)
+	return o.mapError(err, nil)
+}
+
+func modelInfo(id string) types.ModelInfo {
+	if strings.Contains(id, "embedding") {
+		return types.ModelInfo{ID: id, Embeddings: true, ContextWindow: 8191}
+	}
+	info := types.ModelInfo{ID: id, Streaming: true}
+	for _, k := range knownModels {
+		if strings.HasPrefix(id, k.prefix) {
+			info.ContextWindow = k.contextWindow
+			info.Tools = k.tools
+			info.Vision = k.vision
+			break
+		}
+	}
+	return info
+}
@@ -2,36 +2,57 @@ package openai
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"io"
+	"math"
+	"net/http"
 	"os"
+	"strings"
 
 	openai "github.com/sashabaranov/go-openai"
 	"gollm-mini/internal/provider"
+	"gollm-mini/internal/tokenizer"
 	"gollm-mini/internal/types"
 )
 
 type OpenAI struct {
 	client *openai.Client
 	model  string
-}
+	name   string // 注册名，用于错误信息（兼容后端各有自己的名字）
 
-func (o *OpenAI) SetModel(m string) { o.model = m }
+	noSchema bool // 兼容后端声明不支持 json_schema
+}
 
 func New(model string) *OpenAI {
+	cc := openai.DefaultConfig(os.Getenv("OPENAI_API_KEY"))
+	cc.HTTPClient = &http.Client{Transport: captureTransport{base: http.DefaultTransport}}
 	return &OpenAI{
-		client: openai.NewClient(os.Getenv("OPENAI_API_KEY")),
+		client: openai.NewClientWithConfig(cc),
 		model:  model,
+		name:   "openai",
 	}
 }
 
+// Model 实际使用的模型名
+func (o *OpenAI) Model() string { return o.model }
+
+// SupportsJSONSchema 官方 API 与大多数兼容后端（vLLM / LM Studio / llama.cpp）支持 json_schema
+func (o *OpenAI) SupportsJSONSchema() bool { return !o.noSchema }
+
 // ----------- 非流式 --------------------------------------------------------
 
-func (o *OpenAI) Generate(ctx context.Context, msgs []types.Message) (string, types.Usage, error) {
-	req := o.buildRequest(msgs, false)
+func (o *OpenAI) Generate(ctx context.Context, msgs []types.Message, opts types.GenerateOptions) (string, types.Usage, error) {
+	req := o.buildRequest(msgs, opts, false)
 
+	ctx, hdr := withHeaders(ctx)
 	resp, err := o.client.CreateChatCompletion(ctx, *req)
 	if err != nil {
-		return "", types.Usage{}, err
+		return "", types.Usage{}, o.mapError(err, hdr)
+	}
+
+	if len(resp.Choices) == 0 {
+		return "", types.Usage{}, o.emptyChoices()
 	}
 
 	u := types.Usage{
@@ -46,18 +67,24 @@ func (o *OpenAI) Generate(ctx context.Context, msgs []types.Message) (string, ty
 func (o *OpenAI) Stream(
 	ctx context.Context,
 	msgs []types.Message,
+	opts types.GenerateOptions,
 	cb func(types.Chunk),
 ) (types.Usage, error) {
 
-	req := o.buildRequest(msgs, true)
+	req := o.buildRequest(msgs, opts, true)
 
+	ctx, hdr := withHeaders(ctx)
 	stream, err := o.client.CreateChatCompletionStream(ctx, *req)
 	if err != nil {
-		return types.Usage{}, err
+		return types.Usage{}, o.mapError(err, hdr)
 	}
 	defer stream.Close()
 
-	var usage types.Usage
+	var (
+		usage    types.Usage
+		full     strings.Builder
+		reported bool // 服务端是否返回了 usage
+	)
 
 	for {
 		resp, err := stream.Recv()
@@ -65,7 +92,16 @@ func (o *OpenAI) Stream(
 			if err == io.EOF { // 流结束
 				break
 			}
-			return usage, err
+			return usage, o.mapError(err, hdr)
+		}
+
+		// include_usage：最后一块 choices 为空，只带整次请求的 usage
+		if resp.Usage != nil {
+			usage = types.Usage{
+				PromptTokens:     resp.Usage.PromptTokens,
+				CompletionTokens: resp.Usage.CompletionTokens,
+			}
+			reported = true
 		}
 
 		if len(resp.Choices) == 0 {
@@ -77,32 +113,186 @@ func (o *OpenAI) Stream(
 			continue
 		}
 
-		cb(types.Chunk{Content: delta, Delta: 1}) // 每块按 1 token 计
-		usage.CompletionTokens++
+		cb(types.Chunk{Content: delta, Delta: 1})
+		full.WriteString(delta)
 	}
 
-	// PromptTokens 暂无法从 SDK 拿到，可在此处自行调用 tiktoken 计算
-	usage.PromptTokens = 0
+	// 兼容后端可能忽略 stream_options，退回本地 tokenizer 计算
+	if !reported {
+		tok := tokenizer.ForModel("openai", o.model)
+		usage.PromptTokens = tokenizer.CountMessages(tok, msgs)
+		usage.CompletionTokens = tok.Count(full.String())
+	}
 	return usage, nil
 }
 
+// ----------- 工具调用（function calling） -----------------------------------
+
+func (o *OpenAI) GenerateWithTools(
+	ctx context.Context,
+	msgs []types.Message,
+	tools []types.Tool,
+	opts types.GenerateOptions,
+) (types.Message, types.Usage, error) {
+
+	req := o.buildRequest(msgs, opts, false)
+	for _, t := range tools {
+		fd := &openai.FunctionDefinition{Name: t.Name, Description: t.Description}
+		if len(t.Parameters) > 0 {
+			fd.Parameters = t.Parameters
+		}
+		req.Tools = append(req.Tools, openai.Tool{Type: openai.ToolTypeFunction, Function: fd})
+	}
+
+	ctx, hdr := withHeaders(ctx)
+	resp, err := o.client.CreateChatCompletion(ctx, *req)
+	if err != nil {
+		return types.Message{}, types.Usage{}, o.mapError(err, hdr)
+	}
+
+	if len(resp.Choices) == 0 {
+		return types.Message{}, types.Usage{}, o.emptyChoices()
+	}
+
+	u := types.Usage{
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+	}
+	cm := resp.Choices[0].Message
+	out := types.Message{Role: types.RoleAssistant, Content: cm.Content}
+	for _, tc := range cm.ToolCalls {
+		args, err := toolArguments(tc.Function.Arguments)
+		if err != nil {
+			return types.Message{}, u, fmt.Errorf("%s tool call %s: %w", o.name, tc.Function.Name, err)
+		}
+		out.ToolCalls = append(out.ToolCalls, types.ToolCall{
+			ID:        tc.ID,
+			Name:      tc.Function.Name,
+			Arguments: args,
+		})
+	}
+	return out, u, nil
+}
+
+// toolArguments 模型给出的参数串：空串视为 {}，非法 JSON 直接报错（否则下游 json.Marshal 会失败）
+func toolArguments(raw string) (json.RawMessage, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return json.RawMessage("{}"), nil
+	}
+	if !json.Valid([]byte(raw)) {
+		return nil, fmt.Errorf("malformed arguments %q", raw)
+	}
+	return json.RawMessage(raw), nil
+}
+
+// ----------- Embedding -----------------------------------------------------
+
+func (o *OpenAI) Embed(ctx context.Context, inputs []string) ([][]float32, types.Usage, error) {
+	ctx, hdr := withHeaders(ctx)
+	resp, err := o.client.CreateEmbeddings(ctx, openai.EmbeddingRequestStrings{
+		Input: inputs,
+		Model: openai.EmbeddingModel(o.model),
+	})
+	if err != nil {
+		return nil, types.Usage{}, o.mapError(err, hdr)
+	}
+
+	// 返回顺序以 index 为准
+	out := make([][]float32, len(inputs))
+	for _, d := range resp.Data {
+		if d.Index >= 0 && d.Index < len(out) {
+			out[d.Index] = d.Embedding
+		}
+	}
+	return out, types.Usage{PromptTokens: resp.Usage.PromptTokens}, nil
+}
+
 // ----------- 工具 & 注册 ----------------------------------------------------
 
-func (o *OpenAI) buildRequest(msgs []types.Message, stream bool) *openai.ChatCompletionRequest {
+func (o *OpenAI) buildRequest(msgs []types.Message, opts types.GenerateOptions, stream bool) *openai.ChatCompletionRequest {
 	cm := make([]openai.ChatCompletionMessage, len(msgs))
 	for i, m := range msgs {
 		cm[i] = openai.ChatCompletionMessage{
-			Role:    string(m.Role),
-			Content: m.Content,
+			Role:       string(m.Role),
+			Content:    m.Content,
+			ToolCallID: m.ToolCallID, // tool 消息只认 tool_call_id，不带 name
+		}
+		if len(m.Parts) > 0 {
+			// Content 与 MultiContent 互斥
+			cm[i].Content = ""
+			cm[i].MultiContent = toMultiContent(m)
 		}
+		for _, tc := range m.ToolCalls {
+			cm[i].ToolCalls = append(cm[i].ToolCalls, openai.ToolCall{
+				ID:   tc.ID,
+				Type: openai.ToolTypeFunction,
+				Function: openai.FunctionCall{
+					Name:      tc.Name,
+					Arguments: string(tc.Arguments),
+				},
+			})
+		}
+	}
+	req := &openai.ChatCompletionRequest{
+		Model:     o.model,
+		Messages:  cm,
+		Stream:    stream,
+		MaxTokens: opts.MaxTokens,
+		Stop:      opts.Stop,
+		Seed:      opts.Seed,
+	}
+	if stream {
+		req.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+	}
+	if len(opts.JSONSchema) > 0 {
+		req.ResponseFormat = &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+				Name:   "response",
+				Schema: opts.JSONSchema,
+				Strict: strictCompatible(opts.JSONSchema),
+			},
+		}
+	}
+	if opts.Temperature != nil {
+		req.Temperature = nonZero(*opts.Temperature)
+	}
+	if opts.TopP != nil {
+		req.TopP = nonZero(*opts.TopP)
 	}
-	return &openai.ChatCompletionRequest{
-		Model:    o.model,
-		Messages: cm,
-		Stream:   stream,
+	return req
+}
+
+// nonZero SDK 字段带 omitempty，显式的 0 会被丢掉；用最小正数代替以保留“确定性输出”的语义
+func nonZero(v float64) float32 {
+	if v == 0 {
+		return math.SmallestNonzeroFloat32
+	}
+	return float32(v)
+}
+
+// toMultiContent 把文本 + 图片片段映射为 OpenAI 的 content 数组
+func toMultiContent(m types.Message) []openai.ChatMessagePart {
+	var parts []openai.ChatMessagePart
+	if m.Content != "" {
+		parts = append(parts, openai.ChatMessagePart{Type: openai.ChatMessagePartTypeText, Text: m.Content})
+	}
+	for _, p := range m.Parts {
+		switch p.Type {
+		case types.PartText:
+			parts = append(parts, openai.ChatMessagePart{Type: openai.ChatMessagePartTypeText, Text: p.Text})
+		case types.PartImage:
+			parts = append(parts, openai.ChatMessagePart{
+				Type:     openai.ChatMessagePartTypeImageURL,
+				ImageURL: &openai.ChatMessageImageURL{URL: p.DataURL(), Detail: openai.ImageURLDetailAuto},
+			})
+		}
 	}
+	return parts
 }
 
 func init() {
-	provider.Register("openai", New("gpt-3.5-turbo"))
+	provider.Register("openai", provider.Simple("gpt-3.5-turbo", New))
+	registerCompatFromEnv()
 }
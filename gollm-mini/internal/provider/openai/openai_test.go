@@ -0,0 +1,223 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+	"gollm-mini/internal/provider"
+	"gollm-mini/internal/types"
+)
+
+// newReplyServer 对所有 chat 请求返回固定响应体
+func newReplyServer(t *testing.T, body string) *OpenAI {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return NewFromConfig(Config{Name: "stub", BaseURL: srv.URL + "/v1", Model: "m", APIKey: "k"})
+}
+
+func toolReply(args string) string {
+	return `{"choices":[{"index":0,"message":{"role":"assistant","tool_calls":[
+		{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":` + args + `}}]}}],
+		"usage":{"prompt_tokens":3,"completion_tokens":4}}`
+}
+
+func TestToolArguments(t *testing.T) {
+	cases := []struct {
+		name, args, want string
+		wantErr          bool
+	}{
+		{"object", `"{\"city\":\"Paris\"}"`, `{"city":"Paris"}`, false},
+		{"empty", `""`, `{}`, false},
+		{"blank", `"  "`, `{}`, false},
+		{"malformed", `"{\"city\":"`, ``, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			o := newReplyServer(t, toolReply(tc.args))
+			msg, _, err := o.GenerateWithTools(context.Background(), hi, nil, types.GenerateOptions{})
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("want error, got %+v", msg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(msg.ToolCalls) != 1 || string(msg.ToolCalls[0].Arguments) != tc.want {
+				t.Errorf("tool calls = %+v, want arguments %s", msg.ToolCalls, tc.want)
+			}
+		})
+	}
+}
+
+func TestEmptyChoices(t *testing.T) {
+	o := newReplyServer(t, `{"choices":[],"usage":{"prompt_tokens":3}}`)
+
+	_, _, err := o.Generate(context.Background(), hi, types.GenerateOptions{})
+	if !errors.Is(err, provider.ErrOverloaded) {
+		t.Errorf("Generate err = %v, want ErrOverloaded", err)
+	}
+	_, _, err = o.GenerateWithTools(context.Background(), hi, nil, types.GenerateOptions{})
+	if !errors.Is(err, provider.ErrOverloaded) {
+		t.Errorf("GenerateWithTools err = %v, want ErrOverloaded", err)
+	}
+}
+
+func TestToMultiContent(t *testing.T) {
+	m := types.Message{
+		Role:    types.RoleUser,
+		Content: "compare",
+		Parts: []types.ContentPart{
+			{Type: types.PartText, Text: "these two"},
+			{Type: types.PartImage, ImageURL: "https://example.com/a.png"},
+			{Type: types.PartImage, ImageData: "iVBORw0KGgpmYWtl", MIMEType: "image/png"},
+		},
+	}
+	parts := toMultiContent(m)
+	if len(parts) != 4 {
+		t.Fatalf("parts = %+v", parts)
+	}
+	if parts[0].Text != "compare" || parts[1].Text != "these two" {
+		t.Errorf("text parts = %+v %+v", parts[0], parts[1])
+	}
+	if parts[2].Type != openai.ChatMessagePartTypeImageURL || parts[2].ImageURL.URL != "https://example.com/a.png" {
+		t.Errorf("url part = %+v", parts[2].ImageURL)
+	}
+	if parts[3].ImageURL.URL != "data:image/png;base64,iVBORw0KGgpmYWtl" {
+		t.Errorf("base64 part = %+v", parts[3].ImageURL)
+	}
+
+	// 有图片时只能用 MultiContent
+	req := newReplyServer(t, `{}`).buildRequest([]types.Message{m}, types.GenerateOptions{}, false)
+	if req.Messages[0].Content != "" || len(req.Messages[0].MultiContent) != 4 {
+		t.Errorf("request message = %+v", req.Messages[0])
+	}
+}
+
+func TestEmbed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" {
+			http.NotFound(w, r)
+			return
+		}
+		var req struct {
+			Model string   `json:"model"`
+			Input []string `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Model != "text-embedding-3-small" || len(req.Input) != 2 {
+			t.Errorf("request = %+v", req)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		// 故意乱序：结果应按 index 排列
+		_, _ = w.Write([]byte(`{"data":[{"index":1,"embedding":[0.3,0.4]},{"index":0,"embedding":[0.1,0.2]}],
+			"usage":{"prompt_tokens":5,"total_tokens":5}}`))
+	}))
+	t.Cleanup(srv.Close)
+	o := NewFromConfig(Config{Name: "stub", BaseURL: srv.URL + "/v1", Model: "text-embedding-3-small", APIKey: "k"})
+
+	vecs, u, err := o.Embed(context.Background(), []string{"hello", "world"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vecs) != 2 || vecs[0][0] != 0.1 || vecs[1][0] != 0.3 {
+		t.Errorf("vecs = %v", vecs)
+	}
+	if u.PromptTokens != 5 {
+		t.Errorf("usage = %+v", u)
+	}
+}
+
+// newStreamServer 以 SSE 返回给定的 chunk，最后是 [DONE]；同时检查请求带了 include_usage
+func newStreamServer(t *testing.T, chunks ...string) *OpenAI {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if !req.Stream || req.StreamOptions == nil || !req.StreamOptions.IncludeUsage {
+			t.Errorf("stream = %v, stream_options = %+v", req.Stream, req.StreamOptions)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, c := range append(chunks, "[DONE]") {
+			_, _ = w.Write([]byte("data: " + c + "\n\n"))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return NewFromConfig(Config{Name: "stub", BaseURL: srv.URL + "/v1", Model: "gpt-4o-mini", APIKey: "k"})
+}
+
+func TestStreamUsage(t *testing.T) {
+	deltas := []string{
+		`{"id":"1","choices":[{"index":0,"delta":{"role":"assistant","content":""}}]}`,
+		`{"id":"1","choices":[{"index":0,"delta":{"content":"Hel"}}]}`,
+		`{"id":"1","choices":[{"index":0,"delta":{"content":"lo"},"finish_reason":"stop"}]}`,
+	}
+	cases := []struct {
+		name   string
+		chunks []string
+		want   *types.Usage // nil：服务端没给 usage，本地估算
+	}{
+		{"reported", append(deltas, `{"id":"1","choices":[],"usage":{"prompt_tokens":9,"completion_tokens":2,"total_tokens":11}}`),
+			&types.Usage{PromptTokens: 9, CompletionTokens: 2}},
+		{"fallback", deltas, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			o := newStreamServer(t, tc.chunks...)
+			var got []string
+			u, err := o.Stream(context.Background(), hi, types.GenerateOptions{}, func(ch types.Chunk) {
+				got = append(got, ch.Content)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 空 delta 与只带 usage 的最后一块都不产生 chunk
+			if strings.Join(got, "|") != "Hel|lo" {
+				t.Errorf("chunks = %q", got)
+			}
+			if tc.want != nil {
+				if u != *tc.want {
+					t.Errorf("usage = %+v, want %+v", u, *tc.want)
+				}
+				return
+			}
+			if u.PromptTokens == 0 || u.CompletionTokens == 0 {
+				t.Errorf("usage = %+v, want a local tokenizer estimate", u)
+			}
+		})
+	}
+}
+
+func TestModelInfo(t *testing.T) {
+	cases := []struct {
+		id            string
+		tools, vision bool
+	}{
+		{"gpt-4o-mini", true, true},
+		{"gpt-4", true, false},
+		{"o1", true, true},
+		{"o1-2024-12-17", true, true},
+		{"o1-mini", false, false},
+		{"o1-mini-2024-09-12", false, false},
+		{"o1-preview", false, false},
+		{"my-finetune", false, false},
+	}
+	for _, tc := range cases {
+		info := modelInfo(tc.id)
+		if info.Tools != tc.tools || info.Vision != tc.vision {
+			t.Errorf("%s: tools %v vision %v, want %v %v", tc.id, info.Tools, info.Vision, tc.tools, tc.vision)
+		}
+	}
+}
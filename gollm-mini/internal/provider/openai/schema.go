@@ -0,0 +1,67 @@
+package openai
+
+import "encoding/json"
+
+// strictCompatible 判断 schema 是否满足 OpenAI strict 模式的限制：
+// 每个 object 都声明 additionalProperties=false 且 required 覆盖全部属性，不使用 oneOf / allOf。
+// 不满足时以非 strict 方式发送 json_schema，由调用方做最终校验。
+func strictCompatible(raw json.RawMessage) bool {
+	var s any
+	if json.Unmarshal(raw, &s) != nil {
+		return false
+	}
+	return strictNode(s)
+}
+
+func strictNode(v any) bool {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return true
+	}
+	if _, ok := m["oneOf"]; ok {
+		return false
+	}
+	if _, ok := m["allOf"]; ok {
+		return false
+	}
+
+	if props, ok := m["properties"].(map[string]any); ok || m["type"] == "object" {
+		if ap, ok := m["additionalProperties"].(bool); !ok || ap {
+			return false
+		}
+		required := map[string]bool{}
+		if req, ok := m["required"].([]any); ok {
+			for _, r := range req {
+				if s, ok := r.(string); ok {
+					required[s] = true
+				}
+			}
+		}
+		for name, p := range props {
+			if !required[name] || !strictNode(p) {
+				return false
+			}
+		}
+	}
+
+	if !strictNode(m["items"]) {
+		return false
+	}
+	if list, ok := m["anyOf"].([]any); ok {
+		for _, s := range list {
+			if !strictNode(s) {
+				return false
+			}
+		}
+	}
+	for _, key := range []string{"$defs", "definitions"} {
+		if defs, ok := m[key].(map[string]any); ok {
+			for _, d := range defs {
+				if !strictNode(d) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
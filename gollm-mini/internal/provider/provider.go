@@ -2,18 +2,94 @@ package provider
 
 import (
 	"context"
+	"log"
+	"time"
 
 	"gollm-mini/internal/types"
 )
 
 type Provider interface {
 	//Generate 核心能力：把若干消息发给模型，返回一段文本
-	Generate(ctx context.Context, messages []types.Message) (text string, usage types.Usage, err error)
+	Generate(ctx context.Context, messages []types.Message, opts types.GenerateOptions) (text string, usage types.Usage, err error)
 
 	// Stream 可选实现；未实现时由 core 层降级到 Generate
-	Stream(ctx context.Context, messages []types.Message, cb func(types.Chunk)) (usage types.Usage, err error)
+	Stream(ctx context.Context, messages []types.Message, opts types.GenerateOptions, cb func(types.Chunk)) (usage types.Usage, err error)
 }
 
-type ModelSetter interface {
-	SetModel(string)
+// ToolCaller 可选实现：携带工具定义调用模型，返回的 assistant 消息可能包含 ToolCalls
+type ToolCaller interface {
+	GenerateWithTools(ctx context.Context, messages []types.Message, tools []types.Tool, opts types.GenerateOptions) (types.Message, types.Usage, error)
+}
+
+// Embedder 可选实现：把一批文本转换为向量，顺序与 inputs 一致
+type Embedder interface {
+	Embed(ctx context.Context, inputs []string) ([][]float32, types.Usage, error)
+}
+
+// SchemaConstrained 可选实现：能按 GenerateOptions.JSONSchema 做原生约束解码
+// （OpenAI json_schema / Ollama format / TGI grammar）；返回 false 时 core 退回提示词约束
+type SchemaConstrained interface {
+	SupportsJSONSchema() bool
+}
+
+// Modeler 可选实现：返回实例实际使用的模型名（工厂按默认模型补全之后的结果）
+type Modeler interface {
+	Model() string
+}
+
+// ModelLister 可选实现：列出后端当前可用的模型
+type ModelLister interface {
+	ListModels(ctx context.Context) ([]types.ModelInfo, error)
+}
+
+// HealthChecker 可选实现：用尽量廉价的调用（不生成 token）探测后端是否可用
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+// ModelManager 可选实现：在后端拉取 / 删除 / 查看模型，控制模型常驻内存的时间
+type ModelManager interface {
+	// PullModel 拉取模型；progress 可为 nil
+	PullModel(ctx context.Context, model string, progress func(types.PullProgress)) error
+	DeleteModel(ctx context.Context, model string) error
+	ShowModel(ctx context.Context, model string) (types.ModelDetails, error)
+	// KeepAlive 加载模型并保持 d；d == 0 立即卸载，d < 0 永久常驻
+	KeepAlive(ctx context.Context, model string, d time.Duration) error
+}
+
+// Wrapper 可选实现：包装另一个 Provider 的中间层（如录制回放），Unwrap 返回被包装者（可为 nil）
+type Wrapper interface {
+	Unwrap() Provider
+}
+
+// As 查询 p 的可选能力 T（ToolCaller、ModelLister 等），所有能力判断都应经由它：
+//   - 包装层自己实现 T（需要拦截调用，如录制）时，被包装者也实现 T 才算具备，避免“声称”没有的能力；
+//   - 包装层未实现 T 时，返回被包装者的实现，避免把能力“藏起来”；
+//   - 被包装者为 nil（只能回放）时以包装层自身为准。
+func As[T any](p Provider) (T, bool) {
+	t, ok := p.(T)
+	w, wrapped := p.(Wrapper)
+	if !wrapped {
+		return t, ok
+	}
+	inner := w.Unwrap()
+	if inner == nil {
+		return t, ok
+	}
+	it, iok := As[T](inner)
+	switch {
+	case !iok:
+		var zero T
+		return zero, false
+	case ok:
+		return t, true
+	}
+	return it, true
+}
+
+// WarnUnsupported 记录被某 Provider 忽略的生成参数
+func WarnUnsupported(provider string, options ...string) {
+	for _, o := range options {
+		log.Printf("[WARN] provider %s ignores option %s", provider, o)
+	}
 }
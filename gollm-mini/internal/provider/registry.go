@@ -1,17 +1,68 @@
 package provider
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
 
-var registry = map[string]Provider{}
+// Factory 为指定模型创建一个全新的 Provider 实例；model 为空时使用默认模型。
+// 每次调用都应返回独立对象，调用方之间不共享可变状态。
+type Factory func(model string) (Provider, error)
 
-func Register(name string, p Provider) {
-	registry[name] = p
+// ErrNotRegistered 请求的 Provider 名未注册
+var ErrNotRegistered = errors.New("not registered")
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]Factory{}
+)
+
+// Register 注册（或覆盖）一个 Provider 工厂
+func Register(name string, f Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	registry[name] = f
 }
 
-func Get(name string) (Provider, error) {
-	p, ok := registry[name]
+// Get 通过工厂创建指定模型的 Provider 实例
+func Get(name, model string) (Provider, error) {
+	mu.RLock()
+	f, ok := registry[name]
+	mu.RUnlock()
 	if !ok {
-		return nil, fmt.Errorf("provider %s not registered", name)
+		return nil, fmt.Errorf("provider %s %w", name, ErrNotRegistered)
+	}
+	return f(model)
+}
+
+// Lookup 返回已注册的工厂，供包装（如 replay）使用
+func Lookup(name string) (Factory, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	f, ok := registry[name]
+	return f, ok
+}
+
+// Names 返回已注册的 Provider 名（字典序）
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for n := range registry {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Simple 把“构造函数 + 默认模型”包装成 Factory
+func Simple[T Provider](defaultModel string, ctor func(model string) T) Factory {
+	return func(model string) (Provider, error) {
+		if model == "" {
+			model = defaultModel
+		}
+		return ctor(model), nil
 	}
-	return p, nil
 }
@@ -0,0 +1,62 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"gollm-mini/internal/types"
+)
+
+type stub struct{ model string }
+
+func (s *stub) Generate(context.Context, []types.Message, types.GenerateOptions) (string, types.Usage, error) {
+	return s.model, types.Usage{}, nil
+}
+
+func (s *stub) Stream(context.Context, []types.Message, types.GenerateOptions, func(types.Chunk)) (types.Usage, error) {
+	return types.Usage{}, nil
+}
+
+func TestRegistryConcurrentAccess(t *testing.T) {
+	factory := Simple("default", func(m string) *stub { return &stub{model: m} })
+
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			Register(fmt.Sprintf("stub-%d", i%4), factory)
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			_, _ = Get(fmt.Sprintf("stub-%d", i%4), "m")
+			_ = Names()
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestGetReturnsFreshInstances(t *testing.T) {
+	Register("fresh", Simple("default", func(m string) *stub { return &stub{model: m} }))
+
+	a, err := Get("fresh", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := Get("fresh", "b")
+	d, _ := Get("fresh", "")
+
+	if a == b {
+		t.Fatal("Get returned a shared instance")
+	}
+	for p, want := range map[Provider]string{a: "a", b: "b", d: "default"} {
+		if got := p.(*stub).model; got != want {
+			t.Errorf("model = %q, want %q", got, want)
+		}
+	}
+	if _, err := Get("missing", ""); err == nil {
+		t.Error("expected error for unregistered provider")
+	}
+}
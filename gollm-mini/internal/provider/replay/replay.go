@@ -0,0 +1,341 @@
+package replay
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gollm-mini/internal/provider"
+	"gollm-mini/internal/types"
+)
+
+// Mode 录制 / 回放模式
+type Mode string
+
+const (
+	ModeRecord Mode = "record" // 调用真实后端，并把结果写入 cassette
+	ModeReplay Mode = "replay" // 只读 cassette，不访问网络
+	ModeAuto   Mode = "auto"   // 有 cassette 则回放，否则录制
+)
+
+// ErrNoCassette 回放模式下找不到对应请求的 cassette
+var ErrNoCassette = errors.New("no cassette recorded for request")
+
+// ParseMode 解析模式字符串
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(strings.ToLower(strings.TrimSpace(s))); m {
+	case ModeRecord, ModeReplay, ModeAuto:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown replay mode %q (want record / replay / auto)", s)
+}
+
+/* ---------- cassette ---------- */
+
+// Request 参与哈希的规范化请求
+type Request struct {
+	Provider string                `json:"provider"`
+	Model    string                `json:"model"`
+	Endpoint string                `json:"endpoint"` // generate / stream / tools / embed
+	Messages []types.Message       `json:"messages,omitempty"`
+	Tools    []types.Tool          `json:"tools,omitempty"`
+	Inputs   []string              `json:"inputs,omitempty"`
+	Options  types.GenerateOptions `json:"options"`
+	Schema   json.RawMessage       `json:"schema,omitempty"` // Options.JSONSchema（原生约束解码）
+}
+
+// Failure 录制的错误，回放时按状态码重新分类
+type Failure struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+// Cassette 一次请求的完整录像
+type Cassette struct {
+	Key        string         `json:"key"`
+	Request    Request        `json:"request"`
+	Text       string         `json:"text,omitempty"`
+	Chunks     []types.Chunk  `json:"chunks,omitempty"`
+	Message    *types.Message `json:"message,omitempty"`
+	Embeddings [][]float32    `json:"embeddings,omitempty"`
+	Usage      types.Usage    `json:"usage"`
+	Error      *Failure       `json:"error,omitempty"` // 录制时不写入，可手工添加以模拟后端故障
+}
+
+// Key 规范化请求并计算哈希：忽略首尾空白与 JSON 字段顺序
+func Key(r Request) string {
+	msgs := make([]types.Message, len(r.Messages))
+	for i, m := range r.Messages {
+		m.Content = strings.TrimSpace(m.Content)
+		msgs[i] = m
+	}
+	r.Messages = msgs
+	tools := make([]types.Tool, len(r.Tools))
+	for i, t := range r.Tools {
+		t.Parameters = compact(t.Parameters)
+		tools[i] = t
+	}
+	r.Tools = tools
+	r.Schema = compact(r.Schema)
+	b, _ := json.Marshal(r)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8])
+}
+
+func compact(raw json.RawMessage) json.RawMessage {
+	if len(raw) == 0 {
+		return raw
+	}
+	var v interface{}
+	if json.Unmarshal(raw, &v) != nil {
+		return raw
+	}
+	b, _ := json.Marshal(v) // map 按 key 排序输出
+	return b
+}
+
+/* ---------- provider ---------- */
+
+// Replay 包装任意 Provider 的录制 / 回放层。
+// Generate / Stream / GenerateWithTools / Embed 会被录制；能力判断经 provider.As 以被包装者为准，
+// 其余可选接口（ModelLister、HealthChecker、ModelManager）通过 Unwrap 直接转给被包装者，不录制。
+type Replay struct {
+	name  string
+	model string
+	dir   string
+	mode  Mode
+	inner provider.Provider // 回放模式下只用于查询能力（SupportsJSONSchema），不会被调用；可为 nil
+}
+
+// Factory 包装 inner 工厂；inner 为 nil 时只能回放
+func Factory(name, dir string, mode Mode, inner provider.Factory) provider.Factory {
+	return func(model string) (provider.Provider, error) {
+		r := &Replay{name: name, model: model, dir: dir, mode: mode}
+		if inner != nil {
+			p, err := inner(model)
+			if err != nil {
+				return nil, err
+			}
+			r.inner = p
+		}
+		return r, nil
+	}
+}
+
+// Install 把已注册的 Provider 替换为录制 / 回放包装；names 为空时包装全部
+func Install(dir string, mode Mode, names ...string) error {
+	if len(names) == 0 {
+		names = provider.Names()
+	}
+	for _, n := range names {
+		f, ok := provider.Lookup(n)
+		if !ok {
+			return fmt.Errorf("provider %s %w", n, provider.ErrNotRegistered)
+		}
+		provider.Register(n, Factory(n, dir, mode, f))
+	}
+	return nil
+}
+
+// FromEnv 按 GOLLM_REPLAY（record / replay / auto）与 GOLLM_CASSETTES（默认 testdata/cassettes）
+// 包装全部已注册 Provider；未设置 GOLLM_REPLAY 时不做任何事
+func FromEnv() error {
+	v := os.Getenv("GOLLM_REPLAY")
+	if v == "" {
+		return nil
+	}
+	mode, err := ParseMode(v)
+	if err != nil {
+		return err
+	}
+	dir := os.Getenv("GOLLM_CASSETTES")
+	if dir == "" {
+		dir = "testdata/cassettes"
+	}
+	log.Printf("[REPLAY] mode=%s dir=%s", mode, dir)
+	return Install(dir, mode)
+}
+
+func (r *Replay) Generate(ctx context.Context, msgs []types.Message, opts types.GenerateOptions) (string, types.Usage, error) {
+	req := r.request("generate", opts)
+	req.Messages = msgs
+	c, err := r.play(req, func(c *Cassette) error {
+		var e error
+		c.Text, c.Usage, e = r.inner.Generate(ctx, msgs, opts)
+		return e
+	})
+	if err != nil {
+		return "", types.Usage{}, err
+	}
+	return c.Text, c.Usage, nil
+}
+
+func (r *Replay) Stream(ctx context.Context, msgs []types.Message, opts types.GenerateOptions, cb func(types.Chunk)) (types.Usage, error) {
+	req := r.request("stream", opts)
+	req.Messages = msgs
+	recorded := false
+	c, err := r.play(req, func(c *Cassette) error {
+		recorded = true
+		var e error
+		c.Usage, e = r.inner.Stream(ctx, msgs, opts, func(ch types.Chunk) {
+			c.Chunks = append(c.Chunks, ch)
+			cb(ch)
+		})
+		return e
+	})
+	if err != nil {
+		return types.Usage{}, err
+	}
+	if !recorded {
+		for _, ch := range c.Chunks {
+			if ctx.Err() != nil {
+				return types.Usage{}, ctx.Err()
+			}
+			cb(ch)
+		}
+	}
+	return c.Usage, nil
+}
+
+func (r *Replay) GenerateWithTools(ctx context.Context, msgs []types.Message, tools []types.Tool, opts types.GenerateOptions) (types.Message, types.Usage, error) {
+	req := r.request("tools", opts)
+	req.Messages, req.Tools = msgs, tools
+	c, err := r.play(req, func(c *Cassette) error {
+		tc, ok := provider.As[provider.ToolCaller](r.inner)
+		if !ok {
+			return fmt.Errorf("provider %s does not support tool calling", r.name)
+		}
+		msg, u, e := tc.GenerateWithTools(ctx, msgs, tools, opts)
+		c.Message, c.Usage = &msg, u
+		return e
+	})
+	if err != nil {
+		return types.Message{}, types.Usage{}, err
+	}
+	if c.Message == nil {
+		return types.Message{Role: types.RoleAssistant, Content: c.Text}, c.Usage, nil
+	}
+	return *c.Message, c.Usage, nil
+}
+
+func (r *Replay) Embed(ctx context.Context, inputs []string) ([][]float32, types.Usage, error) {
+	req := r.request("embed", types.GenerateOptions{})
+	req.Inputs = inputs
+	c, err := r.play(req, func(c *Cassette) error {
+		em, ok := provider.As[provider.Embedder](r.inner)
+		if !ok {
+			return fmt.Errorf("provider %s does not support embeddings", r.name)
+		}
+		var e error
+		c.Embeddings, c.Usage, e = em.Embed(ctx, inputs)
+		return e
+	})
+	if err != nil {
+		return nil, types.Usage{}, err
+	}
+	return c.Embeddings, c.Usage, nil
+}
+
+// Unwrap 返回被包装的 Provider；只能回放时为 nil
+func (r *Replay) Unwrap() provider.Provider { return r.inner }
+
+// Model 被包装者补全默认模型后的名字；只能回放时为请求的模型名
+func (r *Replay) Model() string {
+	if m, ok := r.inner.(provider.Modeler); ok {
+		return m.Model()
+	}
+	return r.model
+}
+
+// SupportsJSONSchema 与被包装者一致，保证录制与回放走同一条约束路径（请求哈希相同）
+func (r *Replay) SupportsJSONSchema() bool {
+	sc, ok := r.inner.(provider.SchemaConstrained)
+	return ok && sc.SupportsJSONSchema()
+}
+
+func (r *Replay) request(endpoint string, opts types.GenerateOptions) Request {
+	return Request{Provider: r.name, Model: r.model, Endpoint: endpoint, Options: opts, Schema: opts.JSONSchema}
+}
+
+// play 按模式回放或录制；record 只在真实调用成功时写入 cassette
+func (r *Replay) play(req Request, record func(*Cassette) error) (*Cassette, error) {
+	key := Key(req)
+	path := r.path(key)
+
+	if r.mode != ModeRecord {
+		c, err := load(path)
+		switch {
+		case err == nil:
+			if c.Error != nil {
+				return nil, provider.FromStatus(r.name, c.Error.Status, nil, c.Error.Message, nil)
+			}
+			return c, nil
+		case !errors.Is(err, os.ErrNotExist):
+			return nil, err
+		case r.mode == ModeReplay || r.inner == nil:
+			// 归为请求错误：不重试、不计入熔断统计
+			return nil, &provider.Error{Kind: provider.ErrInvalidRequest, Provider: r.name,
+				Err: fmt.Errorf("%w: %s (%s)", ErrNoCassette, req.Endpoint, path)}
+		}
+	}
+	if r.inner == nil {
+		return nil, fmt.Errorf("replay %s: no provider to record from", r.name)
+	}
+
+	c := &Cassette{Key: key, Request: req}
+	if err := record(c); err != nil {
+		return nil, err
+	}
+	if err := save(path, c); err != nil {
+		log.Printf("[WARN] replay: save cassette %s: %v", path, err)
+	}
+	return c, nil
+}
+
+func (r *Replay) path(key string) string {
+	return filepath.Join(r.dir, strings.ReplaceAll(r.name, "/", "_"), key+".json")
+}
+
+func load(path string) (*Cassette, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Cassette
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("cassette %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// save 先写临时文件再 rename，避免并发录制留下半截文件
+func save(path string, c *Cassette) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".cassette-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(append(b, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
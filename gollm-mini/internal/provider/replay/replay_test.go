@@ -0,0 +1,196 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gollm-mini/internal/provider"
+	"gollm-mini/internal/types"
+)
+
+// scripted 按固定回答响应并统计调用次数
+type scripted struct {
+	calls int
+	reply string
+}
+
+func (s *scripted) Generate(context.Context, []types.Message, types.GenerateOptions) (string, types.Usage, error) {
+	s.calls++
+	return s.reply, types.Usage{PromptTokens: 3, CompletionTokens: 2}, nil
+}
+
+func (s *scripted) Stream(_ context.Context, _ []types.Message, _ types.GenerateOptions, cb func(types.Chunk)) (types.Usage, error) {
+	s.calls++
+	for _, w := range strings.SplitAfter(s.reply, " ") {
+		cb(types.Chunk{Content: w, Delta: 1})
+	}
+	return types.Usage{PromptTokens: 3, CompletionTokens: 2}, nil
+}
+
+func (s *scripted) Embed(_ context.Context, inputs []string) ([][]float32, types.Usage, error) {
+	s.calls++
+	out := make([][]float32, len(inputs))
+	for i, in := range inputs {
+		out[i] = []float32{float32(len(in)), 1}
+	}
+	return out, types.Usage{PromptTokens: len(inputs)}, nil
+}
+
+var hello = []types.Message{{Role: types.RoleUser, Content: "hello"}}
+
+func wrap(t *testing.T, dir string, mode Mode, inner provider.Provider) provider.Provider {
+	t.Helper()
+	var f provider.Factory
+	if inner != nil {
+		f = func(string) (provider.Provider, error) { return inner, nil }
+	}
+	p, err := Factory("fake", dir, mode, f)("m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	dir := t.TempDir()
+	inner := &scripted{reply: "hi there"}
+	ctx := context.Background()
+
+	rec := wrap(t, dir, ModeRecord, inner)
+	if _, _, err := rec.Generate(ctx, hello, types.GenerateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rec.Stream(ctx, hello, types.GenerateOptions{}, func(types.Chunk) {}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := rec.(provider.Embedder).Embed(ctx, []string{"a", "bb"}); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "fake", "*.json"))
+	if len(files) != 3 {
+		t.Fatalf("recorded %d cassettes, want 3", len(files))
+	}
+
+	play := wrap(t, dir, ModeReplay, nil)
+	txt, u, err := play.Generate(ctx, hello, types.GenerateOptions{})
+	if err != nil || txt != "hi there" || u.Total() != 5 {
+		t.Fatalf("Generate = %q %+v %v", txt, u, err)
+	}
+	var chunks []string
+	if _, err := play.Stream(ctx, hello, types.GenerateOptions{}, func(ch types.Chunk) {
+		chunks = append(chunks, ch.Content)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 2 || strings.Join(chunks, "") != "hi there" {
+		t.Fatalf("Stream chunks = %q", chunks)
+	}
+	vecs, _, err := play.(provider.Embedder).Embed(ctx, []string{"a", "bb"})
+	if err != nil || len(vecs) != 2 || vecs[1][0] != 2 {
+		t.Fatalf("Embed = %v %v", vecs, err)
+	}
+	if inner.calls != 3 {
+		t.Fatalf("replay hit the inner provider: %d calls", inner.calls)
+	}
+}
+
+func TestKeyNormalization(t *testing.T) {
+	base := Request{Provider: "p", Model: "m", Endpoint: "generate", Messages: hello}
+	spaced := base
+	spaced.Messages = []types.Message{{Role: types.RoleUser, Content: "  hello\n"}}
+	if Key(base) != Key(spaced) {
+		t.Error("surrounding whitespace changed the key")
+	}
+
+	a := base
+	a.Tools = []types.Tool{{Name: "t", Parameters: []byte(`{"type":"object", "properties":{}}`)}}
+	b := base
+	b.Tools = []types.Tool{{Name: "t", Parameters: []byte(`{"properties":{},"type":"object"}`)}}
+	if Key(a) != Key(b) {
+		t.Error("schema key order changed the key")
+	}
+
+	other := base
+	other.Model = "m2"
+	if Key(base) == Key(other) {
+		t.Error("different model produced the same key")
+	}
+}
+
+func TestMissingCassette(t *testing.T) {
+	p := wrap(t, t.TempDir(), ModeReplay, nil)
+	_, _, err := p.Generate(context.Background(), hello, types.GenerateOptions{})
+	if !errors.Is(err, ErrNoCassette) {
+		t.Fatalf("err = %v, want ErrNoCassette", err)
+	}
+	if provider.Retryable(err) {
+		t.Error("missing cassette should not be retried")
+	}
+}
+
+func TestAutoRecordsOnce(t *testing.T) {
+	dir := t.TempDir()
+	inner := &scripted{reply: "once"}
+	p := wrap(t, dir, ModeAuto, inner)
+	for i := 0; i < 3; i++ {
+		if txt, _, err := p.Generate(context.Background(), hello, types.GenerateOptions{}); err != nil || txt != "once" {
+			t.Fatalf("Generate = %q %v", txt, err)
+		}
+	}
+	if inner.calls != 1 {
+		t.Fatalf("inner called %d times, want 1", inner.calls)
+	}
+}
+
+func TestFailureCassette(t *testing.T) {
+	dir := t.TempDir()
+	req := Request{Provider: "fake", Model: "m", Endpoint: "generate", Messages: hello}
+	if err := save(filepath.Join(dir, "fake", Key(req)+".json"), &Cassette{
+		Key: Key(req), Request: req, Error: &Failure{Status: 429, Message: "slow down"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err := wrap(t, dir, ModeReplay, nil).Generate(context.Background(), hello, types.GenerateOptions{})
+	if !errors.Is(err, provider.ErrRateLimited) {
+		t.Fatalf("err = %v, want ErrRateLimited", err)
+	}
+}
+
+// checked 只额外实现 HealthChecker
+type checked struct{ scripted }
+
+func (c *checked) HealthCheck(context.Context) error { return errors.New("probed") }
+
+func TestCapabilitiesFollowInner(t *testing.T) {
+	// scripted：能 Embed，不能调工具，没有健康检查
+	p := wrap(t, t.TempDir(), ModeReplay, &scripted{})
+	if _, ok := provider.As[provider.ToolCaller](p); ok {
+		t.Error("replay claims tools the inner provider lacks")
+	}
+	if _, ok := provider.As[provider.Embedder](p); !ok {
+		t.Error("replay hides Embedder")
+	}
+	if _, ok := provider.As[provider.HealthChecker](p); ok {
+		t.Error("replay claims a health check the inner provider lacks")
+	}
+
+	// 未录制的接口直接转给被包装者
+	p = wrap(t, t.TempDir(), ModeReplay, &checked{})
+	hc, ok := provider.As[provider.HealthChecker](p)
+	if !ok {
+		t.Fatal("replay hides HealthChecker")
+	}
+	if err := hc.HealthCheck(context.Background()); err == nil || err.Error() != "probed" {
+		t.Errorf("HealthCheck = %v, want the inner provider's result", err)
+	}
+
+	// 只能回放：以录制层自身为准
+	p = wrap(t, t.TempDir(), ModeReplay, nil)
+	if _, ok := provider.As[provider.ToolCaller](p); !ok {
+		t.Error("replay-only wrapper should play back tool calls")
+	}
+}
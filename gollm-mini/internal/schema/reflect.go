@@ -0,0 +1,295 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 由 Go 类型生成 JSON Schema。字段名遵循 encoding/json（json 标签、"-"、匿名嵌入展开），其余标签：
+//
+//	description:"城市名称"      字段说明
+//	enum:"low,medium,high"     可选值（逗号分隔，按字段类型解析；切片字段作用于元素）
+//	format:"date-time"         string 格式（email / uri / date / ...）；time.Time 默认 date-time
+//	required:"true" / "false"  覆盖默认规则：非指针且没有 omitempty 的字段为必填
+//
+// 结构体一律 additionalProperties=false；递归类型放入 $defs 并以 $ref 引用。
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	rawType  = reflect.TypeOf(json.RawMessage{})
+	cache    sync.Map // reflect.Type -> json.RawMessage
+)
+
+// Of 返回 T 的 JSON Schema
+func Of[T any]() (json.RawMessage, error) {
+	return Reflect(reflect.TypeOf((*T)(nil)).Elem())
+}
+
+// Reflect 返回类型 t 的 JSON Schema（按类型缓存）
+func Reflect(t reflect.Type) (json.RawMessage, error) {
+	if v, ok := cache.Load(t); ok {
+		return v.(json.RawMessage), nil
+	}
+	g := &generator{root: deref(t), visiting: map[reflect.Type]bool{}, defs: map[string]*node{}}
+	n, err := g.node(t)
+	if err != nil {
+		return nil, err
+	}
+	// 递归引用的类型在主体生成后补到 $defs（其内部的自引用仍是 $ref）
+	for len(g.pending) > 0 {
+		dt := g.pending[0]
+		g.pending = g.pending[1:]
+		g.visiting[dt] = true
+		d, err := g.object(dt)
+		g.visiting[dt] = false
+		if err != nil {
+			return nil, err
+		}
+		g.defs[defName(dt)] = d
+	}
+	if len(g.defs) > 0 {
+		n.Defs = g.defs
+	}
+	b, err := json.Marshal(n)
+	if err != nil {
+		return nil, err
+	}
+	cache.Store(t, json.RawMessage(b))
+	return b, nil
+}
+
+// node 一个 schema 节点；字段顺序即输出顺序
+type node struct {
+	Ref                  string           `json:"$ref,omitempty"`
+	Type                 string           `json:"type,omitempty"`
+	Description          string           `json:"description,omitempty"`
+	Format               string           `json:"format,omitempty"`
+	Enum                 []interface{}    `json:"enum,omitempty"`
+	Minimum              *int             `json:"minimum,omitempty"`
+	Items                *node            `json:"items,omitempty"`
+	Properties           properties       `json:"properties,omitempty"`
+	Required             []string         `json:"required,omitempty"`
+	AdditionalProperties interface{}      `json:"additionalProperties,omitempty"` // false 或 *node
+	Defs                 map[string]*node `json:"$defs,omitempty"`
+}
+
+type property struct {
+	name string
+	node *node
+}
+
+// properties 按结构体字段顺序输出，便于模型按声明顺序生成
+type properties []property
+
+func (ps properties) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, p := range ps {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(p.name)
+		v, err := json.Marshal(p.node)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+type generator struct {
+	root     reflect.Type
+	visiting map[reflect.Type]bool // 正在生成的结构体，再次遇到即为递归
+	defs     map[string]*node
+	pending  []reflect.Type
+}
+
+func (g *generator) node(t reflect.Type) (*node, error) {
+	t = deref(t)
+	switch t {
+	case timeType:
+		return &node{Type: "string", Format: "date-time"}, nil
+	case rawType:
+		return &node{}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &node{Type: "string"}, nil
+	case reflect.Bool:
+		return &node{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &node{Type: "integer"}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := 0
+		return &node{Type: "integer", Minimum: &zero}, nil
+	case reflect.Float32, reflect.Float64:
+		return &node{Type: "number"}, nil
+	case reflect.Interface:
+		return &node{}, nil // 任意值
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &node{Type: "string"}, nil // encoding/json 把 []byte 编码为 base64 字符串
+		}
+		items, err := g.node(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &node{Type: "array", Items: items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("schema: map key must be string, got %s", t)
+		}
+		values, err := g.node(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &node{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Struct:
+		if t == g.root && g.visiting[t] {
+			return &node{Ref: "#"}, nil
+		}
+		if g.visiting[t] {
+			name := defName(t)
+			if _, ok := g.defs[name]; !ok {
+				g.defs[name] = nil // 占位，避免重复排队
+				g.pending = append(g.pending, t)
+			}
+			return &node{Ref: "#/$defs/" + name}, nil
+		}
+		g.visiting[t] = true
+		defer func() { g.visiting[t] = false }()
+		return g.object(t)
+	}
+	return nil, fmt.Errorf("schema: unsupported type %s", t)
+}
+
+// object 生成结构体的 object 节点
+func (g *generator) object(t reflect.Type) (*node, error) {
+	n := &node{Type: "object", AdditionalProperties: false}
+	if err := g.fields(t, n); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (g *generator) fields(t reflect.Type, n *node) error {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && deref(f.Type).Kind() == reflect.Struct {
+			if err := g.fields(deref(f.Type), n); err != nil { // 与 encoding/json 一样展开匿名结构体
+				return err
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		p, err := g.node(f.Type)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", t.Name(), f.Name, err)
+		}
+		if p.Ref == "" {
+			if err := applyTags(p, f); err != nil {
+				return fmt.Errorf("%s.%s: %w", t.Name(), f.Name, err)
+			}
+		}
+		n.Properties = append(n.Properties, property{name: name, node: p})
+
+		required := f.Type.Kind() != reflect.Ptr && !hasOpt(opts, "omitempty")
+		if v := f.Tag.Get("required"); v != "" {
+			if required, err = strconv.ParseBool(v); err != nil {
+				return fmt.Errorf("%s.%s: invalid required tag %q", t.Name(), f.Name, v)
+			}
+		}
+		if required {
+			n.Required = append(n.Required, name)
+		}
+	}
+	return nil
+}
+
+// applyTags 处理 description / enum / format 标签
+func applyTags(n *node, f reflect.StructField) error {
+	if d := f.Tag.Get("description"); d != "" {
+		n.Description = d
+	}
+	target := n
+	if n.Type == "array" && n.Items != nil {
+		target = n.Items // 切片字段的 enum / format 约束元素
+	}
+	if v := f.Tag.Get("format"); v != "" {
+		target.Format = v
+	}
+	if v := f.Tag.Get("enum"); v != "" {
+		for _, s := range strings.Split(v, ",") {
+			s = strings.TrimSpace(s)
+			switch target.Type {
+			case "integer":
+				i, err := strconv.ParseInt(s, 10, 64)
+				if err != nil {
+					return fmt.Errorf("invalid enum value %q", s)
+				}
+				target.Enum = append(target.Enum, i)
+			case "number":
+				x, err := strconv.ParseFloat(s, 64)
+				if err != nil {
+					return fmt.Errorf("invalid enum value %q", s)
+				}
+				target.Enum = append(target.Enum, x)
+			case "boolean":
+				b, err := strconv.ParseBool(s)
+				if err != nil {
+					return fmt.Errorf("invalid enum value %q", s)
+				}
+				target.Enum = append(target.Enum, b)
+			default:
+				target.Enum = append(target.Enum, s)
+			}
+		}
+	}
+	return nil
+}
+
+func hasOpt(opts, want string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		if o == want {
+			return true
+		}
+	}
+	return false
+}
+
+func deref(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// defName $defs 中的名称；匿名类型用类型字符串
+func defName(t reflect.Type) string {
+	if t.Name() != "" {
+		return t.Name()
+	}
+	return strings.NewReplacer(" ", "", "*", "", "{", "_", "}", "_").Replace(t.String())
+}
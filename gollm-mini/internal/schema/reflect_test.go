@@ -0,0 +1,179 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"gollm-mini/internal/helper"
+)
+
+type address struct {
+	City    string `json:"city" description:"城市"`
+	Country string `json:"country,omitempty"`
+}
+
+type Base struct {
+	ID string `json:"id" format:"uuid"`
+}
+
+type person struct {
+	Base
+	Name     string            `json:"name" description:"全名"`
+	Age      uint              `json:"age"`
+	Email    string            `json:"email,omitempty" format:"email"`
+	Level    string            `json:"level" enum:"junior, senior"`
+	Scores   []int             `json:"scores" enum:"1,2,3"`
+	Born     time.Time         `json:"born"`
+	Home     *address          `json:"home"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Nickname string            `json:"nickname" required:"false"`
+	Secret   string            `json:"-"`
+	internal int
+}
+
+type tree struct {
+	Value    int    `json:"value"`
+	Children []tree `json:"children,omitempty"`
+}
+
+type list struct {
+	Head *elem `json:"head"`
+}
+
+type elem struct {
+	V    string `json:"v"`
+	Next *elem  `json:"next,omitempty"`
+}
+
+func decodeSchema(t *testing.T, raw json.RawMessage) map[string]interface{} {
+	t.Helper()
+	var m map[string]interface{}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestReflectStruct(t *testing.T) {
+	raw, err := Of[person]()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Check(raw); err != nil {
+		t.Fatalf("generated schema does not compile: %v\n%s", err, raw)
+	}
+	m := decodeSchema(t, raw)
+	if m["type"] != "object" || m["additionalProperties"] != false {
+		t.Errorf("root = %s", raw)
+	}
+	props := m["properties"].(map[string]interface{})
+	for _, name := range []string{"id", "name", "age", "email", "level", "scores", "born", "home", "labels", "nickname"} {
+		if _, ok := props[name]; !ok {
+			t.Errorf("missing property %q in %s", name, raw)
+		}
+	}
+	for _, name := range []string{"Secret", "internal", "Base"} {
+		if _, ok := props[name]; ok {
+			t.Errorf("unexpected property %q", name)
+		}
+	}
+
+	var required []string
+	for _, r := range m["required"].([]interface{}) {
+		required = append(required, r.(string))
+	}
+	want := []string{"id", "name", "age", "level", "scores", "born"}
+	if !reflect.DeepEqual(required, want) {
+		t.Errorf("required = %v, want %v", required, want)
+	}
+
+	prop := func(name string) map[string]interface{} { return props[name].(map[string]interface{}) }
+	if prop("name")["description"] != "全名" || prop("email")["format"] != "email" || prop("born")["format"] != "date-time" {
+		t.Errorf("tags not applied: %s", raw)
+	}
+	if prop("age")["minimum"] != 0.0 {
+		t.Errorf("uint without minimum: %v", prop("age"))
+	}
+	if !reflect.DeepEqual(prop("level")["enum"], []interface{}{"junior", "senior"}) {
+		t.Errorf("level enum = %v", prop("level")["enum"])
+	}
+	items := prop("scores")["items"].(map[string]interface{})
+	if !reflect.DeepEqual(items["enum"], []interface{}{1.0, 2.0, 3.0}) {
+		t.Errorf("scores items = %v", items)
+	}
+	home := prop("home")
+	if home["type"] != "object" || home["properties"].(map[string]interface{})["city"] == nil {
+		t.Errorf("home = %v", home)
+	}
+	if prop("labels")["additionalProperties"].(map[string]interface{})["type"] != "string" {
+		t.Errorf("labels = %v", prop("labels"))
+	}
+}
+
+func TestReflectValidates(t *testing.T) {
+	raw, err := Of[person]()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok := `{"id":"4b3c9c1e-7d0a-4c55-9d6e-2f3b1a0c8e11","name":"Ada","age":36,"level":"senior","scores":[1,3],
+		"born":"1815-12-10T00:00:00Z","home":{"city":"London"}}`
+	if err := helper.ValidateJSONSchemaBytes(raw, []byte(ok)); err != nil {
+		t.Errorf("valid document rejected: %v", err)
+	}
+	for name, doc := range map[string]string{
+		"bad enum":      `{"id":"x","name":"Ada","age":36,"level":"lead","scores":[],"born":"1815-12-10T00:00:00Z"}`,
+		"missing field": `{"id":"x","name":"Ada","age":36,"scores":[],"born":"1815-12-10T00:00:00Z"}`,
+		"negative uint": `{"id":"x","name":"Ada","age":-1,"level":"senior","scores":[],"born":"1815-12-10T00:00:00Z"}`,
+		"extra field":   `{"id":"x","name":"Ada","age":36,"level":"senior","scores":[],"born":"1815-12-10T00:00:00Z","x":1}`,
+	} {
+		if err := helper.ValidateJSONSchemaBytes(raw, []byte(doc)); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+func TestReflectRecursive(t *testing.T) {
+	raw, err := Of[tree]()
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := `{"value":1,"children":[{"value":2,"children":[{"value":3}]}]}`
+	if err := helper.ValidateJSONSchemaBytes(raw, []byte(doc)); err != nil {
+		t.Errorf("root recursion: %v\n%s", err, raw)
+	}
+	if err := helper.ValidateJSONSchemaBytes(raw, []byte(`{"value":1,"children":[{"value":"x"}]}`)); err == nil {
+		t.Error("nested mismatch accepted")
+	}
+
+	raw, err = Of[list]()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := decodeSchema(t, raw)["$defs"].(map[string]interface{})["elem"]; !ok {
+		t.Fatalf("missing $defs.elem: %s", raw)
+	}
+	if err := helper.ValidateJSONSchemaBytes(raw, []byte(`{"head":{"v":"a","next":{"v":"b"}}}`)); err != nil {
+		t.Errorf("nested recursion: %v\n%s", err, raw)
+	}
+	if err := helper.ValidateJSONSchemaBytes(raw, []byte(`{"head":{"v":"a","next":{"v":2}}}`)); err == nil {
+		t.Error("nested recursion mismatch accepted")
+	}
+}
+
+func TestReflectErrors(t *testing.T) {
+	if _, err := Of[map[int]string](); err == nil {
+		t.Error("non-string map key accepted")
+	}
+	if _, err := Of[struct {
+		F chan int `json:"f"`
+	}](); err == nil {
+		t.Error("chan field accepted")
+	}
+	if _, err := Of[struct {
+		N int `json:"n" enum:"a"`
+	}](); err == nil {
+		t.Error("invalid integer enum accepted")
+	}
+}
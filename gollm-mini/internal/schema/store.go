@@ -0,0 +1,274 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xeipuuv/gojsonschema"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	bucket        = "schemas"
+	versionBucket = "schema_versions" // 名称 -> 已分配的最大版本号，删除记录时不回退
+)
+
+// ErrNotFound 名称或版本不存在
+var ErrNotFound = errors.New("schema not found")
+
+// validName 名称只允许字母、数字与 _ . -（"@" 用于引用版本，":" 是存储键的分隔符）
+var validName = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// Schema 一个命名、带版本的 JSON Schema
+type Schema struct {
+	Name        string          `json:"name"`
+	Version     int             `json:"version"`
+	Description string          `json:"description,omitempty"`
+	Schema      json.RawMessage `json:"schema"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+type Store struct{ db *bolt.DB }
+
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	return &Store{db: db}, err
+}
+
+// Ping 检查 schemas.db 能否开启读事务（用于就绪检查）
+func (s *Store) Ping() error {
+	return s.db.View(func(tx *bolt.Tx) error { return nil })
+}
+
+// Close 释放 bbolt 文件锁
+func (s *Store) Close() error { return s.db.Close() }
+
+// Save 校验后保存为该名称的下一个版本，返回保存的记录
+func (s *Store) Save(name, description string, raw json.RawMessage) (Schema, error) {
+	if !validName.MatchString(name) {
+		return Schema{}, fmt.Errorf("invalid schema name %q (letters, digits, _ . - only)", name)
+	}
+	compact, err := Check(raw)
+	if err != nil {
+		return Schema{}, err
+	}
+	sc := Schema{Name: name, Description: description, Schema: compact, CreatedAt: time.Now()}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		vb, err := tx.CreateBucketIfNotExists([]byte(versionBucket))
+		if err != nil {
+			return err
+		}
+		// 版本号取计数器与现存记录的较大者（兼容没有计数器的旧库），删除后也不会复用
+		last, _ := strconv.Atoi(string(vb.Get([]byte(name))))
+		if k, _ := lastKey(b, name); k != nil && keyVersion(k) > last {
+			last = keyVersion(k)
+		}
+		sc.Version = last + 1
+		if err := vb.Put([]byte(name), []byte(strconv.Itoa(sc.Version))); err != nil {
+			return err
+		}
+		data, _ := json.Marshal(sc)
+		return b.Put(key(name, sc.Version), data)
+	})
+	return sc, err
+}
+
+// Get 返回指定版本；version <= 0 表示最新版本
+func (s *Store) Get(name string, version int) (Schema, error) {
+	var sc Schema
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return ErrNotFound
+		}
+		var v []byte
+		if version <= 0 {
+			_, v = lastKey(b, name)
+		} else {
+			v = b.Get(key(name, version))
+		}
+		if v == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(v, &sc)
+	})
+	return sc, err
+}
+
+// Resolve 解析引用 "name" 或 "name@version"
+func (s *Store) Resolve(ref string) (Schema, error) {
+	name, ver, found := strings.Cut(ref, "@")
+	version := 0
+	if found {
+		v, err := strconv.Atoi(ver)
+		if err != nil || v <= 0 {
+			return Schema{}, fmt.Errorf("invalid schema version in %q", ref)
+		}
+		version = v
+	}
+	sc, err := s.Get(name, version)
+	if err != nil {
+		return sc, fmt.Errorf("%s: %w", ref, err)
+	}
+	return sc, nil
+}
+
+// List 返回同名 schema 的所有版本（按版本升序）
+func (s *Store) List(name string) ([]Schema, error) {
+	var list []Schema
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		prefix := []byte(name + ":")
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var sc Schema
+			if json.Unmarshal(v, &sc) == nil {
+				list = append(list, sc)
+			}
+		}
+		return nil
+	})
+	return list, err
+}
+
+// ListAllLatest 返回每个名称的最新版本（按名称排序）
+func (s *Store) ListAllLatest() ([]Schema, error) {
+	latest := make(map[string]Schema)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(_, v []byte) error {
+			var sc Schema
+			if json.Unmarshal(v, &sc) == nil {
+				if cur, ok := latest[sc.Name]; !ok || sc.Version > cur.Version {
+					latest[sc.Name] = sc
+				}
+			}
+			return nil
+		})
+	})
+	list := make([]Schema, 0, len(latest))
+	for _, v := range latest {
+		list = append(list, v)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, err
+}
+
+// Delete 删除指定版本；version <= 0 时删除该名称的全部版本。不存在时返回 ErrNotFound。
+// 版本计数器保留，之后保存的版本号继续递增，旧引用 name@version 不会指向新内容
+func (s *Store) Delete(name string, version int) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return ErrNotFound
+		}
+		if version > 0 {
+			k := key(name, version)
+			if b.Get(k) == nil {
+				return ErrNotFound
+			}
+			return b.Delete(k)
+		}
+		var keys [][]byte
+		c := b.Cursor()
+		prefix := []byte(name + ":")
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		if len(keys) == 0 {
+			return ErrNotFound
+		}
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// key 版本号补零，保证游标按版本顺序遍历
+func key(name string, ver int) []byte { return []byte(fmt.Sprintf("%s:%08d", name, ver)) }
+
+func keyVersion(k []byte) int {
+	i := bytes.LastIndexByte(k, ':')
+	v, _ := strconv.Atoi(string(k[i+1:]))
+	return v
+}
+
+// lastKey 同名的最大版本
+func lastKey(b *bolt.Bucket, name string) ([]byte, []byte) {
+	c := b.Cursor()
+	prefix := []byte(name + ":")
+	var lk, lv []byte
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		lk, lv = k, v
+	}
+	return lk, lv
+}
+
+/* ---------- validation ---------- */
+
+// Check 校验 schema 本身：必须是 JSON 对象、能被编译，且 $ref / $id 只能指向文档内部（"#..."），
+// 防止通过 file:// 或 http:// 引用读取服务器文件或发起请求。返回压缩后的 schema。
+func Check(raw json.RawMessage) (json.RawMessage, error) {
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, fmt.Errorf("schema is not valid JSON: %w", err)
+	}
+	if _, ok := v.(map[string]interface{}); !ok {
+		return nil, errors.New("schema must be a JSON object")
+	}
+	if err := localRefs(v); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return nil, err
+	}
+	if _, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(buf.Bytes())); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func localRefs(v interface{}) error {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			switch k {
+			case "$ref", "$id", "id": // id 是 draft-04 的 $id；作为属性名时值是对象，不受影响
+				if s, ok := child.(string); ok && !strings.HasPrefix(s, "#") {
+					return fmt.Errorf("external %s %q not allowed", k, s)
+				}
+			}
+			if err := localRefs(child); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, child := range t {
+			if err := localRefs(child); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveNeverReusesVersion(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "schemas.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	raw := json.RawMessage(`{"type":"object"}`)
+	save := func() int {
+		t.Helper()
+		sc, err := s.Save("person", "", raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sc.Version
+	}
+
+	if v := save(); v != 1 {
+		t.Fatalf("first version = %d", v)
+	}
+	if v := save(); v != 2 {
+		t.Fatalf("second version = %d", v)
+	}
+	// 删除最新版本后，下一个版本不能复用 2
+	if err := s.Delete("person", 2); err != nil {
+		t.Fatal(err)
+	}
+	if v := save(); v != 3 {
+		t.Errorf("after deleting v2: version = %d, want 3", v)
+	}
+	// 删除全部版本后同样继续递增
+	if err := s.Delete("person", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get("person", 0); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get after delete: %v", err)
+	}
+	if v := save(); v != 4 {
+		t.Errorf("after deleting all: version = %d, want 4", v)
+	}
+	if _, err := s.Get("person", 3); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleted v3 resolved again: %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gollm-mini/internal/cache"
+	"gollm-mini/internal/memory"
+	"gollm-mini/internal/monitor"
+	"gollm-mini/internal/provider"
+	"gollm-mini/internal/schema"
+	"gollm-mini/internal/template"
+)
+
+// probeTimeout 单个组件探测的超时
+const probeTimeout = 3 * time.Second
+
+// ComponentStatus 单个组件的探测结果
+type ComponentStatus struct {
+	Status    string  `json:"status"` // ok / down / skipped
+	Required  bool    `json:"required"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type ReadyResponse struct {
+	Status     string                     `json:"status"` // ok / degraded / down
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+type probe struct {
+	name     string
+	required bool
+	check    func(ctx context.Context) error
+}
+
+func handleLive(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// handleReady 并发探测存储与各 Provider；必需组件失败返回 503
+func handleReady(c *gin.Context, tplStore *template.Store, schemas *schema.Store) {
+	probes := []probe{
+		{"storage:templates.db", true, func(context.Context) error { return tplStore.Ping() }},
+		{"storage:schemas.db", true, func(context.Context) error { return schemas.Ping() }},
+		{"storage:memory.db", true, func(context.Context) error { return memory.Ping() }},
+		{"storage:prompt_cache.db", true, func(context.Context) error { return cache.Ping() }},
+	}
+	required := requiredProviders()
+	for _, name := range provider.Names() {
+		probes = append(probes, probe{"provider:" + name, required[name], func(ctx context.Context) error {
+			p, err := provider.Get(name, "")
+			if err != nil {
+				return err
+			}
+			hc, ok := provider.As[provider.HealthChecker](p)
+			if !ok {
+				return errSkipped
+			}
+			return hc.HealthCheck(ctx)
+		}})
+	}
+
+	ctx := c.Request.Context() // gin.Context 会被复用，不能交给 goroutine
+	res := ReadyResponse{Status: "ok", Components: make(map[string]ComponentStatus, len(probes))}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, p := range probes {
+		wg.Add(1)
+		go func(p probe) {
+			defer wg.Done()
+			st := runProbe(ctx, p)
+			mu.Lock()
+			res.Components[p.name] = st
+			mu.Unlock()
+		}(p)
+	}
+	wg.Wait()
+
+	code := http.StatusOK
+	for _, st := range res.Components {
+		if st.Status != "down" {
+			continue
+		}
+		if st.Required {
+			res.Status = "down"
+			code = http.StatusServiceUnavailable
+		} else if res.Status == "ok" {
+			res.Status = "degraded"
+		}
+	}
+	c.JSON(code, res)
+}
+
+var errSkipped = errors.New("no health check")
+
+// runProbe 执行探测并更新 Prometheus gauge；bbolt 打开可能阻塞，因此放在 goroutine 里按超时返回
+func runProbe(ctx context.Context, p probe) ComponentStatus {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- p.check(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	dur := time.Since(start)
+
+	st := ComponentStatus{Status: "ok", Required: p.required, LatencyMS: float64(dur.Microseconds()) / 1000}
+	switch {
+	case errors.Is(err, errSkipped):
+		st.Status = "skipped"
+		return st
+	case err != nil:
+		st.Status = "down"
+		st.Error = err.Error()
+	}
+
+	up := 0.0
+	if err == nil {
+		up = 1
+	}
+	monitor.ComponentUp.WithLabelValues(p.name).Set(up)
+	monitor.ComponentLatency.WithLabelValues(p.name).Set(dur.Seconds())
+	return st
+}
+
+// requiredProviders 读取 GOLLM_REQUIRED_PROVIDERS（逗号分隔，默认 ollama）；
+// 其余 Provider 失败只会让状态变为 degraded
+func requiredProviders() map[string]bool {
+	v, ok := os.LookupEnv("GOLLM_REQUIRED_PROVIDERS")
+	if !ok {
+		v = "ollama"
+	}
+	out := map[string]bool{}
+	for _, n := range strings.Split(v, ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			out[n] = true
+		}
+	}
+	return out
+}
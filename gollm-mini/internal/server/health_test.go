@@ -0,0 +1,84 @@
+package server
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"gollm-mini/internal/monitor"
+	"gollm-mini/internal/schema"
+	"gollm-mini/internal/template"
+)
+
+var storages = []string{"storage:templates.db", "storage:schemas.db", "storage:memory.db", "storage:prompt_cache.db"}
+
+func TestHealthLive(t *testing.T) {
+	w := do(t, newTestRouter(t), "GET", "/health/live", nil)
+	var resp map[string]string
+	decode(t, w, &resp)
+	if w.Code != 200 || resp["status"] != "ok" {
+		t.Fatalf("status %d, body %v", w.Code, resp)
+	}
+}
+
+func TestHealthReady(t *testing.T) {
+	t.Setenv("GOLLM_REQUIRED_PROVIDERS", "") // Provider 失败只影响 degraded
+	w := do(t, newTestRouter(t), "GET", "/health/ready", nil)
+	var resp ReadyResponse
+	decode(t, w, &resp)
+	if w.Code != 200 || (resp.Status != "ok" && resp.Status != "degraded") {
+		t.Fatalf("status %d, body %+v", w.Code, resp)
+	}
+	for _, name := range append(storages, "provider:ollama", "provider:openai") {
+		if _, ok := resp.Components[name]; !ok {
+			t.Errorf("component %s missing: %+v", name, resp.Components)
+		}
+	}
+	for _, name := range storages {
+		st := resp.Components[name]
+		if st.Status != "ok" || !st.Required || st.Error != "" {
+			t.Errorf("%s = %+v", name, st)
+		}
+		if up := testutil.ToFloat64(monitor.ComponentUp.WithLabelValues(name)); up != 1 {
+			t.Errorf("health_component_up{%s} = %v, want 1", name, up)
+		}
+	}
+	for name, st := range resp.Components {
+		if st.Required && strings.HasPrefix(name, "provider:") {
+			t.Errorf("%s should not be required", name)
+		}
+	}
+}
+
+func TestHealthReadyStorageDown(t *testing.T) {
+	t.Setenv("GOLLM_REQUIRED_PROVIDERS", "")
+	tpl, err := template.Open(filepath.Join(t.TempDir(), "templates.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = tpl.Close() })
+	schemas, err := schema.Open(filepath.Join(t.TempDir(), "schemas.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = schemas.Close() // 必需组件不可用
+
+	w := do(t, NewRouter(tpl, schemas), "GET", "/health/ready", nil)
+	var resp ReadyResponse
+	decode(t, w, &resp)
+	if w.Code != 503 || resp.Status != "down" {
+		t.Fatalf("status %d, body %+v", w.Code, resp)
+	}
+	st := resp.Components["storage:schemas.db"]
+	if st.Status != "down" || st.Error == "" {
+		t.Errorf("schemas.db = %+v", st)
+	}
+	if up := testutil.ToFloat64(monitor.ComponentUp.WithLabelValues("storage:schemas.db")); up != 0 {
+		t.Errorf("health_component_up{storage:schemas.db} = %v, want 0", up)
+	}
+	if resp.Components["storage:memory.db"].Status != "ok" {
+		t.Errorf("memory.db = %+v", resp.Components["storage:memory.db"])
+	}
+}
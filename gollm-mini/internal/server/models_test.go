@@ -0,0 +1,153 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"gollm-mini/internal/provider"
+	"gollm-mini/internal/provider/ollama"
+	"gollm-mini/internal/types"
+)
+
+// newFakeOllama 只实现模型管理相关接口的假 Ollama，已有模型 llama3:latest
+func newFakeOllama(t *testing.T) *httptest.Server {
+	var mu sync.Mutex
+	models := map[string]bool{"llama3:latest": true}
+	has := func(m string) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return models[m]
+	}
+	decode := func(r *http.Request) string {
+		var req struct{ Model string }
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if !strings.Contains(req.Model, ":") {
+			req.Model += ":latest"
+		}
+		return req.Model
+	}
+	missing := func(w http.ResponseWriter) {
+		w.WriteHeader(404)
+		_, _ = w.Write([]byte(`{"error":"model not found"}`))
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/tags", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"models":[]}`))
+	})
+	mux.HandleFunc("/api/pull", func(w http.ResponseWriter, r *http.Request) {
+		m := decode(r)
+		if strings.HasPrefix(m, "missing") {
+			missing(w)
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"pulling manifest"}` + "\n" +
+			`{"status":"pulling 6a0746a1ec1a","digest":"sha256:6a0746a1ec1a","total":100,"completed":100}` + "\n" +
+			`{"status":"success"}` + "\n"))
+		mu.Lock()
+		models[m] = true
+		mu.Unlock()
+	})
+	mux.HandleFunc("/api/show", func(w http.ResponseWriter, r *http.Request) {
+		if !has(decode(r)) {
+			missing(w)
+			return
+		}
+		_, _ = w.Write([]byte(`{"details":{"family":"qwen2","parameter_size":"7.6B"},"capabilities":["completion"]}`))
+	})
+	mux.HandleFunc("/api/delete", func(w http.ResponseWriter, r *http.Request) {
+		m := decode(r)
+		if !has(m) {
+			missing(w)
+			return
+		}
+		mu.Lock()
+		delete(models, m)
+		mu.Unlock()
+	})
+	mux.HandleFunc("/api/generate", func(w http.ResponseWriter, r *http.Request) {
+		if !has(decode(r)) {
+			missing(w)
+			return
+		}
+		_, _ = w.Write([]byte(`{"done":true}`))
+	})
+	s := httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestModelManagement(t *testing.T) {
+	pool, err := ollama.NewPool([]string{newFakeOllama(t).URL}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider.Register("ollama-managed", provider.Simple("", func(m string) *ollama.Ollama {
+		return ollama.NewWithPool(m, pool)
+	}))
+	r := newTestRouter(t)
+
+	// 拉取：SSE 进度 + done
+	w := do(t, r, "POST", "/providers/ollama-managed/models", gin.H{"model": "qwen2:7b"})
+	var last types.PullProgress
+	for _, ev := range strings.Split(w.Body.String(), "\n\n") {
+		if data, ok := strings.CutPrefix(ev, "event: progress\ndata: "); ok {
+			if err := json.Unmarshal([]byte(data), &last); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if last.Status != "success" || !strings.Contains(w.Body.String(), "event: done") || strings.Contains(w.Body.String(), "event: error") {
+		t.Fatalf("pull stream:\n%s", w.Body)
+	}
+
+	// 拉取失败：以带 JSON 负载的 event: error 结束，没有 done
+	w = do(t, r, "POST", "/providers/ollama-managed/models", gin.H{"model": "missing:1b"})
+	body := w.Body.String()
+	data, ok := strings.CutPrefix(strings.TrimSpace(body), "event: error\ndata: ")
+	var pullErr struct {
+		Error  string `json:"error"`
+		Status int    `json:"status"`
+	}
+	if !ok || json.Unmarshal([]byte(data), &pullErr) != nil || pullErr.Status != 404 || pullErr.Error == "" ||
+		strings.Contains(body, "event: done") {
+		t.Fatalf("failed pull stream:\n%s", body)
+	}
+
+	w = do(t, r, "GET", "/providers/ollama-managed/models/qwen2:7b", nil)
+	var d types.ModelDetails
+	decode(t, w, &d)
+	if w.Code != 200 || d.Family != "qwen2" {
+		t.Fatalf("show: %d %s", w.Code, w.Body)
+	}
+
+	if w = do(t, r, "POST", "/providers/ollama-managed/models/qwen2:7b/keepalive", gin.H{"keep_alive": "10m"}); w.Code != 200 {
+		t.Errorf("keepalive: %d %s", w.Code, w.Body)
+	}
+	if w = do(t, r, "POST", "/providers/ollama-managed/models/qwen2:7b/keepalive", gin.H{"keep_alive": "soon"}); w.Code != 400 {
+		t.Errorf("bad keepalive: %d", w.Code)
+	}
+
+	if w = do(t, r, "DELETE", "/providers/ollama-managed/models/qwen2:7b", nil); w.Code != 204 {
+		t.Fatalf("delete: %d %s", w.Code, w.Body)
+	}
+	if w = do(t, r, "GET", "/providers/ollama-managed/models/qwen2:7b", nil); w.Code != 404 {
+		t.Errorf("show after delete: %d", w.Code)
+	}
+}
+
+func TestModelManagementUnsupported(t *testing.T) {
+	r := newTestRouter(t)
+	if w := do(t, r, "GET", "/providers/openai/models/gpt-4o", nil); w.Code != 501 {
+		t.Errorf("status %d, want 501", w.Code)
+	}
+	if w := do(t, r, "DELETE", "/providers/nope/models/x", nil); w.Code != 404 {
+		t.Errorf("status %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"gollm-mini/internal/schema"
+)
+
+// SchemaRequest POST /schemas：每次保存生成该名称的下一个版本
+type SchemaRequest struct {
+	Name        string          `json:"name" binding:"required"`
+	Description string          `json:"description"`
+	Schema      json.RawMessage `json:"schema" binding:"required"`
+}
+
+// hasSchema 请求是否指定了 schema（省略或 null 视为未指定）
+func hasSchema(raw json.RawMessage) bool {
+	return len(raw) > 0 && string(raw) != "null"
+}
+
+// resolveSchema ChatRequest.Schema 为 JSON 字符串时按注册表名称（"name" / "name@version"）查找，
+// 为对象时作为内联 schema 校验；从不当作文件路径。返回 schema 与出错时的 HTTP 状态码
+func resolveSchema(raw json.RawMessage, store *schema.Store) (json.RawMessage, int, error) {
+	var ref string
+	if json.Unmarshal(raw, &ref) == nil {
+		sc, err := store.Resolve(ref)
+		if errors.Is(err, schema.ErrNotFound) {
+			return nil, 404, err
+		}
+		if err != nil {
+			return nil, 400, err
+		}
+		return sc.Schema, 0, nil
+	}
+	sc, err := schema.Check(raw)
+	if err != nil {
+		return nil, 400, err
+	}
+	return sc, 0, nil
+}
+
+/* ---------- schema CRUD ---------- */
+
+func handleSchemaSave(c *gin.Context, store *schema.Store) {
+	var req SchemaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	sc, err := store.Save(req.Name, req.Description, req.Schema)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(201, sc)
+}
+
+func handleSchemaList(c *gin.Context, store *schema.Store) {
+	list, err := store.ListAllLatest()
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, list)
+}
+
+func handleSchemaLatestOrVersions(c *gin.Context, store *schema.Store) {
+	name := c.Param("name")
+	if c.Query("all") == "1" {
+		list, err := store.List(name)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		if len(list) == 0 {
+			c.JSON(404, gin.H{"error": schema.ErrNotFound.Error()})
+			return
+		}
+		c.JSON(200, list)
+		return
+	}
+	sc, err := store.Get(name, 0)
+	if err != nil {
+		c.JSON(schemaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, sc)
+}
+
+func handleSchemaGet(c *gin.Context, store *schema.Store) {
+	v, err := strconv.Atoi(c.Param("ver"))
+	if err != nil || v <= 0 {
+		c.JSON(400, gin.H{"error": "invalid version " + c.Param("ver")})
+		return
+	}
+	sc, err := store.Get(c.Param("name"), v)
+	if err != nil {
+		c.JSON(schemaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, sc)
+}
+
+// handleSchemaDelete 带 :ver 时删除单个版本，否则删除该名称的全部版本
+func handleSchemaDelete(c *gin.Context, store *schema.Store) {
+	v := 0
+	if s := c.Param("ver"); s != "" {
+		var err error
+		if v, err = strconv.Atoi(s); err != nil || v <= 0 {
+			c.JSON(400, gin.H{"error": "invalid version " + s})
+			return
+		}
+	}
+	if err := store.Delete(c.Param("name"), v); err != nil {
+		c.JSON(schemaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(204)
+}
+
+func schemaErrorStatus(err error) int {
+	if errors.Is(err, schema.ErrNotFound) {
+		return 404
+	}
+	return 500
+}
@@ -0,0 +1,240 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"gollm-mini/internal/core"
+	"gollm-mini/internal/provider"
+	"gollm-mini/internal/schema"
+	"gollm-mini/internal/types"
+)
+
+var citySchema = gin.H{
+	"type": "object",
+	"properties": gin.H{
+		"name":    gin.H{"type": "string"},
+		"country": gin.H{"type": "string"},
+	},
+	"required": []string{"name", "country"},
+}
+
+// cityModel 固定返回一个城市 JSON，并记录收到的 schema
+type cityModel struct {
+	mu     sync.Mutex
+	schema []byte
+}
+
+func (m *cityModel) Generate(_ context.Context, _ []types.Message, opts types.GenerateOptions) (string, types.Usage, error) {
+	m.mu.Lock()
+	m.schema = opts.JSONSchema
+	m.mu.Unlock()
+	return `{"name":"Tokyo","country":"Japan"}`, types.Usage{PromptTokens: 1, CompletionTokens: 1}, nil
+}
+
+// Stream 把回答切成 5 字节一块
+func (m *cityModel) Stream(ctx context.Context, msgs []types.Message, opts types.GenerateOptions, cb func(types.Chunk)) (types.Usage, error) {
+	txt, u, err := m.Generate(ctx, msgs, opts)
+	for i := 0; i < len(txt); i += 5 {
+		cb(types.Chunk{Content: txt[i:min(i+5, len(txt))], Delta: 1})
+	}
+	return u, err
+}
+
+func (m *cityModel) SupportsJSONSchema() bool { return true }
+
+func TestSchemaCRUD(t *testing.T) {
+	r := newTestRouter(t)
+
+	for want := 1; want <= 2; want++ {
+		w := do(t, r, "POST", "/schemas", gin.H{"name": "city", "description": "a city", "schema": citySchema})
+		if w.Code != 201 {
+			t.Fatalf("save: status %d: %s", w.Code, w.Body)
+		}
+		var sc schema.Schema
+		decode(t, w, &sc)
+		if sc.Name != "city" || sc.Version != want {
+			t.Fatalf("saved %+v, want version %d", sc, want)
+		}
+	}
+
+	var latest schema.Schema
+	decode(t, do(t, r, "GET", "/schemas/city", nil), &latest)
+	if latest.Version != 2 || !strings.Contains(string(latest.Schema), `"required"`) {
+		t.Errorf("latest = %+v", latest)
+	}
+	var versions []schema.Schema
+	decode(t, do(t, r, "GET", "/schemas/city?all=1", nil), &versions)
+	if len(versions) != 2 {
+		t.Errorf("versions = %+v", versions)
+	}
+	var all []schema.Schema
+	decode(t, do(t, r, "GET", "/schemas", nil), &all)
+	if len(all) != 1 || all[0].Version != 2 {
+		t.Errorf("list = %+v", all)
+	}
+
+	if w := do(t, r, "DELETE", "/schemas/city/2", nil); w.Code != 204 {
+		t.Fatalf("delete version: status %d", w.Code)
+	}
+	if w := do(t, r, "GET", "/schemas/city/2", nil); w.Code != 404 {
+		t.Errorf("deleted version: status %d", w.Code)
+	}
+	if w := do(t, r, "DELETE", "/schemas/city", nil); w.Code != 204 {
+		t.Fatalf("delete all: status %d", w.Code)
+	}
+	if w := do(t, r, "GET", "/schemas/city", nil); w.Code != 404 {
+		t.Errorf("deleted schema: status %d", w.Code)
+	}
+}
+
+func TestSchemaSaveRejects(t *testing.T) {
+	r := newTestRouter(t)
+	cases := map[string]gin.H{
+		"bad name":     {"name": "../city", "schema": citySchema},
+		"not object":   {"name": "city", "schema": []int{1}},
+		"external ref": {"name": "city", "schema": gin.H{"$ref": "file:///etc/passwd"}},
+		"external id":  {"name": "city", "schema": gin.H{"$id": "http://example.com/s.json", "$ref": "#/definitions/x"}},
+		"bad keyword":  {"name": "city", "schema": gin.H{"type": 42}},
+	}
+	for name, body := range cases {
+		if w := do(t, r, "POST", "/schemas", body); w.Code != 400 {
+			t.Errorf("%s: status %d: %s", name, w.Code, w.Body)
+		}
+	}
+}
+
+func TestChatSchema(t *testing.T) {
+	m := &cityModel{}
+	provider.Register("city-fake", func(string) (provider.Provider, error) { return m, nil })
+	r := newTestRouter(t)
+	if w := do(t, r, "POST", "/schemas", gin.H{"name": "city", "schema": citySchema}); w.Code != 201 {
+		t.Fatalf("save: status %d: %s", w.Code, w.Body)
+	}
+
+	msgs := []gin.H{{"role": "user", "content": "Describe Tokyo."}}
+	for name, ref := range map[string]interface{}{
+		"registered": "city",
+		"versioned":  "city@1",
+		"inline":     citySchema,
+	} {
+		w := do(t, r, "POST", "/chat", gin.H{"provider": "city-fake", "messages": msgs, "schema": ref})
+		if w.Code != 200 {
+			t.Fatalf("%s: status %d: %s", name, w.Code, w.Body)
+		}
+		var resp ChatResponse
+		decode(t, w, &resp)
+		obj, _ := resp.JSON.(map[string]interface{})
+		if resp.ErrMsg != "" || obj["name"] != "Tokyo" || resp.Structured == nil || resp.Structured.Attempts != 1 {
+			t.Errorf("%s: resp = %+v", name, resp)
+		}
+		if !strings.Contains(string(m.schema), `"country"`) {
+			t.Errorf("%s: schema sent to provider = %s", name, m.schema)
+		}
+	}
+
+	// 文件路径只会被当成注册表名称，不会读取文件
+	for ref, status := range map[string]int{
+		"/etc/passwd":          404,
+		"testdata/city.schema": 404,
+		"missing":              404,
+		"city@x":               400,
+	} {
+		w := do(t, r, "POST", "/chat", gin.H{"provider": "city-fake", "messages": msgs, "schema": ref})
+		if w.Code != status {
+			t.Errorf("schema %q: status %d, want %d: %s", ref, w.Code, status, w.Body)
+		}
+	}
+	w := do(t, r, "POST", "/chat", gin.H{"provider": "city-fake", "messages": msgs,
+		"schema": gin.H{"$ref": "file:///etc/passwd"}})
+	if w.Code != 400 {
+		t.Errorf("inline external ref: status %d", w.Code)
+	}
+}
+
+func TestChatSchemaStream(t *testing.T) {
+	provider.Register("city-stream", func(string) (provider.Provider, error) { return &cityModel{}, nil })
+	r := newTestRouter(t)
+	msgs := []gin.H{{"role": "user", "content": "Describe Tokyo."}}
+
+	for _, mode := range []string{"snapshot", "patch"} {
+		w := do(t, r, "POST", "/chat", gin.H{"provider": "city-stream", "messages": msgs,
+			"schema": citySchema, "stream": true, "stream_mode": mode})
+		if w.Code != 200 || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+			t.Fatalf("%s: status %d %s: %s", mode, w.Code, w.Header().Get("Content-Type"), w.Body)
+		}
+		var (
+			partials int
+			result   ChatResponse
+			names    []string
+		)
+		for _, ev := range strings.Split(w.Body.String(), "\n\n") {
+			if data, ok := strings.CutPrefix(ev, "event: "+mode+"\ndata: "); ok {
+				partials++
+				var p struct {
+					Attempt int                      `json:"attempt"`
+					Value   map[string]interface{}   `json:"value"`
+					Patch   []map[string]interface{} `json:"patch"`
+				}
+				if err := json.Unmarshal([]byte(data), &p); err != nil || p.Attempt != 1 {
+					t.Fatalf("%s event %q: %v", mode, data, err)
+				}
+				if mode == "snapshot" && p.Value["name"] != nil {
+					names = append(names, p.Value["name"].(string))
+				}
+				if mode == "patch" && len(p.Patch) == 0 {
+					t.Errorf("empty patch event")
+				}
+			}
+			if data, ok := strings.CutPrefix(ev, "event: result\ndata: "); ok {
+				if err := json.Unmarshal([]byte(data), &result); err != nil {
+					t.Fatalf("result %q: %v", data, err)
+				}
+			}
+		}
+		if partials < 3 {
+			t.Errorf("%s: only %d partial events: %s", mode, partials, w.Body)
+		}
+		obj, _ := result.JSON.(map[string]interface{})
+		if obj["country"] != "Japan" || result.Structured == nil || result.Structured.Attempts != 1 {
+			t.Errorf("%s: result = %+v", mode, result)
+		}
+		if mode == "snapshot" && (len(names) < 2 || names[0] == "Tokyo") {
+			t.Errorf("name did not stream incrementally: %v", names)
+		}
+		body := w.Body.String()
+		if !strings.Contains(body, "event: usage") || !strings.Contains(body, "event: done") || strings.Contains(body, "event: error") {
+			t.Errorf("%s: body = %s", mode, body)
+		}
+	}
+
+	if w := do(t, r, "POST", "/chat", gin.H{"provider": "city-stream", "messages": msgs,
+		"schema": citySchema, "stream": true, "stream_mode": "diff"}); w.Code != 400 {
+		t.Errorf("unknown stream_mode: status %d", w.Code)
+	}
+
+	// 每次输出都缺少 population：以带过程报告的 event: error 结束，然后 done
+	strict := gin.H{"type": "object", "properties": citySchema["properties"], "required": []string{"name", "population"}}
+	w := do(t, r, "POST", "/chat", gin.H{"provider": "city-stream", "messages": msgs, "schema": strict, "stream": true})
+	body := w.Body.String()
+	i := strings.Index(body, "event: error\ndata: ")
+	if i < 0 || !strings.HasSuffix(body, "event: done\n\n") || strings.Contains(body, "event: result") {
+		t.Fatalf("failed stream:\n%s", body)
+	}
+	var failed struct {
+		Error      string                 `json:"error"`
+		Structured *core.StructuredReport `json:"structured"`
+	}
+	data, _, _ := strings.Cut(body[i+len("event: error\ndata: "):], "\n\n")
+	if err := json.Unmarshal([]byte(data), &failed); err != nil || failed.Error == "" || failed.Structured == nil {
+		t.Fatalf("error event %q: %v", data, err)
+	}
+	if h := failed.Structured.History; failed.Structured.Attempts < 2 || len(h) == 0 || h[len(h)-1].Result != "schema_mismatch" {
+		t.Errorf("report = %+v", failed.Structured)
+	}
+}
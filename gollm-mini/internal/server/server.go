@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
@@ -16,8 +18,11 @@ import (
 
 	"gollm-mini/internal/cache"
 	"gollm-mini/internal/core"
+	"gollm-mini/internal/helper"
 	"gollm-mini/internal/memory"
 	"gollm-mini/internal/optimizer"
+	"gollm-mini/internal/provider"
+	"gollm-mini/internal/schema"
 	"gollm-mini/internal/template"
 	"gollm-mini/internal/types"
 )
@@ -31,22 +36,81 @@ type ChatRequest struct {
 	System    string            `json:"system"`
 	Provider  string            `json:"provider" default:"ollama"`
 	Model     string            `json:"model"    default:"llama3"`
-	Schema    string            `json:"schema"`
+	Fallbacks []string          `json:"fallbacks,omitempty"` // 备用目标 "provider:model"，按顺序尝试
+	Schema    json.RawMessage   `json:"schema,omitempty"`    // 内联 schema 对象，或已注册的名称 "name" / "name@version"
 	Stream    bool              `json:"stream,omitempty"`
-	SessionID string            `json:"session_id"` // 新增：对话记忆
+	// StreamMode 流式结构化输出的事件格式：snapshot（默认，完整的部分对象）/ patch（JSON Patch 增量）
+	StreamMode string       `json:"stream_mode,omitempty"`
+	SessionID  string       `json:"session_id"` // 新增：对话记忆
+	Tools      []types.Tool `json:"tools,omitempty"`
+	Images     []string     `json:"images,omitempty"` // URL / data URL / base64，附加到最后一条 user 消息
+
+	types.GenerateOptions // temperature / top_p / max_tokens / stop / seed
 }
 
 type ChatResponse struct {
-	Text   string      `json:"text,omitempty"`
-	JSON   interface{} `json:"json,omitempty"`
-	Usage  types.Usage `json:"usage"`
-	ErrMsg string      `json:"error,omitempty"`
+	Text      string           `json:"text,omitempty"`
+	JSON      interface{}      `json:"json,omitempty"`
+	ToolCalls []types.ToolCall `json:"tool_calls,omitempty"`
+	Usage     types.Usage      `json:"usage"`
+	// Structured 结构化模式的尝试报告（次数、每次的错误与修复情况）
+	Structured *core.StructuredReport `json:"structured,omitempty"`
+	Provider   string                 `json:"provider,omitempty"` // 实际服务的目标（可能是备用目标）
+	Model      string                 `json:"model,omitempty"`
+	ErrMsg     string                 `json:"error,omitempty"`
+}
+
+// StreamUsage SSE 结束前发送的 usage 事件
+type StreamUsage struct {
+	Provider         string  `json:"provider"`
+	Model            string  `json:"model"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+}
+
+type EmbeddingsRequest struct {
+	Provider string   `json:"provider"`
+	Model    string   `json:"model" binding:"required"` // 不回退到 chat 默认模型：embedding 需要专门的模型
+	Input    []string `json:"input" binding:"required"`
+}
+
+type EmbeddingsResponse struct {
+	Embeddings [][]float32 `json:"embeddings"`
+	Usage      types.Usage `json:"usage"`
+	CostUSD    float64     `json:"cost_usd"`
+	ErrMsg     string      `json:"error,omitempty"`
 }
 
 /* ---------- bootstrap ---------- */
 
 func Run(ctx context.Context, addr string) error {
+	tplStore, err := template.Open("templates.db")
+	if err != nil {
+		return err
+	}
+	schemas, err := schema.Open("schemas.db")
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           NewRouter(tplStore, schemas),
+		ReadHeaderTimeout: 15 * time.Second,
+		WriteTimeout:      300 * time.Second,
+		IdleTimeout:       120 * time.Second,
+	}
+
+	go func() { <-ctx.Done(); _ = srv.Shutdown(context.Background()) }()
+	return srv.ListenAndServe()
+}
+
+// NewRouter 注册全部路由；测试可直接配合 httptest 使用
+func NewRouter(tplStore *template.Store, schemas *schema.Store) *gin.Engine {
 	r := gin.Default()
+	r.UseRawPath = true // 模型名可能含 "/"（如 hf.co/org/repo），需写成 %2F
 
 	r.Use(func(c *gin.Context) {
 		defer func() {
@@ -58,17 +122,26 @@ func Run(ctx context.Context, addr string) error {
 		c.Next()
 	})
 
-	tplStore, err := template.Open("templates.db")
-	if err != nil {
-		return err
-	}
-
 	r.GET("/health", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	r.GET("/health/live", handleLive)
+	r.GET("/health/ready", func(c *gin.Context) { handleReady(c, tplStore, schemas) })
 	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
 	chat := r.Group("/chat")
 	{
-		chat.POST("", func(c *gin.Context) { handleChat(c, tplStore) })
+		chat.POST("", func(c *gin.Context) { handleChat(c, tplStore, schemas) })
+	}
+
+	r.POST("/embeddings", handleEmbeddings)
+
+	prov := r.Group("/providers")
+	{
+		prov.GET("", handleProviders)
+		prov.GET("/:name/models", handleProviderModels) // ?refresh=true 跳过缓存
+		prov.POST("/:name/models", handlePullModel)     // SSE 推送拉取进度
+		prov.GET("/:name/models/:model", handleShowModel)
+		prov.DELETE("/:name/models/:model", handleDeleteModel)
+		prov.POST("/:name/models/:model/keepalive", handleKeepAlive)
 	}
 
 	tpl := r.Group("/template")
@@ -80,6 +153,16 @@ func Run(ctx context.Context, addr string) error {
 		tpl.DELETE("/:name/:ver", func(c *gin.Context) { handleTplDel(c, tplStore) })
 	}
 
+	sch := r.Group("/schemas")
+	{
+		sch.POST("", func(c *gin.Context) { handleSchemaSave(c, schemas) })
+		sch.GET("", func(c *gin.Context) { handleSchemaList(c, schemas) })
+		sch.GET("/:name", func(c *gin.Context) { handleSchemaLatestOrVersions(c, schemas) }) // ?all=1 列出全部版本
+		sch.GET("/:name/:ver", func(c *gin.Context) { handleSchemaGet(c, schemas) })
+		sch.DELETE("/:name", func(c *gin.Context) { handleSchemaDelete(c, schemas) }) // 删除全部版本
+		sch.DELETE("/:name/:ver", func(c *gin.Context) { handleSchemaDelete(c, schemas) })
+	}
+
 	opt := r.Group("/optimizer")
 	{
 		opt.POST("", func(c *gin.Context) { handleOptimize(c, tplStore) })
@@ -97,37 +180,84 @@ func Run(ctx context.Context, addr string) error {
 		mem.DELETE("/:sid", handleMemoryDelete) // DELETE /memory/{sid}
 	}
 
-	srv := &http.Server{
-		Addr:              addr,
-		Handler:           r,
-		ReadHeaderTimeout: 15 * time.Second,
-		WriteTimeout:      300 * time.Second,
-		IdleTimeout:       120 * time.Second,
+	admin := r.Group("/admin")
+	{
+		admin.GET("/breakers", func(c *gin.Context) { c.JSON(200, core.Breakers()) })
 	}
-
-	go func() { <-ctx.Done(); _ = srv.Shutdown(context.Background()) }()
-	return srv.ListenAndServe()
+	return r
 }
 
 /* ---------- chat ---------- */
 
-func handleChat(c *gin.Context, tplStore *template.Store) {
+// parseImages 解析 images 字段并校验消息中的图片片段，非法 base64 / URL 在绑定阶段即返回 400
+func parseImages(req ChatRequest) ([]types.ContentPart, error) {
+	for _, m := range req.Messages {
+		for _, p := range m.Parts {
+			if err := p.Validate(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	imgs := make([]types.ContentPart, len(req.Images))
+	for i, s := range req.Images {
+		p, err := types.ImagePart(s)
+		if err != nil {
+			return nil, fmt.Errorf("images[%d]: %w", i, err)
+		}
+		imgs[i] = p
+	}
+	return imgs, nil
+}
+
+func handleChat(c *gin.Context, tplStore *template.Store, schemas *schema.Store) {
 	var req ChatRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
 
-	llm, err := core.New(req.Provider, req.Model)
+	imgs, err := parseImages(req)
 	if err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
+	if req.StreamMode != "" && req.StreamMode != "snapshot" && req.StreamMode != "patch" {
+		c.JSON(400, gin.H{"error": "stream_mode must be snapshot or patch"})
+		return
+	}
+	var structured json.RawMessage
+	if hasSchema(req.Schema) {
+		sc, status, err := resolveSchema(req.Schema, schemas)
+		if err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		structured = sc
+	}
+
+	targets := []core.Target{{Provider: req.Provider, Model: req.Model}}
+	for _, s := range req.Fallbacks {
+		t, err := core.ParseTarget(s)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		targets = append(targets, t)
+	}
+	llm, err := core.NewChain(targets...)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := llm.SetOptions(req.GenerateOptions); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
 
 	/* ① 读取历史 */
 	var history []types.Message
 	if req.SessionID != "" {
-		history, err = memory.Load(req.SessionID)
+		history, err = memory.Load(req.SessionID, llm.Tokenizer())
 		if err != nil {
 			c.JSON(500, gin.H{"error": err.Error()})
 			return
@@ -155,53 +285,256 @@ func handleChat(c *gin.Context, tplStore *template.Store) {
 		c.JSON(400, gin.H{"error": "no messages or template provided"})
 		return
 	}
+	msgs = helper.AttachImages(msgs, imgs)
+
+	/* ③ 工具调用：单轮返回 tool_calls，由客户端执行后回填 tool 消息 */
+	if len(req.Tools) > 0 {
+		msg, res, err := llm.GenerateWithTools(c, msgs, req.Tools)
+		c.JSON(200, servedBy(res.Served, ChatResponse{Text: msg.Content, ToolCalls: msg.ToolCalls, Usage: res.Usage, ErrMsg: errMsg(err)}))
+		return
+	}
 
-	/* ③ 非流式 & 无 schema */
-	if !req.Stream && req.Schema == "" {
-		text, usage, err := llm.Generate(c, msgs)
-		c.JSON(200, ChatResponse{Text: text, Usage: usage, ErrMsg: errMsg(err)})
+	/* ④ 非流式 & 无 schema */
+	if !req.Stream && structured == nil {
+		res, err := llm.Generate(c, msgs)
+		c.JSON(200, servedBy(res.Served, ChatResponse{Text: res.Text, Usage: res.Usage, ErrMsg: errMsg(err)}))
 
 		if req.SessionID != "" && err == nil {
 			_ = memory.Append(req.SessionID, []types.Message{
 				{Role: types.RoleUser, Content: msgs[len(msgs)-1].Content},
-				{Role: types.RoleAssistant, Content: text},
+				{Role: types.RoleAssistant, Content: res.Text},
 			})
 		}
 		return
 	}
 
-	/* ④ 结构化 JSON */
-	if req.Schema != "" {
+	/* ⑤ 结构化 JSON */
+	if structured != nil && req.Stream {
+		streamStructured(c, llm, msgs, structured, req.StreamMode == "patch")
+		return
+	}
+	if structured != nil {
 		var out map[string]interface{}
-		usage, err := llm.StructuredGenerate(c, msgs, req.Schema, &out)
-		c.JSON(200, ChatResponse{JSON: out, Usage: usage, ErrMsg: errMsg(err)})
+		rep, err := llm.StructuredGenerateSchema(c, msgs, structured, &out)
+		c.JSON(200, servedBy(rep.Served, ChatResponse{JSON: out, Usage: rep.Usage, Structured: &rep, ErrMsg: errMsg(err)}))
 		return
 	}
 
-	/* ⑤ 流式 SSE */
+	/* ⑥ 流式 SSE */
 	c.Writer.Header().Set("Content-Type", "text/event-stream")
 	c.Writer.Header().Set("Cache-Control", "no-cache")
 	flusher, _ := c.Writer.(http.Flusher)
 
-	var buf bytes.Buffer
-	_, err = llm.Stream(c, msgs, func(ch types.Chunk) {
+	res, err := llm.Stream(c, msgs, func(ch types.Chunk) {
 		_ = writeSSE(c.Writer, "data", ch.Content)
-		buf.WriteString(ch.Content)
 		flusher.Flush()
 	})
-	_ = writeSSE(c.Writer, "event", "done")
-	if err != nil {
-		_ = writeSSE(c.Writer, "error", err.Error())
+	if err == nil {
+		b, _ := json.Marshal(streamUsage(res.Served, res.Usage))
+		_ = writeSSEEvent(c.Writer, "usage", string(b))
+	} else {
+		_ = writeSSEError(c.Writer, gin.H{"error": err.Error(), "provider": res.Served.Provider, "model": res.Served.Model})
 	}
+	_ = writeSSE(c.Writer, "event", "done")
 
 	if req.SessionID != "" && err == nil {
 		_ = memory.Append(req.SessionID, []types.Message{
 			{Role: types.RoleUser, Content: msgs[len(msgs)-1].Content},
-			{Role: types.RoleAssistant, Content: buf.String()},
+			{Role: types.RoleAssistant, Content: res.Text},
 		})
 	}
 }
 
+// streamStructured 流式结构化输出：每当部分对象变化时推送 snapshot（或 patch）事件，
+// 结束时推送校验后的 result 与 usage 事件，失败时推送带过程报告的 error 事件，最后是 done
+func streamStructured(c *gin.Context, llm *core.LLM, msgs []types.Message, schema json.RawMessage, patch bool) {
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	flusher, _ := c.Writer.(http.Flusher)
+
+	var out map[string]interface{}
+	rep, err := llm.StructuredStreamSchema(c.Request.Context(), msgs, schema, &out, func(p core.StructuredPartial) {
+		event, payload := "snapshot", gin.H{"attempt": p.Attempt, "value": p.Value}
+		if patch {
+			event, payload = "patch", gin.H{"attempt": p.Attempt, "patch": p.Patch}
+		}
+		b, _ := json.Marshal(payload)
+		_ = writeSSEEvent(c.Writer, event, string(b))
+		flusher.Flush()
+	})
+	if err == nil {
+		b, _ := json.Marshal(servedBy(rep.Served, ChatResponse{JSON: out, Usage: rep.Usage, Structured: &rep}))
+		_ = writeSSEEvent(c.Writer, "result", string(b))
+
+		b, _ = json.Marshal(streamUsage(rep.Served, rep.Usage))
+		_ = writeSSEEvent(c.Writer, "usage", string(b))
+	} else {
+		// 报告里有每次尝试的错误与原始输出，客户端可据此判断失败原因
+		_ = writeSSEError(c.Writer, gin.H{"error": err.Error(), "structured": rep})
+	}
+	_ = writeSSE(c.Writer, "event", "done")
+	flusher.Flush()
+}
+
+/* ---------- embeddings ---------- */
+
+func handleEmbeddings(c *gin.Context) {
+	var req EmbeddingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	llm, err := core.New(req.Provider, req.Model)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	vecs, usage, err := llm.Embed(c, req.Input)
+	c.JSON(200, EmbeddingsResponse{Embeddings: vecs, Usage: usage, CostUSD: llm.Target().Cost(usage), ErrMsg: errMsg(err)})
+}
+
+/* ---------- providers ---------- */
+
+func handleProviders(c *gin.Context) {
+	names := provider.Names()
+	out := make([]provider.Info, 0, len(names))
+	for _, n := range names {
+		info, err := provider.Describe(n)
+		if err != nil {
+			log.Printf("[WARN] describe provider %s: %v", n, err)
+			continue
+		}
+		out = append(out, info)
+	}
+	c.JSON(200, out)
+}
+
+func handleProviderModels(c *gin.Context) {
+	refresh := c.Query("refresh") == "true"
+	models, err := provider.Models(c, c.Param("name"), refresh)
+	switch {
+	case errors.Is(err, provider.ErrNotRegistered):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, provider.ErrNoModelList):
+		c.JSON(501, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(502, gin.H{"error": err.Error()})
+	default:
+		c.JSON(200, models)
+	}
+}
+
+/* ---------- model management ---------- */
+
+type PullModelRequest struct {
+	Model string `json:"model" binding:"required"`
+}
+
+type KeepAliveRequest struct {
+	KeepAlive string `json:"keep_alive" binding:"required"` // "10m" / "0"（立即卸载）/ "-1"（永久常驻）
+}
+
+// modelManager 取出支持模型管理的 Provider；失败时已写好响应
+func modelManager(c *gin.Context) (provider.ModelManager, bool) {
+	p, err := provider.Get(c.Param("name"), "")
+	if err != nil {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	mm, ok := provider.As[provider.ModelManager](p)
+	if !ok {
+		c.JSON(501, gin.H{"error": "provider " + c.Param("name") + " does not support model management"})
+		return nil, false
+	}
+	return mm, true
+}
+
+// modelErrorStatus 模型管理错误对应的 HTTP 状态码
+func modelErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, provider.ErrModelNotFound):
+		return 404
+	case errors.Is(err, provider.ErrInvalidRequest):
+		return 400
+	}
+	return 502
+}
+
+func handlePullModel(c *gin.Context) {
+	var req PullModelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	mm, ok := modelManager(c)
+	if !ok {
+		return
+	}
+
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	flusher, _ := c.Writer.(http.Flusher)
+
+	err := mm.PullModel(c.Request.Context(), req.Model, func(p types.PullProgress) {
+		b, _ := json.Marshal(p)
+		_ = writeSSEEvent(c.Writer, "progress", string(b))
+		flusher.Flush()
+	})
+	if err != nil {
+		// 失败时以 event: error 结束，不再发 done，客户端不会把失败当成完成
+		_ = writeSSEError(c.Writer, gin.H{"error": err.Error(), "status": modelErrorStatus(err)})
+		return
+	}
+	_ = writeSSE(c.Writer, "event", "done")
+}
+
+func handleShowModel(c *gin.Context) {
+	mm, ok := modelManager(c)
+	if !ok {
+		return
+	}
+	d, err := mm.ShowModel(c.Request.Context(), c.Param("model"))
+	if err != nil {
+		c.JSON(modelErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, d)
+}
+
+func handleDeleteModel(c *gin.Context) {
+	mm, ok := modelManager(c)
+	if !ok {
+		return
+	}
+	if err := mm.DeleteModel(c.Request.Context(), c.Param("model")); err != nil {
+		c.JSON(modelErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(204)
+}
+
+func handleKeepAlive(c *gin.Context) {
+	var req KeepAliveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	d, err := helper.ParseKeepAlive(req.KeepAlive)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	mm, ok := modelManager(c)
+	if !ok {
+		return
+	}
+	if err := mm.KeepAlive(c.Request.Context(), c.Param("model"), d); err != nil {
+		c.JSON(modelErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"model": c.Param("model"), "keep_alive": req.KeepAlive})
+}
+
 /* ---------- template CRUD ---------- */
 
 func handleTplSave(c *gin.Context, store *template.Store) {
@@ -361,6 +694,37 @@ func writeSSE(w http.ResponseWriter, field, data string) error {
 	_, err := w.Write([]byte(field + ": " + data + "\n\n"))
 	return err
 }
+
+// servedBy 在响应中标注实际服务的目标
+func servedBy(t core.Target, resp ChatResponse) ChatResponse {
+	resp.Provider, resp.Model = t.Provider, t.Model
+	return resp
+}
+
+// streamUsage 流式结束时的 usage 事件，按实际服务的目标计费
+func streamUsage(t core.Target, u types.Usage) StreamUsage {
+	return StreamUsage{
+		Provider:         t.Provider,
+		Model:            t.Model,
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.Total(),
+		CostUSD:          t.Cost(u),
+	}
+}
+
+// writeSSEEvent 写出带名字的事件：event: <name>\ndata: <data>
+func writeSSEEvent(w http.ResponseWriter, event, data string) error {
+	_, err := w.Write([]byte("event: " + event + "\ndata: " + data + "\n\n"))
+	return err
+}
+
+// writeSSEError 写出 event: error，data 为 JSON 负载
+func writeSSEError(w http.ResponseWriter, payload any) error {
+	b, _ := json.Marshal(payload)
+	return writeSSEEvent(w, "error", string(b))
+}
+
 func errMsg(e error) string {
 	if e != nil {
 		return e.Error()
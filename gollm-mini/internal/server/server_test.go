@@ -0,0 +1,276 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"gollm-mini/internal/provider/replay"
+	"gollm-mini/internal/schema"
+	"gollm-mini/internal/template"
+
+	_ "gollm-mini/internal/provider/ollama"
+	_ "gollm-mini/internal/provider/openai"
+)
+
+// 默认回放仓库根目录的 testdata/cassettes（core / server / optimizer 共用）；
+// GOLLM_REPLAY=record go test 可对真实后端重新录制。
+// memory / cache 的 bbolt 文件写在当前目录，整个包在临时目录中运行。
+func TestMain(m *testing.M) {
+	mode := replay.ModeReplay
+	if v := os.Getenv("GOLLM_REPLAY"); v != "" {
+		var err error
+		if mode, err = replay.ParseMode(v); err != nil {
+			panic(err)
+		}
+	}
+	cassettes, err := filepath.Abs("../../testdata/cassettes")
+	if err != nil {
+		panic(err)
+	}
+	if err := replay.Install(cassettes, mode, "ollama", "openai"); err != nil {
+		panic(err)
+	}
+
+	dir, err := os.MkdirTemp("", "gollm-server-test")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+	gin.SetMode(gin.TestMode)
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func newTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	store, err := template.Open(filepath.Join(t.TempDir(), "templates.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	schemas, err := schema.Open(filepath.Join(t.TempDir(), "schemas.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = store.Close(); _ = schemas.Close() })
+	return NewRouter(store, schemas)
+}
+
+func do(t *testing.T, r http.Handler, method, path string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode %q: %v", w.Body.String(), err)
+	}
+}
+
+const capital = "What is the capital of France? Answer in one sentence."
+
+func TestChat(t *testing.T) {
+	r := newTestRouter(t)
+	w := do(t, r, "POST", "/chat", gin.H{
+		"provider": "ollama", "model": "llama3",
+		"messages": []gin.H{{"role": "user", "content": capital}},
+	})
+	if w.Code != 200 {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var resp ChatResponse
+	decode(t, w, &resp)
+	if resp.ErrMsg != "" || !strings.Contains(resp.Text, "Paris") {
+		t.Fatalf("resp = %+v", resp)
+	}
+	if resp.Provider != "ollama" || resp.Model != "llama3" || resp.Usage.Total() == 0 {
+		t.Errorf("resp = %+v, want served target and usage", resp)
+	}
+}
+
+func TestChatStream(t *testing.T) {
+	r := newTestRouter(t)
+	w := do(t, r, "POST", "/chat", gin.H{
+		"provider": "ollama", "model": "llama3", "stream": true,
+		"messages": []gin.H{{"role": "user", "content": capital}},
+	})
+	body := w.Body.String()
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type %q", ct)
+	}
+
+	var text strings.Builder
+	var usage StreamUsage
+	for _, ev := range strings.Split(body, "\n\n") {
+		switch {
+		case strings.HasPrefix(ev, "data: "):
+			text.WriteString(strings.TrimPrefix(ev, "data: "))
+		case strings.HasPrefix(ev, "event: usage\ndata: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(ev, "event: usage\ndata: ")), &usage); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if !strings.Contains(text.String(), "Paris") {
+		t.Errorf("streamed text %q", text.String())
+	}
+	if usage.Provider != "ollama" || usage.TotalTokens == 0 {
+		t.Errorf("usage event = %+v", usage)
+	}
+	if !strings.Contains(body, "event: done") || strings.Contains(body, "event: error") {
+		t.Errorf("stream did not finish cleanly:\n%s", body)
+	}
+}
+
+// 流式失败：以带 JSON 负载的 event: error 结束，然后 done（与结构化流式、拉取模型一致）
+func TestChatStreamError(t *testing.T) {
+	if os.Getenv("GOLLM_REPLAY") != "" {
+		t.Skip("only meaningful when replaying")
+	}
+	r := newTestRouter(t)
+	w := do(t, r, "POST", "/chat", gin.H{
+		"provider": "ollama", "model": "llama3", "stream": true,
+		"messages": []gin.H{{"role": "user", "content": "a prompt nobody recorded"}},
+	})
+	body := w.Body.String()
+	i := strings.Index(body, "event: error\ndata: ")
+	if i < 0 || !strings.HasSuffix(body, "event: done\n\n") || strings.Contains(body, "event: usage") {
+		t.Fatalf("stream did not end with an error event:\n%s", body)
+	}
+	data, _, _ := strings.Cut(body[i+len("event: error\ndata: "):], "\n\n")
+	var ev struct{ Error, Provider, Model string }
+	if err := json.Unmarshal([]byte(data), &ev); err != nil {
+		t.Fatalf("error payload %q: %v", data, err)
+	}
+	if !strings.Contains(ev.Error, replay.ErrNoCassette.Error()) || ev.Provider != "ollama" || ev.Model != "llama3" {
+		t.Errorf("error event = %+v", ev)
+	}
+}
+
+func TestChatFallback(t *testing.T) {
+	r := newTestRouter(t)
+	w := do(t, r, "POST", "/chat", gin.H{
+		"provider": "openai", "model": "gpt-4o-mini",
+		"fallbacks": []string{"ollama:llama3"},
+		"messages":  []gin.H{{"role": "user", "content": "Say hello."}},
+	})
+	var resp ChatResponse
+	decode(t, w, &resp)
+	if resp.ErrMsg != "" || resp.Provider != "ollama" {
+		t.Fatalf("resp = %+v, want answer from fallback", resp)
+	}
+}
+
+func TestChatSessionMemory(t *testing.T) {
+	r := newTestRouter(t)
+	for i, q := range []string{"My name is Ada.", "What is my name?"} {
+		w := do(t, r, "POST", "/chat", gin.H{
+			"provider": "ollama", "model": "llama3", "session_id": "replay-session",
+			"messages": []gin.H{{"role": "user", "content": q}},
+		})
+		var resp ChatResponse
+		decode(t, w, &resp)
+		if resp.ErrMsg != "" {
+			t.Fatalf("turn %d: %s", i, resp.ErrMsg)
+		}
+		if i == 1 && !strings.Contains(resp.Text, "Ada") {
+			t.Errorf("second turn = %q, want history to carry the name", resp.Text)
+		}
+	}
+	if w := do(t, r, "DELETE", "/memory/replay-session", nil); w.Code != 204 {
+		t.Errorf("delete memory: %d", w.Code)
+	}
+}
+
+func TestChatTemplate(t *testing.T) {
+	r := newTestRouter(t)
+	w := do(t, r, "POST", "/template", template.Template{
+		Name: "explain", Version: 1, Content: "Explain {{.input}} in one short sentence.", Vars: []string{"input"},
+	})
+	if w.Code != 200 {
+		t.Fatalf("save template: %d %s", w.Code, w.Body)
+	}
+
+	w = do(t, r, "POST", "/chat", gin.H{
+		"provider": "ollama", "model": "llama3",
+		"tpl": "explain", "vars": gin.H{"input": "Go"},
+	})
+	var resp ChatResponse
+	decode(t, w, &resp)
+	if resp.ErrMsg != "" || !strings.HasPrefix(resp.Text, "Go is") {
+		t.Fatalf("resp = %+v", resp)
+	}
+}
+
+func TestChatBadRequest(t *testing.T) {
+	r := newTestRouter(t)
+	if w := do(t, r, "POST", "/chat", gin.H{"provider": "ollama"}); w.Code != 400 {
+		t.Errorf("no messages: status %d", w.Code)
+	}
+	if w := do(t, r, "POST", "/chat", gin.H{
+		"provider": "nope", "messages": []gin.H{{"role": "user", "content": "hi"}},
+	}); w.Code != 400 {
+		t.Errorf("unknown provider: status %d", w.Code)
+	}
+	if w := do(t, r, "POST", "/chat", gin.H{
+		"provider": "ollama", "messages": []gin.H{{"role": "user", "content": "hi"}}, "images": []string{"not base64!"},
+	}); w.Code != 400 {
+		t.Errorf("bad image: status %d", w.Code)
+	}
+	if w := do(t, r, "POST", "/chat", gin.H{
+		"provider": "ollama", "messages": []gin.H{{"role": "user", "parts": []gin.H{{"type": "image", "image_url": "file:///etc/passwd"}}}},
+	}); w.Code != 400 {
+		t.Errorf("bad image part: status %d", w.Code)
+	}
+}
+
+func TestEmbeddings(t *testing.T) {
+	r := newTestRouter(t)
+	w := do(t, r, "POST", "/embeddings", gin.H{
+		"provider": "openai", "model": "text-embedding-3-small",
+		"input": []string{"hello world", "goodbye"},
+	})
+	var resp EmbeddingsResponse
+	decode(t, w, &resp)
+	if resp.ErrMsg != "" || len(resp.Embeddings) != 2 || len(resp.Embeddings[0]) == 0 {
+		t.Fatalf("resp = %+v", resp)
+	}
+	if resp.CostUSD <= 0 {
+		t.Errorf("cost = %v, want priced embedding model", resp.CostUSD)
+	}
+}
+
+func TestEmbeddingsRequiresModel(t *testing.T) {
+	r := newTestRouter(t)
+	if w := do(t, r, "POST", "/embeddings", gin.H{"provider": "openai", "input": []string{"hi"}}); w.Code != 400 {
+		t.Errorf("status %d, want 400 without model", w.Code)
+	}
+}
+
+func TestProviderModelsUnknown(t *testing.T) {
+	r := newTestRouter(t)
+	if w := do(t, r, "GET", "/providers/nope/models", nil); w.Code != 404 {
+		t.Errorf("status %d, want 404", w.Code)
+	}
+}
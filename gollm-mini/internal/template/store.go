@@ -30,6 +30,14 @@ func Open(path string) (*Store, error) {
 	return &Store{db: db}, err
 }
 
+// Ping 检查 templates.db 能否开启读事务（用于就绪检查）
+func (s *Store) Ping() error {
+	return s.db.View(func(tx *bolt.Tx) error { return nil })
+}
+
+// Close 释放 bbolt 文件锁
+func (s *Store) Close() error { return s.db.Close() }
+
 func (s *Store) Save(tpl Template) error {
 	return s.db.Update(func(tx *bolt.Tx) error {
 		b, _ := tx.CreateBucketIfNotExists([]byte(bucket))
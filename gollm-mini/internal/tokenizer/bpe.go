@@ -0,0 +1,147 @@
+package tokenizer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+)
+
+// 预分词正则。原版模式里的 `\s+(?!\S)` 在 RE2 中不可用，
+// 这里把最后一个分支写成捕获组 `(\s+)`，由 split 手动实现“留下最后一个空白给下个词”。
+const (
+	patCL100K = `\A(?:(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|(\s+))`
+	patO200K  = `\A(?:[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+		`|[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+		`|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|(\s+))`
+)
+
+// BPE 是 tiktoken 风格的字节级 BPE（rank 越小越先合并）
+type BPE struct {
+	name  string
+	ranks map[string]int
+	pre   *regexp.Regexp
+}
+
+// LoadTiktoken 读取 tiktoken 词表：每行 "<base64 token> <rank>"
+func LoadTiktoken(name, path string) (*BPE, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ranks := make(map[string]int, 200_000)
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		tok, rank, ok := bytes.Cut(bytes.TrimSpace(sc.Bytes()), []byte(" "))
+		if !ok {
+			continue
+		}
+		b, err := base64.StdEncoding.DecodeString(string(tok))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		r, err := strconv.Atoi(string(rank))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		ranks[string(b)] = r
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("%s: empty vocabulary", path)
+	}
+
+	pat := patCL100K
+	if name == "o200k_base" {
+		pat = patO200K
+	}
+	return NewBPE(name, ranks, pat), nil
+}
+
+// NewBPE 由 rank 表和预分词正则构造（pattern 最后一个分支须为捕获组 (\s+)）
+func NewBPE(name string, ranks map[string]int, pattern string) *BPE {
+	return &BPE{name: name, ranks: ranks, pre: regexp.MustCompile(pattern)}
+}
+
+func (b *BPE) Name() string { return b.name }
+
+func (b *BPE) Count(text string) int {
+	n := 0
+	for _, piece := range b.split(text) {
+		n += len(b.encodePiece([]byte(piece)))
+	}
+	return n
+}
+
+// Encode 返回 token id
+func (b *BPE) Encode(text string) []int {
+	var ids []int
+	for _, piece := range b.split(text) {
+		ids = append(ids, b.encodePiece([]byte(piece))...)
+	}
+	return ids
+}
+
+// split 预分词
+func (b *BPE) split(s string) []string {
+	var out []string
+	for len(s) > 0 {
+		loc := b.pre.FindStringSubmatchIndex(s)
+		end := 0
+		if loc != nil {
+			end = loc[1]
+		}
+		if end == 0 { // 理论上不会发生；保证前进
+			_, size := utf8.DecodeRuneInString(s)
+			end = size
+		}
+		// 纯空白分支：后面还有非空白时，把最后一个空白字符让给下一个词（等价 \s+(?!\S)）
+		if loc != nil && loc[2] >= 0 && end < len(s) {
+			if last, size := utf8.DecodeLastRuneInString(s[:end]); size < end && last != utf8.RuneError {
+				end -= size
+			}
+		}
+		out = append(out, s[:end])
+		s = s[end:]
+	}
+	return out
+}
+
+// encodePiece 在单个预分词片段上做 BPE 合并
+func (b *BPE) encodePiece(piece []byte) []int {
+	if r, ok := b.ranks[string(piece)]; ok {
+		return []int{r}
+	}
+
+	// parts 存放片段边界，每轮合并 rank 最小的相邻对
+	parts := make([]int, len(piece)+1)
+	for i := range parts {
+		parts[i] = i
+	}
+	for len(parts) > 2 {
+		best, at := -1, -1
+		for i := 0; i+2 < len(parts); i++ {
+			if r, ok := b.ranks[string(piece[parts[i]:parts[i+2]])]; ok && (best == -1 || r < best) {
+				best, at = r, i
+			}
+		}
+		if at == -1 {
+			break
+		}
+		parts = append(parts[:at+1], parts[at+2:]...)
+	}
+
+	ids := make([]int, 0, len(parts)-1)
+	for i := 0; i+1 < len(parts); i++ {
+		ids = append(ids, b.ranks[string(piece[parts[i]:parts[i+1]])])
+	}
+	return ids
+}
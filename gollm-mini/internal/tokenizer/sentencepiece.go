@@ -0,0 +1,124 @@
+package tokenizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const spaceMark = "▁" // SentencePiece 用 U+2581 表示空格
+
+// SentencePiece 读取 HF tokenizer.json（model.type=BPE）实现 Llama-2 / Mistral 计数
+type SentencePiece struct {
+	name         string
+	vocab        map[string]int
+	merges       map[string]int // "a b" → 优先级
+	byteFallback bool
+}
+
+// LoadSentencePiece 读取 HF tokenizer.json
+func LoadSentencePiece(name, path string) (*SentencePiece, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		Model struct {
+			Type         string          `json:"type"`
+			Vocab        map[string]int  `json:"vocab"`
+			Merges       json.RawMessage `json:"merges"`
+			ByteFallback bool            `json:"byte_fallback"`
+		} `json:"model"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if doc.Model.Type != "BPE" {
+		return nil, fmt.Errorf("%s: unsupported model type %q", path, doc.Model.Type)
+	}
+
+	// merges 有 ["a b", ...] 与 [["a","b"], ...] 两种写法
+	merges := map[string]int{}
+	var flat []string
+	if err := json.Unmarshal(doc.Model.Merges, &flat); err != nil {
+		var pairs [][2]string
+		if err := json.Unmarshal(doc.Model.Merges, &pairs); err != nil {
+			return nil, fmt.Errorf("%s: merges: %w", path, err)
+		}
+		for _, p := range pairs {
+			flat = append(flat, p[0]+" "+p[1])
+		}
+	}
+	for i, m := range flat {
+		merges[m] = i
+	}
+	return NewSentencePiece(name, doc.Model.Vocab, merges, doc.Model.ByteFallback), nil
+}
+
+// NewSentencePiece 由词表与合并表构造
+func NewSentencePiece(name string, vocab, merges map[string]int, byteFallback bool) *SentencePiece {
+	return &SentencePiece{name: name, vocab: vocab, merges: merges, byteFallback: byteFallback}
+}
+
+func (s *SentencePiece) Name() string { return s.name }
+
+func (s *SentencePiece) Count(text string) int {
+	if text == "" {
+		return 0
+	}
+	// 与 Llama 规范化一致：前置 ▁，空格替换为 ▁
+	norm := spaceMark + strings.ReplaceAll(text, " ", spaceMark)
+
+	n := 0
+	for _, word := range splitWords(norm) {
+		for _, sym := range s.merge(word) {
+			if _, ok := s.vocab[sym]; ok || !s.byteFallback {
+				n++
+			} else {
+				n += len(sym) // <0xXX> 逐字节回退
+			}
+		}
+	}
+	return n
+}
+
+// splitWords 以 ▁ 为词首切分（▁ 连续出现时保持在一起），合并不会跨越词边界
+func splitWords(s string) []string {
+	var (
+		words []string
+		start int
+		inMk  bool
+	)
+	for i, r := range s {
+		isMk := string(r) == spaceMark
+		if isMk && !inMk && i > start {
+			words = append(words, s[start:i])
+			start = i
+		}
+		inMk = isMk
+	}
+	return append(words, s[start:])
+}
+
+// merge 在一个词内按 merges 优先级反复合并相邻符号
+func (s *SentencePiece) merge(word string) []string {
+	var syms []string
+	for _, r := range word {
+		syms = append(syms, string(r))
+	}
+	for len(syms) > 1 {
+		best, at := -1, -1
+		for i := 0; i+1 < len(syms); i++ {
+			if r, ok := s.merges[syms[i]+" "+syms[i+1]]; ok && (best == -1 || r < best) {
+				best, at = r, i
+			}
+		}
+		if at == -1 {
+			break
+		}
+		syms[at] += syms[at+1]
+		syms = append(syms[:at+1], syms[at+2:]...)
+	}
+	return syms
+}
@@ -0,0 +1,166 @@
+// Package tokenizer 提供纯 Go 的 token 计数：按 Provider / 模型选择 BPE 词表
+// （tiktoken 格式的 cl100k / o200k / Llama-3，或 HF tokenizer.json 格式的
+// Llama-2 / Mistral SentencePiece），词表文件缺失时退回字符数估算。
+package tokenizer
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"gollm-mini/internal/types"
+)
+
+// Tokenizer 只负责计数；截断、Usage 估算都基于它
+type Tokenizer interface {
+	Name() string
+	Count(text string) int
+}
+
+// 对话格式开销（参考 OpenAI cookbook）：每条消息 3 个，回复引导 3 个
+const (
+	tokensPerMessage = 3
+	tokensPerName    = 1
+	tokensPerReply   = 3
+)
+
+// MessageTokens 单条消息的 token 数（含格式开销）
+func MessageTokens(t Tokenizer, m types.Message) int {
+	n := tokensPerMessage + t.Count(string(m.Role)) + t.Count(m.Text())
+	if m.Name != "" {
+		n += tokensPerName + t.Count(m.Name)
+	}
+	for _, tc := range m.ToolCalls {
+		n += t.Count(tc.Name) + t.Count(string(tc.Arguments))
+	}
+	return n
+}
+
+// CountMessages 整段对话作为 prompt 时的 token 数
+func CountMessages(t Tokenizer, msgs []types.Message) int {
+	n := tokensPerReply
+	for _, m := range msgs {
+		n += MessageTokens(t, m)
+	}
+	return n
+}
+
+// ---------------------------------------------------------------------
+// 近似计数：词表缺失时的兜底
+// ---------------------------------------------------------------------
+
+// Approx 按 4 字符 ≈ 1 token 估算
+type Approx struct{}
+
+func (Approx) Name() string { return "approx" }
+
+func (Approx) Count(s string) int {
+	n := utf8.RuneCountInString(s)
+	if n == 0 {
+		return 0
+	}
+	return (n + 3) / 4
+}
+
+// ---------------------------------------------------------------------
+// 词表选择 & 缓存
+// ---------------------------------------------------------------------
+
+// 词表名 → 文件名（位于 GOLLM_TOKENIZER_DIR，默认 ./tokenizers）
+var files = map[string]string{
+	"cl100k_base": "cl100k_base.tiktoken",
+	"o200k_base":  "o200k_base.tiktoken",
+	"llama3":      "llama3.tiktoken", // Meta 发布的 tokenizer.model 即 tiktoken 格式
+	"llama2":      "llama2.json",     // HF tokenizer.json
+	"mistral":     "mistral.json",
+}
+
+var (
+	mu     sync.Mutex
+	loaded = map[string]Tokenizer{}
+	warned = map[string]bool{} // 已提示过退回估算的 provider/model
+)
+
+// ForModel 按 Provider / 模型选择 tokenizer；词表加载失败时返回 Approx，
+// 每个模型首次退回时记录一条警告（截断与用量估算会因此不够精确）
+func ForModel(provider, model string) Tokenizer {
+	enc := Encoding(provider, model)
+	t := Load(enc)
+	if _, ok := t.(Approx); ok {
+		key := provider + "/" + model
+		mu.Lock()
+		if !warned[key] {
+			warned[key] = true
+			log.Printf("[tokenizer] WARN %s: no %s vocabulary, token counts are approximate (4 chars ≈ 1 token)", key, enc)
+		}
+		mu.Unlock()
+	}
+	return t
+}
+
+// Default 未知模型时使用的 tokenizer
+func Default() Tokenizer { return Load("cl100k_base") }
+
+// Encoding 推断模型使用的词表名
+func Encoding(provider, model string) string {
+	m := strings.ToLower(model)
+	switch {
+	case strings.Contains(m, "gpt-4o"), strings.Contains(m, "gpt-4.1"), strings.Contains(m, "gpt-5"),
+		strings.HasPrefix(m, "o1"), strings.HasPrefix(m, "o3"), strings.HasPrefix(m, "o4"):
+		return "o200k_base"
+	case strings.Contains(m, "gpt-4"), strings.Contains(m, "gpt-3.5"), strings.Contains(m, "text-embedding"):
+		return "cl100k_base"
+	case strings.Contains(m, "llama-3"), strings.Contains(m, "llama3"):
+		return "llama3"
+	case strings.Contains(m, "mistral"), strings.Contains(m, "mixtral"):
+		return "mistral"
+	case strings.Contains(m, "llama-2"), strings.Contains(m, "llama2"),
+		strings.Contains(m, "tinyllama"), strings.Contains(m, "zephyr"):
+		return "llama2"
+	}
+	// 未识别的模型：OpenAI / Anthropic 用 cl100k 近似，其余退回估算
+	switch provider {
+	case "openai", "anthropic":
+		return "cl100k_base"
+	}
+	return "approx"
+}
+
+// Load 按词表名加载（带缓存），失败时记录一次日志并返回 Approx
+func Load(encoding string) Tokenizer {
+	mu.Lock()
+	defer mu.Unlock()
+	if t, ok := loaded[encoding]; ok {
+		return t
+	}
+
+	var (
+		t   Tokenizer = Approx{}
+		err error
+	)
+	if file, ok := files[encoding]; ok {
+		path := filepath.Join(dir(), file)
+		switch filepath.Ext(file) {
+		case ".tiktoken":
+			t, err = LoadTiktoken(encoding, path)
+		case ".json":
+			t, err = LoadSentencePiece(encoding, path)
+		}
+		if err != nil {
+			log.Printf("[tokenizer] WARN %s unavailable (%v), falling back to approx", encoding, err)
+			t = Approx{}
+		}
+	}
+	loaded[encoding] = t
+	return t
+}
+
+func dir() string {
+	if d := os.Getenv("GOLLM_TOKENIZER_DIR"); d != "" {
+		return d
+	}
+	return "tokenizers"
+}
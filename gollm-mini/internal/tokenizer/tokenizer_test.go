@@ -0,0 +1,160 @@
+package tokenizer
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"gollm-mini/internal/types"
+)
+
+// testdata 下是手工构造的小词表，计数结果可以逐步推算：
+//   tiny.tiktoken：256 个单字节 + he / ll / hell / hello / " w" / or / " wor" / " world" / 12 / 123
+//   tiny.json：    ▁ h e l l o w r d 及 ▁h / ll / ▁he / ▁hell / ▁hello / or / ▁w / ▁wor，开启 byte_fallback
+
+func TestBPEGolden(t *testing.T) {
+	b, err := LoadTiktoken("cl100k_base", "testdata/tiny.tiktoken")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"hello world", 2},  // 两个片段都是完整 token
+		{"hello worlds", 5}, // " worlds" → " wor" l d s
+		{"1234567", 5},      // 数字三位一组：123 | 4 5 6 | 7
+		{"hi  there", 8},    // 连续空白留一个给下一个词：hi | " " | " " t he r e
+	}
+	for _, tc := range cases {
+		if got := b.Count(tc.text); got != tc.want {
+			t.Errorf("Count(%q) = %d, want %d", tc.text, got, tc.want)
+		}
+	}
+	if got, want := b.Encode("hello worlds"), []int{259, 262, 'l', 'd', 's'}; !slices.Equal(got, want) {
+		t.Errorf("Encode = %v, want %v", got, want)
+	}
+}
+
+func TestSentencePieceGolden(t *testing.T) {
+	s, err := LoadSentencePiece("llama2", "testdata/tiny.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"hello", 1},       // ▁hello
+		{"hello world", 4}, // ▁hello | ▁wor l d
+		{"hé", 3},          // ▁h + é 按 2 个字节回退
+	}
+	for _, tc := range cases {
+		if got := s.Count(tc.text); got != tc.want {
+			t.Errorf("Count(%q) = %d, want %d", tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestSentencePiecePairMerges(t *testing.T) {
+	raw, err := os.ReadFile("testdata/tiny.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Model map[string]any `json:"model"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatal(err)
+	}
+	// 新版 tokenizer.json 的 merges 写成 [["a","b"], ...]
+	var pairs [][]string
+	for _, m := range doc.Model["merges"].([]any) {
+		pairs = append(pairs, strings.SplitN(m.(string), " ", 2))
+	}
+	doc.Model["merges"] = pairs
+	path := filepath.Join(t.TempDir(), "pairs.json")
+	raw, _ = json.Marshal(doc)
+	if err := os.WriteFile(path, raw, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := LoadSentencePiece("mistral", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Count("hello world"); got != 4 {
+		t.Errorf("Count = %d, want 4", got)
+	}
+}
+
+// useDir 让 Load 从 dir 读取词表，并清空缓存
+func useDir(t *testing.T, dir string) *bytes.Buffer {
+	t.Helper()
+	t.Setenv("GOLLM_TOKENIZER_DIR", dir)
+	reset := func() {
+		mu.Lock()
+		loaded, warned = map[string]Tokenizer{}, map[string]bool{}
+		mu.Unlock()
+	}
+	reset()
+	t.Cleanup(reset)
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+func TestForModel(t *testing.T) {
+	dir := t.TempDir()
+	raw, err := os.ReadFile("testdata/tiny.tiktoken")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cl100k_base.tiktoken"), raw, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	logs := useDir(t, dir)
+
+	if tok := ForModel("openai", "gpt-4"); tok.Name() != "cl100k_base" || tok.Count("hello world") != 2 {
+		t.Errorf("gpt-4 tokenizer = %s", tok.Name())
+	}
+	if logs.Len() != 0 {
+		t.Errorf("unexpected log: %s", logs)
+	}
+
+	// 词表缺失 / 未知模型：退回估算并且每个模型只警告一次
+	for range 2 {
+		if tok := ForModel("openai", "gpt-4o"); tok.Name() != "approx" {
+			t.Errorf("gpt-4o tokenizer = %s, want approx", tok.Name())
+		}
+		ForModel("ollama", "phi3")
+	}
+	if n := strings.Count(logs.String(), "WARN openai/gpt-4o"); n != 1 {
+		t.Errorf("gpt-4o warned %d times:\n%s", n, logs)
+	}
+	if n := strings.Count(logs.String(), "WARN ollama/phi3"); n != 1 {
+		t.Errorf("phi3 warned %d times:\n%s", n, logs)
+	}
+}
+
+func TestCountMessages(t *testing.T) {
+	msgs := []types.Message{
+		{Role: types.RoleSystem, Content: "be brief"},
+		{Role: types.RoleUser, Content: "hello", Name: "bob"},
+	}
+	// 3（回复引导）+ 每条 3 + role + 内容（+ name 1 + 名字）
+	a := Approx{}
+	want := 3 + (3 + 2 + 2) + (3 + 1 + 2 + 1 + 1)
+	if got := CountMessages(a, msgs); got != want {
+		t.Errorf("CountMessages = %d, want %d", got, want)
+	}
+}
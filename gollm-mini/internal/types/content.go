@@ -0,0 +1,125 @@
+package types
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type PartType string
+
+const (
+	PartText  PartType = "text"
+	PartImage PartType = "image"
+)
+
+// ContentPart 是多模态消息的一个片段：文本或图片（URL / base64 二选一）
+type ContentPart struct {
+	Type      PartType `json:"type"`
+	Text      string   `json:"text,omitempty"`
+	ImageURL  string   `json:"image_url,omitempty"`  // http(s) 地址
+	ImageData string   `json:"image_data,omitempty"` // base64，不含 data: 前缀
+	MIMEType  string   `json:"mime_type,omitempty"`  // 为空时按内容嗅探
+}
+
+// ImagePart 解析 http(s) URL、data URL 或裸 base64 字符串；base64 非法时报错
+func ImagePart(s string) (ContentPart, error) {
+	s = strings.TrimSpace(s)
+	var p ContentPart
+	switch {
+	case strings.HasPrefix(s, "http://"), strings.HasPrefix(s, "https://"):
+		p = ContentPart{Type: PartImage, ImageURL: s}
+	case strings.HasPrefix(s, "data:"):
+		// data:image/png;base64,xxxx
+		meta, data, _ := strings.Cut(strings.TrimPrefix(s, "data:"), ",")
+		mime, _, _ := strings.Cut(meta, ";")
+		p = ContentPart{Type: PartImage, ImageData: data, MIMEType: mime}
+	default:
+		p = ContentPart{Type: PartImage, ImageData: s}
+	}
+	return p, p.Validate()
+}
+
+// Validate 检查图片片段：URL 只允许 http(s)，base64 必须能解码
+func (p ContentPart) Validate() error {
+	if p.Type != PartImage {
+		return nil
+	}
+	switch {
+	case p.ImageURL != "":
+		if !strings.HasPrefix(p.ImageURL, "http://") && !strings.HasPrefix(p.ImageURL, "https://") {
+			return fmt.Errorf("image url must be http(s): %q", p.ImageURL)
+		}
+	case p.ImageData == "":
+		return errors.New("image has neither url nor data")
+	default:
+		if _, err := p.Bytes(); err != nil {
+			return fmt.Errorf("invalid base64 image data: %w", err)
+		}
+	}
+	return nil
+}
+
+// Bytes 解码 base64 图片数据
+func (p ContentPart) Bytes() ([]byte, error) {
+	return base64.StdEncoding.DecodeString(p.ImageData)
+}
+
+// MediaType 返回图片 MIME；未显式给出时嗅探前 512 字节
+func (p ContentPart) MediaType() string {
+	if p.MIMEType != "" {
+		return p.MIMEType
+	}
+	if b, err := p.Bytes(); err == nil {
+		return http.DetectContentType(b)
+	}
+	return "application/octet-stream"
+}
+
+// DataURL 把 base64 图片拼成 data URL；URL 图片原样返回
+func (p ContentPart) DataURL() string {
+	if p.ImageURL != "" {
+		return p.ImageURL
+	}
+	return "data:" + p.MediaType() + ";base64," + p.ImageData
+}
+
+// Text 返回消息中全部文本（Content + 文本片段）
+func (m Message) Text() string {
+	if len(m.Parts) == 0 {
+		return m.Content
+	}
+	var texts []string
+	if m.Content != "" {
+		texts = append(texts, m.Content)
+	}
+	for _, p := range m.Parts {
+		if p.Type == PartText && p.Text != "" {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// Images 返回消息中的图片片段
+func (m Message) Images() []ContentPart {
+	var imgs []ContentPart
+	for _, p := range m.Parts {
+		if p.Type == PartImage {
+			imgs = append(imgs, p)
+		}
+	}
+	return imgs
+}
+
+// HasImages 判断一组消息中是否包含图片
+func HasImages(msgs []Message) bool {
+	for _, m := range msgs {
+		if len(m.Images()) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package types
+
+import "testing"
+
+func TestImagePart(t *testing.T) {
+	cases := []struct {
+		in, url, data, mime string
+		wantErr             bool
+	}{
+		{in: "https://example.com/a.png", url: "https://example.com/a.png"},
+		{in: " data:image/png;base64,iVBORw0KGgpmYWtl ", data: "iVBORw0KGgpmYWtl", mime: "image/png"},
+		{in: "iVBORw0KGgpmYWtl", data: "iVBORw0KGgpmYWtl"},
+		{in: "data:image/png;base64,not base64!", wantErr: true},
+		{in: "definitely not an image", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tc := range cases {
+		p, err := ImagePart(tc.in)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("ImagePart(%q) = %+v, want error", tc.in, p)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ImagePart(%q): %v", tc.in, err)
+			continue
+		}
+		if p.Type != PartImage || p.ImageURL != tc.url || p.ImageData != tc.data || p.MIMEType != tc.mime {
+			t.Errorf("ImagePart(%q) = %+v", tc.in, p)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	bad := []ContentPart{
+		{Type: PartImage, ImageURL: "file:///etc/passwd"},
+		{Type: PartImage},
+		{Type: PartImage, ImageData: "%%%"},
+	}
+	for _, p := range bad {
+		if p.Validate() == nil {
+			t.Errorf("Validate(%+v) = nil, want error", p)
+		}
+	}
+	if err := (ContentPart{Type: PartText, Text: "hi"}).Validate(); err != nil {
+		t.Errorf("text part: %v", err)
+	}
+}
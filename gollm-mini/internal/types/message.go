@@ -6,9 +6,14 @@ const (
 	RoleSystem    Role = "system"
 	RoleUser      Role = "user"
 	RoleAssistant Role = "assistant"
+	RoleTool      Role = "tool" // 工具执行结果
 )
 
 type Message struct {
-	Role    Role   `json:"role"`
-	Content string `json:"content"`
+	Role       Role          `json:"role"`
+	Content    string        `json:"content"`
+	Parts      []ContentPart `json:"parts,omitempty"`        // 多模态片段（文本 / 图片）
+	ToolCalls  []ToolCall    `json:"tool_calls,omitempty"`   // assistant 发起的工具调用
+	ToolCallID string        `json:"tool_call_id,omitempty"` // tool 消息对应的调用 ID
+	Name       string        `json:"name,omitempty"`         // tool 消息对应的工具名
 }
@@ -0,0 +1,37 @@
+package types
+
+import "time"
+
+// ModelInfo 描述 Provider 上的一个可用模型及其能力
+type ModelInfo struct {
+	ID            string `json:"id"`
+	Streaming     bool   `json:"streaming"`
+	Embeddings    bool   `json:"embeddings"`
+	Tools         bool   `json:"tools"`
+	Vision        bool   `json:"vision"`
+	ContextWindow int    `json:"context_window,omitempty"` // 0 表示未知
+}
+
+// ModelDetails 单个模型的详细信息（见 provider.ModelManager）
+type ModelDetails struct {
+	ModelInfo
+	Family        string    `json:"family,omitempty"`
+	ParameterSize string    `json:"parameter_size,omitempty"`
+	Quantization  string    `json:"quantization,omitempty"`
+	Format        string    `json:"format,omitempty"`
+	Capabilities  []string  `json:"capabilities,omitempty"`
+	Parameters    string    `json:"parameters,omitempty"`
+	Template      string    `json:"template,omitempty"`
+	License       string    `json:"license,omitempty"`
+	ModifiedAt    time.Time `json:"modified_at,omitempty"`
+	Hosts         []string  `json:"hosts,omitempty"` // 多主机时：已有该模型的主机
+}
+
+// PullProgress 拉取模型时的一条进度
+type PullProgress struct {
+	Host      string `json:"host,omitempty"` // 多主机时标注正在拉取的主机
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+}
@@ -0,0 +1,33 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// GenerateOptions 采样参数；零值表示使用 Provider 默认值
+type GenerateOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+	Seed        *int     `json:"seed,omitempty"`
+
+	// JSONSchema 非空时要求 Provider 用原生约束解码输出符合该 schema 的 JSON
+	// （见 provider.SchemaConstrained）；由 core.StructuredGenerate 设置，不从请求体读取
+	JSONSchema json.RawMessage `json:"-"`
+}
+
+// Validate 检查与 Provider 无关的取值范围
+func (o GenerateOptions) Validate() error {
+	if o.Temperature != nil && (*o.Temperature < 0 || *o.Temperature > 2) {
+		return fmt.Errorf("temperature must be within [0, 2], got %v", *o.Temperature)
+	}
+	if o.TopP != nil && (*o.TopP <= 0 || *o.TopP > 1) {
+		return fmt.Errorf("top_p must be within (0, 1], got %v", *o.TopP)
+	}
+	if o.MaxTokens < 0 {
+		return fmt.Errorf("max_tokens must be >= 0, got %d", o.MaxTokens)
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package types
+
+import "encoding/json"
+
+// Tool 描述一个可供模型调用的函数
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"` // JSON Schema（type=object）
+}
+
+// ToolCall 是模型返回的一次函数调用请求
+type ToolCall struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"` // JSON 对象
+}
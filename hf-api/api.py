@@ -1,15 +1,33 @@
 # server.py 关键改动
+import json
 from functools import lru_cache
+from threading import Thread
 from fastapi import FastAPI
+from fastapi.responses import StreamingResponse
 from pydantic import BaseModel
-from transformers import AutoTokenizer, AutoModelForCausalLM
+from transformers import AutoTokenizer, AutoModel, AutoModelForCausalLM, TextIteratorStreamer
 import torch
 
 app = FastAPI()
 
+class Params(BaseModel):
+    # 与 TGI parameters 字段保持一致，方便 Go 端复用
+    temperature: float | None = None
+    top_p: float | None = None
+    max_new_tokens: int | None = None
+    stop: list[str] | None = None
+    seed: int | None = None
+    do_sample: bool | None = None
+
 class ChatReq(BaseModel):
     input: str
     model: str | None = None   # 允许前端指定模型；留空则用默认
+    parameters: Params = Params()
+
+class EmbedReq(BaseModel):
+    # 与 text-embeddings-inference /embed 保持一致
+    inputs: list[str]
+    model: str | None = None
 
 @lru_cache                         # 多次请求同一个模型时复用
 def load(model_id: str):
@@ -17,6 +35,33 @@ def load(model_id: str):
     mod = AutoModelForCausalLM.from_pretrained(model_id, torch_dtype="auto")
     return tok, mod
 
+@lru_cache
+def load_encoder(model_id: str):
+    tok = AutoTokenizer.from_pretrained(model_id)
+    mod = AutoModel.from_pretrained(model_id)
+    mod.eval()
+    return tok, mod
+
+def gen_kwargs(p: Params, tokenizer) -> dict:
+    kw = {
+        "max_new_tokens": p.max_new_tokens or 1024,
+        "do_sample": bool(p.do_sample),
+    }
+    if p.temperature is not None:
+        kw["temperature"] = p.temperature
+    if p.top_p is not None:
+        kw["top_p"] = p.top_p
+    if p.stop:
+        kw["stop_strings"] = p.stop
+        kw["tokenizer"] = tokenizer
+    if p.seed is not None:
+        torch.manual_seed(p.seed)
+    return kw
+
+@app.get("/health")
+def health():
+    return {"status": "ok"}
+
 @app.post("/generate")
 def generate(req: ChatReq):
     model_id = req.model or "TinyLlama/TinyLlama-1.1B-Chat-v1.0"
@@ -26,9 +71,51 @@ def generate(req: ChatReq):
     eos = tokenizer.convert_tokens_to_ids("</s>")
     outputs = model.generate(
         **inputs,
-        max_new_tokens=1024,
-        eos_token_id=eos, 
-        do_sample=False
+        eos_token_id=eos,
+        **gen_kwargs(req.parameters, tokenizer),
     )
     text = tokenizer.decode(outputs[0], skip_special_tokens=False)
     return {"text": text}
+
+@app.post("/generate_stream")
+def generate_stream(req: ChatReq):
+    """SSE 流式输出，事件格式与 TGI /generate_stream 一致"""
+    model_id = req.model or "TinyLlama/TinyLlama-1.1B-Chat-v1.0"
+    tokenizer, model = load(model_id)
+
+    inputs = tokenizer(req.input, return_tensors="pt")
+    eos = tokenizer.convert_tokens_to_ids("</s>")
+    streamer = TextIteratorStreamer(tokenizer, skip_prompt=True, skip_special_tokens=True)
+    kwargs = dict(**inputs, eos_token_id=eos, streamer=streamer, **gen_kwargs(req.parameters, tokenizer))
+    Thread(target=model.generate, kwargs=kwargs, daemon=True).start()
+
+    def events():
+        text, n = "", 0
+        for piece in streamer:
+            if not piece:
+                continue
+            n += 1
+            text += piece
+            ev = {"index": n, "token": {"text": piece, "special": False},
+                  "generated_text": None, "details": None}
+            yield f"data: {json.dumps(ev)}\n\n"
+        done = {"index": n + 1, "token": {"text": "", "special": True},
+                "generated_text": text,
+                "details": {"generated_tokens": len(tokenizer.encode(text, add_special_tokens=False))}}
+        yield f"data: {json.dumps(done)}\n\n"
+
+    return StreamingResponse(events(), media_type="text/event-stream")
+
+@app.post("/embed")
+def embed(req: EmbedReq):
+    """返回 [[float, ...], ...]，顺序与 inputs 一致（mean pooling + L2 归一化）"""
+    model_id = req.model or "sentence-transformers/all-MiniLM-L6-v2"
+    tokenizer, model = load_encoder(model_id)
+
+    batch = tokenizer(req.inputs, padding=True, truncation=True, return_tensors="pt")
+    with torch.no_grad():
+        hidden = model(**batch).last_hidden_state
+    mask = batch["attention_mask"].unsqueeze(-1).to(hidden.dtype)
+    pooled = (hidden * mask).sum(dim=1) / mask.sum(dim=1).clamp(min=1e-9)
+    pooled = torch.nn.functional.normalize(pooled, p=2, dim=1)
+    return pooled.tolist()